@@ -0,0 +1,249 @@
+package stun
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"time"
+)
+
+// AccessToken is the content of a self-contained token used by third-party
+// authorization (RFC 7635 §6.2). An authorization server issues it to a
+// client, encrypted with a key it shares with the STUN server, together with
+// the MAC key in clear; the client proves possession of the MAC key by
+// signing its requests with it.
+//
+//	struct {
+//		uint16_t nonce_length;
+//		opaque nonce[nonce_length];
+//		opaque {
+//			uint16_t key_length;
+//			opaque mac_key[key_length];
+//			uint64_t timestamp;
+//			uint32_t lifetime;
+//		} encrypted_block;
+//	} token;
+type AccessToken struct {
+	// MACKey is the session key the client computes MESSAGE-INTEGRITY with.
+	MACKey []byte
+	// Timestamp is when the token was issued.
+	Timestamp time.Time
+	// Lifetime is how long after Timestamp the token is valid.
+	Lifetime time.Duration
+}
+
+// Expired reports whether the token is no longer valid at now.
+func (t AccessToken) Expired(now time.Time) bool {
+	return now.After(t.Timestamp.Add(t.Lifetime))
+}
+
+// Seal encrypts the token with AES-GCM under key, a 16 or 32 byte key shared
+// between the authorization server and the STUN server, authenticating the
+// name of the STUN server as additional data. The result is the value of the
+// ACCESS-TOKEN attribute.
+//
+// Example:
+//
+//	token, err := stun.AccessToken{
+//		MACKey:    macKey,
+//		Timestamp: time.Now(),
+//		Lifetime:  time.Hour,
+//	}.Seal(sharedKey, "stun.example.org")
+func (t AccessToken) Seal(key []byte, serverName string) (AccessTokenAttribute, error) {
+	aead, err := newAccessTokenAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	plain := make([]byte, 0, 2+len(t.MACKey)+12)
+	plain = binary.BigEndian.AppendUint16(plain, uint16(len(t.MACKey)))
+	plain = append(plain, t.MACKey...)
+	plain = binary.BigEndian.AppendUint64(plain, encodeTokenTimestamp(t.Timestamp))
+	plain = binary.BigEndian.AppendUint32(plain, uint32(t.Lifetime/time.Second))
+
+	token := binary.BigEndian.AppendUint16(nil, uint16(len(nonce)))
+	token = append(token, nonce...)
+	return aead.Seal(token, nonce, plain, []byte(serverName)), nil
+}
+
+// OpenAccessToken decrypts the ACCESS-TOKEN value token with key for the STUN
+// server serverName.
+//
+// Returns ErrInvalidAccessToken if the token is malformed or was not sealed
+// with key for serverName.
+func OpenAccessToken(token AccessTokenAttribute, key []byte, serverName string) (AccessToken, error) {
+	aead, err := newAccessTokenAEAD(key)
+	if err != nil {
+		return AccessToken{}, err
+	}
+	if len(token) < 2 {
+		return AccessToken{}, ErrInvalidAccessToken
+	}
+	n := int(binary.BigEndian.Uint16(token))
+	if n != aead.NonceSize() || len(token) < 2+n {
+		return AccessToken{}, ErrInvalidAccessToken
+	}
+	nonce := token[2 : 2+n]
+	plain, err := aead.Open(nil, nonce, token[2+n:], []byte(serverName))
+	if err != nil {
+		return AccessToken{}, ErrInvalidAccessToken
+	}
+
+	if len(plain) < 2 {
+		return AccessToken{}, ErrInvalidAccessToken
+	}
+	keyLen := int(binary.BigEndian.Uint16(plain))
+	if len(plain) != 2+keyLen+12 {
+		return AccessToken{}, ErrInvalidAccessToken
+	}
+	rest := plain[2+keyLen:]
+	return AccessToken{
+		MACKey:    plain[2 : 2+keyLen],
+		Timestamp: decodeTokenTimestamp(binary.BigEndian.Uint64(rest)),
+		Lifetime:  time.Duration(binary.BigEndian.Uint32(rest[8:])) * time.Second,
+	}, nil
+}
+
+// newAccessTokenAEAD returns the AES-GCM cipher for key, AEAD_AES_128_GCM or
+// AEAD_AES_256_GCM depending on its length.
+func newAccessTokenAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// encodeTokenTimestamp returns the token timestamp of t: 48 bits of seconds
+// since the Unix epoch followed by 16 bits of 1/64000 second.
+func encodeTokenTimestamp(t time.Time) uint64 {
+	frac := uint64(t.Nanosecond()) * 64000 / uint64(time.Second)
+	return uint64(t.Unix())<<16 | frac
+}
+
+// decodeTokenTimestamp is the inverse of encodeTokenTimestamp.
+func decodeTokenTimestamp(v uint64) time.Time {
+	frac := (v & 0xFFFF) * uint64(time.Second) / 64000
+	return time.Unix(int64(v>>16), int64(frac))
+}
+
+// AccessTokenAttribute is the value of an ACCESS-TOKEN attribute
+// (RFC 7635 §6.2): an AccessToken sealed by the authorization server.
+type AccessTokenAttribute []byte
+
+// AddTo appends the token to m as an ACCESS-TOKEN attribute.
+func (t AccessTokenAttribute) AddTo(m *Message) error {
+	if paddedLength(len(t)) > maxAttrValueLength(m) {
+		return ErrAttrTooLong
+	}
+	return m.Add(AccessTokenAttr, append([]byte(nil), t...))
+}
+
+// GetFrom decodes the ACCESS-TOKEN attribute of m into t.
+//
+// Returns ErrAttrNotFound if m has no ACCESS-TOKEN attribute.
+func (t *AccessTokenAttribute) GetFrom(m *Message) error {
+	attr, ok := m.GetAttr(AccessTokenAttr)
+	if !ok {
+		return ErrAttrNotFound
+	}
+	*t = append(AccessTokenAttribute(nil), attr.rawValue()...)
+	return nil
+}
+
+// ThirdPartyAuthorizationAttribute is the value of a
+// THIRD-PARTY-AUTHORIZATION attribute (RFC 7635 §6.1): the name of the
+// authorization server, sent by a STUN server in 401 responses to signal
+// that it accepts access tokens.
+type ThirdPartyAuthorizationAttribute string
+
+// AddTo appends the server name to m as a THIRD-PARTY-AUTHORIZATION attribute.
+func (a ThirdPartyAuthorizationAttribute) AddTo(m *Message) error {
+	return m.Add(ThirdPartyAuthorization, []byte(a))
+}
+
+// GetFrom decodes the THIRD-PARTY-AUTHORIZATION attribute of m into a.
+//
+// Returns ErrAttrNotFound if m has no THIRD-PARTY-AUTHORIZATION attribute.
+func (a *ThirdPartyAuthorizationAttribute) GetFrom(m *Message) error {
+	attr, ok := m.GetAttr(ThirdPartyAuthorization)
+	if !ok {
+		return ErrAttrNotFound
+	}
+	*a = ThirdPartyAuthorizationAttribute(attr.rawValue())
+	return nil
+}
+
+// OAuthCredentials are the credentials a client obtained from an
+// authorization server for third-party authorization (RFC 7635 §4): the key
+// identifier, the sealed access token and the MAC key in clear.
+type OAuthCredentials struct {
+	KeyID  string
+	Token  AccessTokenAttribute
+	MACKey []byte
+}
+
+// AddTo authorizes the request m with the credentials: the key identifier is
+// sent as USERNAME, the token as ACCESS-TOKEN, and MESSAGE-INTEGRITY is
+// computed with the MAC key. Nothing but FINGERPRINT may be added afterwards.
+//
+// Example:
+//
+//	req := &stun.Message{Header: stun.Header{
+//		Type:          stun.NewMessageType(stun.MethodAllocate, stun.ClassRequest),
+//		MagicCookie:   0x2112A442,
+//		TransactionID: txID,
+//	}}
+//	if err := creds.AddTo(req); err != nil {
+//		log.Fatal(err)
+//	}
+func (c OAuthCredentials) AddTo(m *Message) error {
+	if err := m.SetUsername(c.KeyID); err != nil {
+		return err
+	}
+	if err := c.Token.AddTo(m); err != nil {
+		return err
+	}
+	return Integrity(c.MACKey).AddTo(m)
+}
+
+// CheckAccessToken authorizes a request carrying an access token on the STUN
+// server named serverName: the key shared with the authorization server is
+// looked up by the key identifier in USERNAME, the token is decrypted and
+// checked for expiry at now, and MESSAGE-INTEGRITY is verified with the MAC
+// key of the token, which is returned.
+//
+// Returns ErrAttrNotFound if USERNAME or ACCESS-TOKEN is missing,
+// ErrInvalidAccessToken if the token cannot be opened,
+// ErrAccessTokenExpired if it has expired and ErrIntegrityMismatch if the
+// message was not signed with its MAC key.
+func CheckAccessToken(m *Message, serverName string, now time.Time, key func(keyID string) ([]byte, error)) (AccessToken, error) {
+	keyID, err := m.GetUsername()
+	if err != nil {
+		return AccessToken{}, err
+	}
+	var sealed AccessTokenAttribute
+	if err := sealed.GetFrom(m); err != nil {
+		return AccessToken{}, err
+	}
+	k, err := key(keyID)
+	if err != nil {
+		return AccessToken{}, err
+	}
+	token, err := OpenAccessToken(sealed, k, serverName)
+	if err != nil {
+		return AccessToken{}, err
+	}
+	if token.Expired(now) {
+		return AccessToken{}, ErrAccessTokenExpired
+	}
+	if err := Integrity(token.MACKey).Check(m); err != nil {
+		return AccessToken{}, err
+	}
+	return token, nil
+}
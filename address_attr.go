@@ -0,0 +1,122 @@
+package stun
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+)
+
+// AddressAttribute is the codec of the attributes carrying a transport
+// address in the MAPPED-ADDRESS wire format (RFC 5389 §15.1): MAPPED-ADDRESS,
+// ALTERNATE-SERVER, RESPONSE-ORIGIN, OTHER-ADDRESS, the SOURCE-ADDRESS and
+// CHANGED-ADDRESS of RFC 3489 and, with XOR set, the XOR-* attributes, whose
+// port and address are XORed with the magic cookie and, for IPv6, the
+// transaction ID (RFC 5389 §15.2).
+//
+// New address attributes are defined by declaring a codec for their type
+// rather than reimplementing serialization:
+//
+//	xorPeer := stun.AddressAttribute{Type: stun.XORPeerAddress, XOR: true}
+//	if err := xorPeer.Add(msg, stun.MappedAddr{IP: peerIP, Port: peerPort}); err != nil {
+//		log.Fatal(err)
+//	}
+type AddressAttribute struct {
+	Type StunAttribute
+	XOR  bool
+}
+
+// Add appends addr to m as an attribute of the codec type. The family is
+// derived from addr.IP, so addr.Family may be left unset. For XOR codecs the
+// transaction ID of m must be set beforehand.
+func (c AddressAttribute) Add(m *Message, addr MappedAddr) error {
+	value, err := c.Encode(addr, m.Header.TransactionID)
+	if err != nil {
+		return err
+	}
+	return m.Add(c.Type, value)
+}
+
+// Get decodes the first attribute of the codec type in m.
+//
+// Returns ErrAttrNotFound if m has no such attribute.
+func (c AddressAttribute) Get(m *Message) (MappedAddr, error) {
+	attr, ok := m.GetAttr(c.Type)
+	if !ok {
+		return MappedAddr{}, ErrAttrNotFound
+	}
+	return c.Decode(attr.rawValue(), m.Header.TransactionID)
+}
+
+// Encode returns the attribute value for addr. transactionID is only used by
+// XOR codecs.
+func (c AddressAttribute) Encode(addr MappedAddr, transactionID TransactionID) ([]byte, error) {
+	family, ip := IPV4, addr.IP.To4()
+	if ip == nil {
+		family, ip = IPV6, addr.IP.To16()
+		if ip == nil {
+			return nil, fmt.Errorf("invalid IP address: %v", addr.IP)
+		}
+	}
+
+	buf := make([]byte, 4+len(ip))
+	buf[0] = 0x00 // Reserved
+	buf[1] = byte(family)
+	binary.BigEndian.PutUint16(buf[2:4], addr.Port)
+	copy(buf[4:], ip)
+	if c.XOR {
+		xorAddrValue(buf, transactionID)
+	}
+	return buf, nil
+}
+
+// Decode decodes the attribute value buf, checking its length against the
+// address family. transactionID is only used by XOR codecs.
+func (c AddressAttribute) Decode(buf []byte, transactionID TransactionID) (MappedAddr, error) {
+	if len(buf) < 4 {
+		return MappedAddr{}, ErrShortBuffer
+	}
+	family := IPFamily(buf[1])
+
+	var ipLen int
+	switch family {
+	case IPV4:
+		ipLen = net.IPv4len
+	case IPV6:
+		ipLen = net.IPv6len
+	default:
+		return MappedAddr{}, fmt.Errorf("unsupported address family: 0x%02x", uint16(family))
+	}
+	if len(buf) < 4+ipLen {
+		return MappedAddr{}, ErrShortBuffer
+	}
+
+	value := append([]byte(nil), buf[:4+ipLen]...)
+	if c.XOR {
+		xorAddrValue(value, transactionID)
+	}
+	return MappedAddr{
+		Family: family,
+		IP:     net.IP(value[4:]),
+		Port:   binary.BigEndian.Uint16(value[2:4]),
+	}, nil
+}
+
+// xorAddrValue XORs in place the port and address of an address attribute
+// value with the magic cookie followed by the transaction ID.
+func xorAddrValue(value []byte, transactionID TransactionID) {
+	key := xorKey(transactionID)
+	value[2] ^= key[0]
+	value[3] ^= key[1]
+	for i := range value[4:] {
+		value[4+i] ^= key[i]
+	}
+}
+
+// xorKey returns the 16 bytes addresses are XORed with: the magic cookie
+// followed by the transaction ID.
+func xorKey(transactionID TransactionID) [16]byte {
+	var key [16]byte
+	binary.BigEndian.PutUint32(key[0:4], magicCookie)
+	copy(key[4:], transactionID[:])
+	return key
+}
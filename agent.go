@@ -0,0 +1,588 @@
+package stun
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// DefaultAgentTimeout is the transaction timeout of an Agent whose
+// configuration leaves Timeout unset.
+const DefaultAgentTimeout = 5 * time.Second
+
+// DefaultAgentMaxPeers is the number of peer-reflexive addresses an Agent
+// learns from Binding requests when its configuration leaves MaxPeers unset.
+const DefaultAgentMaxPeers = 1024
+
+// Agent is a STUN endpoint bound to a single packet connection, as used for
+// hole punching and ICE connectivity checks: it sends requests to peers and
+// matches their responses by transaction ID, while receiving the requests
+// those peers send in turn.
+//
+// The agent keeps track of the peer addresses it knows, either added with
+// AddPeer or contacted with Do. An authenticated Binding request arriving
+// from any other address reveals a peer-reflexive address and is reported to
+// the OnPeerReflexive callback.
+//
+// Example:
+//
+//	conn, err := net.ListenPacket("udp4", ":0")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	agent := stun.NewAgent(stun.AgentConfig{
+//		Conn:      conn,
+//		Integrity: stun.NewShortTermIntegrity(localPassword),
+//		OnPeerReflexive: func(addr net.Addr, m *stun.Message) {
+//			fmt.Println("new candidate:", addr)
+//		},
+//	})
+//	defer agent.Close()
+type Agent struct {
+	conn            net.PacketConn
+	logger          *Logger
+	timeout         time.Duration
+	onPeerReflexive func(addr net.Addr, m *Message)
+	respond         bool
+	integrity       Integrity
+	maxPeers        int
+	clock           Clock
+	rand            io.Reader
+	slots           chan struct{}
+	maxQueued       int
+	maxMessageSize  int
+
+	mu           sync.Mutex
+	peers        map[string]bool
+	learned      int
+	transactions map[TransactionID]chan *Message
+	owners       []transactionOwner
+	closed       bool
+	queued       int
+	rejected     uint64
+
+	done chan struct{}
+}
+
+// AgentConfig holds configuration options for creating an Agent.
+type AgentConfig struct {
+	// Conn is the connection the agent sends and receives on. It is closed by
+	// Agent.Close.
+	Conn net.PacketConn
+	// Logger is the logger instance to use for logging. Nil selects the
+	// default logger.
+	Logger *Logger
+	// Timeout bounds how long Do waits for a response. Zero selects
+	// DefaultAgentTimeout.
+	Timeout time.Duration
+	// OnPeerReflexive is called with the source address and the parsed
+	// message of every Binding request received from an address the agent
+	// does not know yet, once its integrity is verified, so that the
+	// application can add the peer-reflexive candidate. The address is known
+	// from then on. The callback runs on the read loop of the agent and must
+	// not block.
+	OnPeerReflexive func(addr net.Addr, m *Message)
+	// Integrity is the short-term key under which inbound Binding requests
+	// must carry a valid MESSAGE-INTEGRITY or MESSAGE-INTEGRITY-SHA256, e.g.
+	// built from the ICE password of the agent. Other requests are dropped
+	// before their source is learned or answered, and the responses of
+	// RespondToBinding are signed with the key. Nil accepts unauthenticated
+	// requests, letting any host that reaches the agent make it report
+	// addresses.
+	Integrity Integrity
+	// MaxPeers caps the peer-reflexive addresses the agent learns from
+	// Binding requests. Requests from further unknown addresses are still
+	// answered but neither learned nor reported. Zero selects
+	// DefaultAgentMaxPeers.
+	MaxPeers int
+	// RespondToBinding makes the agent answer inbound Binding requests with
+	// a success response carrying the source address of the request as
+	// XOR-MAPPED-ADDRESS, as each side must when both probe each other
+	// during hole punching.
+	RespondToBinding bool
+	// Clock times out the transactions of the agent. Nil selects the system
+	// clock. Together with Rand and a simulated Conn, it lets tests script
+	// interleavings deterministically (see stuntest.Clock and stuntest.Network).
+	Clock Clock
+	// Rand is the source of the transaction IDs. Nil selects crypto/rand.
+	Rand io.Reader
+	// MaxPending caps the transactions of Do outstanding at once, bounding
+	// the memory held for them and the rate of requests towards peers as ICE
+	// pacing requires. Zero means no limit.
+	MaxPending int
+	// MaxQueued is the number of calls to Do allowed to wait for one of the
+	// MaxPending slots, in arrival order, once all are taken. Calls beyond it
+	// fail with ErrAgentBusy; zero rejects as soon as all slots are taken.
+	MaxQueued int
+	// MaxMessageSize is the size of the largest datagram the agent reads.
+	// Larger ones are dropped. Zero selects the MaxMessageSize of the decode
+	// limits (see SetDecodeLimits).
+	MaxMessageSize int
+}
+
+// AgentStats is a snapshot of the transactions of an Agent.
+type AgentStats struct {
+	// Pending is the number of transactions of Do awaiting a response.
+	Pending int
+	// Queued is the number of calls to Do waiting for a free slot.
+	Queued int
+	// Rejected is the number of calls to Do that failed with ErrAgentBusy.
+	Rejected uint64
+}
+
+// NewAgent creates an Agent on cfg.Conn and starts reading from it.
+func NewAgent(cfg AgentConfig) *Agent {
+	logger := cfg.Logger
+	if logger == nil {
+		logger = NewDefaultLogger()
+	}
+	timeout := cfg.Timeout
+	if timeout == 0 {
+		timeout = DefaultAgentTimeout
+	}
+	clock := cfg.Clock
+	if clock == nil {
+		clock = systemClock{}
+	}
+	random := cfg.Rand
+	if random == nil {
+		random = rand.Reader
+	}
+	maxPeers := cfg.MaxPeers
+	if maxPeers == 0 {
+		maxPeers = DefaultAgentMaxPeers
+	}
+
+	a := &Agent{
+		conn:            cfg.Conn,
+		logger:          logger,
+		timeout:         timeout,
+		onPeerReflexive: cfg.OnPeerReflexive,
+		respond:         cfg.RespondToBinding,
+		integrity:       cfg.Integrity,
+		maxPeers:        maxPeers,
+		clock:           clock,
+		rand:            random,
+		maxQueued:       cfg.MaxQueued,
+		maxMessageSize:  cfg.MaxMessageSize,
+		peers:           make(map[string]bool),
+		transactions:    make(map[TransactionID]chan *Message),
+		done:            make(chan struct{}),
+	}
+	if cfg.MaxPending > 0 {
+		a.slots = make(chan struct{}, cfg.MaxPending)
+	}
+	go a.readLoop()
+	return a
+}
+
+// AddPeer marks addr as a known peer, so that its Binding requests are not
+// reported as peer-reflexive.
+func (a *Agent) AddPeer(addr net.Addr) {
+	a.mu.Lock()
+	a.peers[addr.String()] = true
+	a.mu.Unlock()
+}
+
+// KnownPeer reports whether addr is a known peer of the agent.
+func (a *Agent) KnownPeer(addr net.Addr) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.peers[addr.String()]
+}
+
+// Do sends the request m to addr and waits for the matching response. The
+// magic cookie, length and a fresh transaction ID are set on m, and addr
+// becomes a known peer.
+//
+// When AgentConfig.MaxPending transactions are outstanding, Do waits for one
+// of them to complete before sending, or fails with ErrAgentBusy if the queue
+// is full. The timeout starts once m is sent.
+//
+// Returns ErrTransactionTimeout if no response arrives in time,
+// ErrAgentClosed if the agent is closed meanwhile and the error of
+// AgentConfig.Rand if no transaction ID can be generated.
+//
+// Example:
+//
+//	resp, err := agent.Do(&stun.Message{
+//		Header: stun.Header{Type: stun.BindingRequest},
+//	}, peerAddr)
+func (a *Agent) Do(m *Message, addr net.Addr) (*Message, error) {
+	if err := a.acquire(); err != nil {
+		return nil, err
+	}
+	defer a.release()
+
+	m.Header.MagicCookie = magicCookie
+	for {
+		id, err := a.NewTransactionID(nil)
+		if err != nil {
+			return nil, err
+		}
+		// Keep the namespaces of registered owners for their own responses
+		if a.owner(id) == nil {
+			m.Header.TransactionID = id
+			break
+		}
+	}
+
+	ch := make(chan *Message, 1)
+	a.mu.Lock()
+	if a.closed {
+		a.mu.Unlock()
+		return nil, ErrAgentClosed
+	}
+	a.transactions[m.Header.TransactionID] = ch
+	a.peers[addr.String()] = true
+	a.mu.Unlock()
+	defer a.forget(m.Header.TransactionID)
+
+	if _, err := a.conn.WriteTo(m.Encode(), addr); err != nil {
+		loggerWithTransaction(a.logger, m.Header.TransactionID, addr.String()).
+			LogError("Failed to write request to peer", err)
+		return nil, err
+	}
+
+	select {
+	case resp := <-ch:
+		return resp, nil
+	case <-a.clock.After(a.timeout):
+		return nil, ErrTransactionTimeout
+	case <-a.done:
+		return nil, ErrAgentClosed
+	}
+}
+
+// acquire takes one of the MaxPending slots of the agent, waiting in the
+// queue if all are taken and it has room.
+func (a *Agent) acquire() error {
+	if a.slots == nil {
+		return nil
+	}
+	select {
+	case a.slots <- struct{}{}:
+		return nil
+	default:
+	}
+
+	a.mu.Lock()
+	if a.queued >= a.maxQueued {
+		a.rejected++
+		a.mu.Unlock()
+		metrics.Add(MetricAgentRejected, 1)
+		return ErrAgentBusy
+	}
+	a.queued++
+	a.mu.Unlock()
+	metrics.Add(MetricAgentQueued, 1)
+	defer func() {
+		a.mu.Lock()
+		a.queued--
+		a.mu.Unlock()
+		metrics.Add(MetricAgentQueued, -1)
+	}()
+
+	select {
+	case a.slots <- struct{}{}:
+		return nil
+	case <-a.done:
+		return ErrAgentClosed
+	}
+}
+
+// release frees the slot taken by acquire.
+func (a *Agent) release() {
+	if a.slots != nil {
+		<-a.slots
+	}
+}
+
+// Stats returns the number of outstanding and queued transactions of the
+// agent. The queue depth of all agents of the process is also published as
+// the MetricAgentQueued metric, and rejections as MetricAgentRejected.
+func (a *Agent) Stats() AgentStats {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return AgentStats{
+		Pending:  len(a.transactions),
+		Queued:   a.queued,
+		Rejected: a.rejected,
+	}
+}
+
+// Close closes the connection of the agent, waits for its read loop to
+// return and fails the pending transactions with ErrAgentClosed.
+func (a *Agent) Close() error {
+	a.mu.Lock()
+	if a.closed {
+		a.mu.Unlock()
+		return ErrAgentClosed
+	}
+	a.closed = true
+	a.mu.Unlock()
+
+	err := a.conn.Close()
+	<-a.done
+	return err
+}
+
+// MaxOwnerPrefixLength is the longest transaction ID prefix an owner may
+// register, leaving the rest of the ID random.
+const MaxOwnerPrefixLength = 4
+
+// ResponseHandler receives the responses to the transactions of an owner
+// registered with Agent.RegisterOwner.
+type ResponseHandler func(m *Message, addr net.Addr)
+
+// transactionOwner is a layer sharing the connection of an agent, owning the
+// transaction IDs that start with prefix.
+type transactionOwner struct {
+	prefix  []byte
+	handler ResponseHandler
+}
+
+// RegisterOwner partitions the transaction ID space of the agent so that a
+// layer sharing its connection, e.g. a TURN or ICE client built on this
+// package, receives its responses directly: every response whose transaction
+// ID starts with prefix and that does not belong to a transaction of Do is
+// passed to h. The layer generates its IDs with NewTransactionID. h runs on
+// the read loop of the agent and must not block.
+//
+// Returns ErrOwnerPrefix if prefix is empty or longer than
+// MaxOwnerPrefixLength bytes, and ErrOwnerConflict if it overlaps the prefix
+// of a registered owner.
+//
+// Example:
+//
+//	prefix := []byte{'T'}
+//	if err := agent.RegisterOwner(prefix, turnClient.HandleResponse); err != nil {
+//		log.Fatal(err)
+//	}
+//	id, err := agent.NewTransactionID(prefix)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	req.Header.TransactionID = id
+func (a *Agent) RegisterOwner(prefix []byte, h ResponseHandler) error {
+	if len(prefix) == 0 || len(prefix) > MaxOwnerPrefixLength {
+		return ErrOwnerPrefix
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, o := range a.owners {
+		if bytes.HasPrefix(o.prefix, prefix) || bytes.HasPrefix(prefix, o.prefix) {
+			return ErrOwnerConflict
+		}
+	}
+	a.owners = append(a.owners, transactionOwner{
+		prefix:  append([]byte(nil), prefix...),
+		handler: h,
+	})
+	return nil
+}
+
+// UnregisterOwner removes the owner of prefix, if any.
+func (a *Agent) UnregisterOwner(prefix []byte) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for i, o := range a.owners {
+		if bytes.Equal(o.prefix, prefix) {
+			a.owners = append(a.owners[:i], a.owners[i+1:]...)
+			return
+		}
+	}
+}
+
+// NewTransactionID returns a random transaction ID starting with prefix, to
+// be used by the owner of prefix. prefix is truncated to
+// MaxOwnerPrefixLength bytes. The random bytes come from AgentConfig.Rand,
+// whose error is returned if it cannot fill the ID.
+func (a *Agent) NewTransactionID(prefix []byte) (TransactionID, error) {
+	var id TransactionID
+	n := copy(id[:MaxOwnerPrefixLength], prefix)
+	if _, err := io.ReadFull(a.rand, id[n:]); err != nil {
+		return TransactionID{}, err
+	}
+	return id, nil
+}
+
+// owner returns the handler of the owner of the transaction id, or nil.
+// Owners are few, so a linear scan of their prefixes is cheaper than
+// hashing the ID.
+func (a *Agent) owner(id TransactionID) ResponseHandler {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, o := range a.owners {
+		if bytes.HasPrefix(id[:], o.prefix) {
+			return o.handler
+		}
+	}
+	return nil
+}
+
+// forget removes the transaction id from the pending transactions.
+func (a *Agent) forget(id TransactionID) {
+	a.mu.Lock()
+	delete(a.transactions, id)
+	a.mu.Unlock()
+}
+
+// readLoop dispatches the packets received on the connection until it is
+// closed.
+func (a *Agent) readLoop() {
+	defer close(a.done)
+
+	maxSize := readSize(a.maxMessageSize)
+	buff := make([]byte, maxSize+1)
+	for {
+		n, addr, err := a.conn.ReadFrom(buff)
+		if err != nil {
+			a.mu.Lock()
+			closed := a.closed
+			a.mu.Unlock()
+			if !closed {
+				a.logger.LogError("Agent read failed", err, map[string]interface{}{
+					"local_addr": a.conn.LocalAddr().String(),
+				})
+			}
+			return
+		}
+
+		err = checkRead(n, maxSize)
+		var m *Message
+		if err == nil {
+			m, err = NewMessage(append([]byte(nil), buff[:n]...))
+		}
+		if err != nil {
+			a.logger.Debug("Ignoring non-STUN packet", map[string]interface{}{
+				"remote_addr": addr.String(),
+				"error":       err.Error(),
+			})
+			continue
+		}
+		a.handle(m, addr)
+	}
+}
+
+// handle routes a received message: responses complete their transaction or
+// go to the owner of their transaction ID, authenticated Binding requests from
+// unknown addresses are reported as peer-reflexive and, if enabled, answered.
+func (a *Agent) handle(m *Message, addr net.Addr) {
+	switch m.Header.Type.Class() {
+	case ClassSuccessResponse, ClassErrorResponse:
+		a.mu.Lock()
+		ch, ok := a.transactions[m.Header.TransactionID]
+		a.mu.Unlock()
+		if !ok {
+			if h := a.owner(m.Header.TransactionID); h != nil {
+				h(m, addr)
+				return
+			}
+			loggerWithTransaction(a.logger, m.Header.TransactionID, addr.String()).
+				Debug("Ignoring response to unknown transaction")
+			return
+		}
+		select {
+		case ch <- m:
+		default:
+		}
+	case ClassRequest:
+		if m.Header.Type.Method() != MethodBinding {
+			return
+		}
+		if err := a.authenticate(m); err != nil {
+			loggerWithTransaction(a.logger, m.Header.TransactionID, addr.String()).
+				Debug("Dropping unauthenticated Binding request", map[string]interface{}{
+					"error": err.Error(),
+				})
+			return
+		}
+		if a.learn(addr) && a.onPeerReflexive != nil {
+			a.logger.Debug("Peer reflexive address learned", map[string]interface{}{
+				"remote_addr": addr.String(),
+			})
+			a.onPeerReflexive(addr, m)
+		}
+		if a.respond {
+			a.respondBinding(m, addr)
+		}
+	}
+}
+
+// authenticate verifies the MESSAGE-INTEGRITY-SHA256 of the request m if it
+// has one, its MESSAGE-INTEGRITY otherwise, under AgentConfig.Integrity.
+func (a *Agent) authenticate(m *Message) error {
+	if a.integrity == nil {
+		return nil
+	}
+	if _, ok := m.GetAttr(MessageIntegritySHA256); ok {
+		return NewIntegritySHA256(a.integrity).Check(m)
+	}
+	return a.integrity.Check(m)
+}
+
+// learn marks addr as a known peer and reports whether it was unknown, unless
+// MaxPeers addresses were learned already.
+func (a *Agent) learn(addr net.Addr) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.peers[addr.String()] {
+		return false
+	}
+	if a.learned >= a.maxPeers {
+		a.logger.Debug("Peer reflexive address not learned, too many peers", map[string]interface{}{
+			"remote_addr": addr.String(),
+		})
+		return false
+	}
+	a.learned++
+	a.peers[addr.String()] = true
+	return true
+}
+
+// respondBinding answers the Binding request m received from addr with the
+// address as XOR-MAPPED-ADDRESS.
+func (a *Agent) respondBinding(m *Message, addr net.Addr) {
+	if err := a.writeBindingResponse(m, addr); err != nil {
+		loggerWithTransaction(a.logger, m.Header.TransactionID, addr.String()).
+			LogError("Failed to respond to Binding request", err)
+	}
+}
+
+// writeBindingResponse sends the success response to the Binding request m
+// received from addr.
+func (a *Agent) writeBindingResponse(m *Message, addr net.Addr) error {
+	port, ip, err := GetPortAndIPFromAddr(addr)
+	if err != nil {
+		return err
+	}
+	if ip == nil {
+		return ErrUnsupportedAddr
+	}
+
+	resp := NewBindingSuccess(m.Header.TransactionID)
+	if err := (XorMappedAddr{IP: ip, Port: uint16(port)}).AddTo(resp); err != nil {
+		return err
+	}
+	if a.integrity != nil {
+		var err error
+		if _, ok := m.GetAttr(MessageIntegritySHA256); ok {
+			err = NewIntegritySHA256(a.integrity).AddTo(resp)
+		} else {
+			err = a.integrity.AddTo(resp)
+		}
+		if err != nil {
+			return err
+		}
+		if err := (FingerprintAttribute{}).AddTo(resp); err != nil {
+			return err
+		}
+	}
+	_, err = a.conn.WriteTo(resp.Encode(), addr)
+	return err
+}
@@ -0,0 +1,141 @@
+package stun
+
+import (
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestAgent returns an agent on a loopback UDP socket.
+func newTestAgent(t *testing.T, cfg AgentConfig) *Agent {
+	t.Helper()
+	cfg.Conn = listenUDP(t)
+	if cfg.Logger == nil {
+		cfg.Logger = quietLogger()
+	}
+	a := NewAgent(cfg)
+	t.Cleanup(func() { a.Close() })
+	return a
+}
+
+func TestAgentNewTransactionIDRandError(t *testing.T) {
+	a := newTestAgent(t, AgentConfig{Rand: strings.NewReader("abc")})
+	if _, err := a.NewTransactionID([]byte{'T'}); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("got %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+}
+
+func TestAgentDoRandError(t *testing.T) {
+	a := newTestAgent(t, AgentConfig{Rand: strings.NewReader("")})
+	// An exhausted source used to yield zero IDs, all owned by this prefix,
+	// and Do looped forever looking for one that is not
+	if err := a.RegisterOwner([]byte{0}, func(*Message, net.Addr) {}); err != nil {
+		t.Fatal(err)
+	}
+
+	peer := listenUDP(t)
+	_, err := a.Do(&Message{Header: Header{Type: BindingRequest}}, peer.LocalAddr())
+	if !errors.Is(err, io.EOF) {
+		t.Fatalf("got %v, want %v", err, io.EOF)
+	}
+	if stats := a.Stats(); stats.Pending != 0 {
+		t.Fatalf("%d transactions pending after a failed Do", stats.Pending)
+	}
+}
+
+// reportedPeers returns a channel receiving the addresses an agent reports
+// to OnPeerReflexive.
+func reportedPeers(cfg *AgentConfig) <-chan string {
+	ch := make(chan string, 16)
+	cfg.OnPeerReflexive = func(addr net.Addr, m *Message) {
+		ch <- addr.String()
+	}
+	return ch
+}
+
+// readResponse reads the next message received on conn.
+func readResponse(t *testing.T, conn *net.UDPConn) *Message {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	buf := make([]byte, 1500)
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := NewMessage(buf[:n])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestAgentDropsUnauthenticatedBindingRequests(t *testing.T) {
+	key := NewShortTermIntegrity("local-password")
+	cfg := AgentConfig{Integrity: key, RespondToBinding: true}
+	reported := reportedPeers(&cfg)
+	a := newTestAgent(t, cfg)
+	peer := listenUDP(t)
+
+	forged := NewBindingRequest()
+	NewShortTermIntegrity("guess").AddTo(forged)
+	for _, req := range []*Message{NewBindingRequest(), forged} {
+		if _, err := peer.WriteTo(req.Encode(), a.conn.LocalAddr()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req := NewBindingRequest()
+	NewIntegritySHA256(key).AddTo(req)
+	if _, err := peer.WriteTo(req.Encode(), a.conn.LocalAddr()); err != nil {
+		t.Fatal(err)
+	}
+
+	// The requests are handled in order, so the first response and report
+	// are those of the authenticated request
+	resp := readResponse(t, peer)
+	if resp.Header.TransactionID != req.Header.TransactionID {
+		t.Fatalf("answered transaction %s, want %s", resp.Header.TransactionID, req.Header.TransactionID)
+	}
+	if err := NewIntegritySHA256(key).Check(resp); err != nil {
+		t.Fatalf("response integrity: %v", err)
+	}
+	if err := (FingerprintAttribute{}).Check(resp); err != nil {
+		t.Fatalf("response fingerprint: %v", err)
+	}
+	if got := <-reported; got != peer.LocalAddr().String() {
+		t.Fatalf("reported %s, want %s", got, peer.LocalAddr())
+	}
+	select {
+	case got := <-reported:
+		t.Fatalf("%s reported twice", got)
+	default:
+	}
+}
+
+func TestAgentMaxPeers(t *testing.T) {
+	cfg := AgentConfig{MaxPeers: 1, RespondToBinding: true}
+	reported := reportedPeers(&cfg)
+	a := newTestAgent(t, cfg)
+	first, second := listenUDP(t), listenUDP(t)
+
+	for _, peer := range []*net.UDPConn{first, second} {
+		if _, err := peer.WriteTo(NewBindingRequest().Encode(), a.conn.LocalAddr()); err != nil {
+			t.Fatal(err)
+		}
+		// Peers beyond the cap are still answered
+		readResponse(t, peer)
+	}
+	if got := <-reported; got != first.LocalAddr().String() {
+		t.Fatalf("reported %s, want %s", got, first.LocalAddr())
+	}
+	select {
+	case got := <-reported:
+		t.Fatalf("%s reported beyond MaxPeers", got)
+	default:
+	}
+	if a.KnownPeer(second.LocalAddr()) {
+		t.Fatalf("%s learned beyond MaxPeers", second.LocalAddr())
+	}
+}
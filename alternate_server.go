@@ -0,0 +1,83 @@
+package stun
+
+// MaxAlternateDomainLength is the maximum size in bytes of an
+// ALTERNATE-DOMAIN value (RFC 8489 §14.16).
+const MaxAlternateDomainLength = 255
+
+// AlternateServerAddr is the value of an ALTERNATE-SERVER attribute
+// (RFC 8489 §14.15): the address of the server a client is redirected to by
+// a 300 (Try Alternate) error response.
+type AlternateServerAddr MappedAddr
+
+// AddTo appends the address to m as an ALTERNATE-SERVER attribute.
+func (a AlternateServerAddr) AddTo(m *Message) error {
+	return AddressAttribute{Type: AlternateServer}.Add(m, MappedAddr(a))
+}
+
+// GetFrom decodes the ALTERNATE-SERVER attribute of m into a.
+//
+// Returns ErrAttrNotFound if m has no ALTERNATE-SERVER attribute.
+func (a *AlternateServerAddr) GetFrom(m *Message) error {
+	return getAddr(m, AddressAttribute{Type: AlternateServer}, (*MappedAddr)(a))
+}
+
+// alternateDomainText is the text codec of ALTERNATE-DOMAIN.
+var alternateDomainText = TextAttribute{Type: AlternateDomain, MaxBytes: MaxAlternateDomainLength}
+
+// AlternateDomainAttribute is the value of an ALTERNATE-DOMAIN attribute
+// (RFC 8489 §14.16): the domain name of the alternate server, sent with
+// ALTERNATE-SERVER when the request arrived over TLS or DTLS so that the
+// client can validate the certificate of the server it is redirected to.
+type AlternateDomainAttribute string
+
+// AddTo appends the domain to m as an ALTERNATE-DOMAIN attribute.
+//
+// Returns a *TextLengthError, matching ErrAttrTooLong, if the domain exceeds
+// MaxAlternateDomainLength bytes.
+func (d AlternateDomainAttribute) AddTo(m *Message) error {
+	return alternateDomainText.Add(m, string(d))
+}
+
+// GetFrom decodes the ALTERNATE-DOMAIN attribute of m into d.
+//
+// Returns ErrAttrNotFound if m has no ALTERNATE-DOMAIN attribute, and the
+// errors of AddTo if the received value is invalid.
+func (d *AlternateDomainAttribute) GetFrom(m *Message) error {
+	v, err := alternateDomainText.Get(m)
+	if err != nil {
+		return err
+	}
+	*d = AlternateDomainAttribute(v)
+	return nil
+}
+
+// AlternateTarget returns where a 300 (Try Alternate) response redirects the
+// client, and the name to validate the certificate of that server against
+// when the original request was sent over TLS or DTLS to domain: the
+// ALTERNATE-DOMAIN of the response if any, domain otherwise (RFC 8489 §10).
+//
+// Returns ErrAttrNotFound if resp has no ALTERNATE-SERVER attribute.
+//
+// Example:
+//
+//	addr, serverName, err := stun.AlternateTarget(resp, "stun.example.org")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	conn, err := tls.Dial("tcp", net.JoinHostPort(addr.IP.String(),
+//		strconv.Itoa(int(addr.Port))), &tls.Config{ServerName: serverName})
+func AlternateTarget(resp *Message, domain string) (AlternateServerAddr, string, error) {
+	var addr AlternateServerAddr
+	if err := addr.GetFrom(resp); err != nil {
+		return addr, "", err
+	}
+	var alt AlternateDomainAttribute
+	switch err := alt.GetFrom(resp); err {
+	case nil:
+		return addr, string(alt), nil
+	case ErrAttrNotFound:
+		return addr, domain, nil
+	default:
+		return addr, "", err
+	}
+}
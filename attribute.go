@@ -5,12 +5,24 @@ type Attribute struct {
 	Length       uint16        // Length of the attribute value
 	Type         StunAttribute // Type of the attribute (e.g., MAPPED-ADDRESS, USERNAME)
 	PaddedLength int           // Length of the attribute value after padding (must be a multiple of 4)
-	Value        []byte        // The value of the attribute (could be IP address, username, etc.)
+	Value        []byte        // The value of the attribute, exactly Length bytes without padding
+
+	// padding holds the padding bytes of a decoded attribute when they are
+	// not all zero, for the integrity checks and Message.EncodeRaw to
+	// reproduce the bytes received.
+	padding []byte
 }
 
-// DecodeStunAttr decodes a single STUN attribute from the given byte buffer.
-// The STUN attribute format is as follows:
-func DecodeAttr(buff []byte) Attribute {
+// DecodeAttr decodes a single STUN attribute from the given byte buffer,
+// which starts with the 4-byte type and length header of the attribute.
+//
+// Returns ErrShortBuffer if buff is too short for the header, the value or
+// its padding.
+func DecodeAttr(buff []byte) (Attribute, error) {
+	if len(buff) < 4 {
+		return Attribute{}, ErrShortBuffer
+	}
+
 	// Extract the attribute type (first 2 bytes)
 	attrType := StunAttribute(uint16(buff[0])<<8 | uint16(buff[1]))
 
@@ -19,19 +31,36 @@ func DecodeAttr(buff []byte) Attribute {
 
 	// Calculate the padded length of the attribute value
 	// STUN attributes are padded to a multiple of 4 bytes
-	paddedLen := int(attrLen)
-	if paddedLen%4 != 0 {
-		paddedLen = paddedLen + 4 - (paddedLen % 4)
+	paddedLen := paddedLength(int(attrLen))
+	if len(buff) < 4+paddedLen {
+		return Attribute{}, ErrShortBuffer
 	}
 
-	return Attribute{
+	// The padding is skipped: Value holds the Length bytes of the value only,
+	// so that text attributes such as SOFTWARE or NONCE carry no trailing
+	// padding bytes
+	attr := Attribute{
 		Type:         attrType,
 		Length:       attrLen,
-		Value:        buff[4 : 4+paddedLen],
+		Value:        buff[4 : 4+int(attrLen)],
 		PaddedLength: paddedLen,
 	}
+
+	// Senders may pad with any bytes (RFC 5389 §15). Non-zero padding is
+	// kept since MESSAGE-INTEGRITY and FINGERPRINT cover it, and so that
+	// Message.EncodeRaw can forward the attribute byte for byte.
+	for _, b := range buff[4+int(attrLen) : 4+paddedLen] {
+		if b != 0 {
+			attr.padding = buff[4+int(attrLen) : 4+paddedLen]
+			break
+		}
+	}
+	return attr, nil
 }
 
+// Encode converts the attribute to its binary representation: the 4-byte
+// type and length header followed by the value, padded to PaddedLength with
+// zeros, whatever padding the attribute was decoded with.
 func (a *Attribute) Encode() []byte {
 	// Calculate the total buffer size: 4 bytes header (type + length) + padded value length
 	buff := make([]byte, 4+a.PaddedLength)
@@ -44,8 +73,64 @@ func (a *Attribute) Encode() []byte {
 	buff[2] = byte(a.Length >> 8)   // High byte
 	buff[3] = byte(a.Length & 0xFF) // Low byte
 
-	// Copy the value into the buffer
-	copy(buff[4:], a.Value)
+	// Copy the value into the buffer. Only Length bytes are copied so that the
+	// padding is zero, even if Value was set longer than Length
+	copy(buff[4:], a.rawValue())
 
 	return buff
 }
+
+// appendTo appends the encoded attribute to buff, in the same format as
+// Encode, with the padding bytes it was decoded with if raw is set.
+func (a *Attribute) appendTo(buff []byte, raw bool) []byte {
+	buff = append(buff,
+		byte(a.Type>>8), byte(a.Type&0xFF),
+		byte(a.Length>>8), byte(a.Length&0xFF),
+	)
+	start := len(buff)
+	buff = append(buff, make([]byte, a.PaddedLength)...)
+	copy(buff[start:], a.rawValue())
+	if raw {
+		a.copyPadding(buff[start:])
+	}
+	return buff
+}
+
+// copyPadding writes the original padding of a decoded attribute after the
+// value in dst, which holds the PaddedLength bytes of the encoded value. It
+// does nothing for zero padding, or if the length of the attribute changed
+// since decoding.
+func (a *Attribute) copyPadding(dst []byte) {
+	if len(a.padding) == 0 || int(a.Length)+len(a.padding) != a.PaddedLength {
+		return
+	}
+	copy(dst[a.Length:], a.padding)
+}
+
+// newAttr builds an attribute of the given type around value, computing its
+// length and padded length.
+func newAttr(t StunAttribute, value []byte) Attribute {
+	return Attribute{
+		Type:         t,
+		Length:       uint16(len(value)),
+		PaddedLength: paddedLength(len(value)),
+		Value:        value,
+	}
+}
+
+// paddedLength rounds n up to the next multiple of 4 bytes.
+func paddedLength(n int) int {
+	if n%4 != 0 {
+		n = n + 4 - (n % 4)
+	}
+	return n
+}
+
+// rawValue returns the attribute value, bounded by Length in case Value was
+// set longer by hand.
+func (a *Attribute) rawValue() []byte {
+	if int(a.Length) <= len(a.Value) {
+		return a.Value[:a.Length]
+	}
+	return a.Value
+}
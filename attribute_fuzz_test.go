@@ -0,0 +1,61 @@
+package stun_test
+
+import (
+	"testing"
+
+	"github.com/lai0xn/stun"
+)
+
+// getter is implemented by the attribute types of the package.
+type getter interface {
+	GetFrom(m *stun.Message) error
+}
+
+func FuzzGetFrom(f *testing.F) {
+	f.Fuzz(func(t *testing.T, data []byte) {
+		m, err := stun.NewMessage(data)
+		if err != nil {
+			return
+		}
+		for _, g := range []getter{
+			new(stun.AccessTokenAttribute),
+			new(stun.ThirdPartyAuthorizationAttribute),
+			new(stun.AlternateServerAddr),
+			new(stun.AlternateDomainAttribute),
+			new(stun.Capabilities),
+			new(stun.ChangeRequestAttribute),
+			new(stun.SourceAddr),
+			new(stun.ChangedAddr),
+			new(stun.ErrorCodeAttribute),
+			new(stun.UnknownAttributes),
+			new(stun.PriorityAttribute),
+			new(stun.ICEControllingAttribute),
+			new(stun.ICEControlledAttribute),
+			new(stun.MappedAddr),
+			new(stun.OtherAddr),
+			new(stun.PasswordAlgorithmAttribute),
+			new(stun.PasswordAlgorithmsAttribute),
+			new(stun.RealmAttribute),
+			new(stun.NonceAttribute),
+			new(stun.ResponseOriginAddr),
+			new(stun.SoftwareAttribute),
+			new(stun.ChannelNumberAttribute),
+			new(stun.LifetimeAttribute),
+			new(stun.XorPeerAddr),
+			new(stun.XorRelayedAddr),
+			new(stun.DataAttribute),
+			new(stun.RequestedTransportAttribute),
+			new(stun.EvenPortAttribute),
+			new(stun.ReservationTokenAttribute),
+			new(stun.MobilityTicketAttribute),
+			new(stun.UsernameAttribute),
+			new(stun.XorMappedAddr),
+		} {
+			_ = g.GetFrom(m)
+		}
+		_, _ = m.GetUserHash()
+		_, _ = m.GetMappedAddr()
+		_ = stun.FingerprintAttribute{}.Check(m)
+		_ = stun.NewShortTermIntegrity("password").Check(m)
+	})
+}
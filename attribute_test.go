@@ -0,0 +1,60 @@
+package stun
+
+import (
+	"bytes"
+	"testing"
+)
+
+// checkZeroPadding fails the test unless the padding of every attribute of
+// the encoded message enc is zero.
+func checkZeroPadding(t *testing.T, enc []byte) {
+	t.Helper()
+	for off := headrLength; off+4 <= len(enc); {
+		length := int(enc[off+2])<<8 | int(enc[off+3])
+		end := off + 4 + paddedLength(length)
+		for _, b := range enc[off+4+length : end] {
+			if b != 0 {
+				t.Fatalf("attribute at offset %d has padding %x in %x", off, enc[off+4+length:end], enc)
+			}
+		}
+		off = end
+	}
+}
+
+func TestAttributeEncodeZeroPadding(t *testing.T) {
+	// Value longer than Length, as when it is sliced from a larger buffer
+	a := Attribute{Type: Software, Length: 3, PaddedLength: 4, Value: []byte("abcd")}
+	if got, want := a.Encode(), []byte{0x80, 0x22, 0x00, 0x03, 'a', 'b', 'c', 0}; !bytes.Equal(got, want) {
+		t.Fatalf("Encode = %x, want %x", got, want)
+	}
+
+	decoded, err := DecodeAttr([]byte{0x80, 0x22, 0x00, 0x03, 'a', 'b', 'c', 0xff})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := decoded.Encode(), []byte{0x80, 0x22, 0x00, 0x03, 'a', 'b', 'c', 0}; !bytes.Equal(got, want) {
+		t.Fatalf("Encode of a decoded attribute = %x, want %x", got, want)
+	}
+}
+
+func TestMessageEncodeZeroPadding(t *testing.T) {
+	m, err := NewMessage(garbagePadded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkZeroPadding(t, m.Encode())
+
+	// Into a reused buffer holding garbage
+	dirty := bytes.Repeat([]byte{0xff}, 128)
+	checkZeroPadding(t, m.AppendTo(dirty[:0]))
+
+	// Through the decode, encode path of a forwarding hop
+	decoded, err := NewMessage(m.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkZeroPadding(t, decoded.Encode())
+	if !decoded.Equal(m) {
+		t.Fatal("zero padding changed the attribute values")
+	}
+}
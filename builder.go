@@ -0,0 +1,146 @@
+package stun
+
+// NewBindingRequest returns a Binding request with the magic cookie and a
+// new random transaction ID.
+//
+// Example:
+//
+//	req := stun.NewBindingRequest()
+//	if err := req.Add(stun.Software, []byte("example/1.0")); err != nil {
+//		log.Fatal(err)
+//	}
+func NewBindingRequest() *Message {
+	return &Message{Header: Header{
+		Type:          BindingRequest,
+		MagicCookie:   magicCookie,
+		TransactionID: NewTransactionID(),
+	}}
+}
+
+// NewBindingSuccess returns a Binding success response with the magic cookie
+// to the transaction txID, for attributes to be added to.
+//
+// Example:
+//
+//	resp := stun.NewBindingSuccess(req.Header.TransactionID)
+//	if err := stun.FromUDPAddr(remote).AddTo(resp); err != nil {
+//		return nil, err
+//	}
+func NewBindingSuccess(txID TransactionID) *Message {
+	return &Message{Header: Header{
+		Type:          BindingResponse,
+		MagicCookie:   magicCookie,
+		TransactionID: txID,
+	}}
+}
+
+// MessageBuilder assembles a message step by step: the header is filled in
+// with the magic cookie and a random transaction ID, Header.Length follows
+// the attributes, and the first error is reported by Build.
+//
+// The transaction ID of XOR-* attributes is the one of the message when they
+// are added, so TransactionID must be called before adding them.
+//
+// Example:
+//
+//	msg, err := stun.NewMessageBuilder(stun.BindingRequest).
+//		Add(stun.Software, []byte("example/1.0")).
+//		Add(stun.Priority, []byte{0x6e, 0x00, 0x01, 0xff}).
+//		Build()
+type MessageBuilder struct {
+	msg *Message
+	err error
+}
+
+// NewMessageBuilder returns a builder of a message of type t.
+func NewMessageBuilder(t MessageType) *MessageBuilder {
+	return &MessageBuilder{msg: &Message{Header: Header{
+		Type:          t,
+		MagicCookie:   magicCookie,
+		TransactionID: NewTransactionID(),
+	}}}
+}
+
+// TransactionID sets the transaction ID of the message, e.g. that of the
+// request a response is built for.
+func (b *MessageBuilder) TransactionID(id TransactionID) *MessageBuilder {
+	b.msg.Header.TransactionID = id
+	return b
+}
+
+// Add appends an attribute of type t with the given value.
+func (b *MessageBuilder) Add(t StunAttribute, value []byte) *MessageBuilder {
+	if b.err != nil {
+		return b
+	}
+	b.err = b.msg.Add(t, value)
+	return b
+}
+
+// Build returns the message, or the first error met while building it.
+func (b *MessageBuilder) Build() (*Message, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	return b.msg, nil
+}
+
+// Setter is a part of a message applied by Build: a message type, a
+// transaction ID, or an attribute appended to the message. Every attribute
+// type of the package implements it.
+type Setter interface {
+	AddTo(m *Message) error
+}
+
+// SetterFunc adapts a function to the Setter interface.
+type SetterFunc func(m *Message) error
+
+// AddTo calls f(m).
+func (f SetterFunc) AddTo(m *Message) error {
+	return f(m)
+}
+
+// AddTo sets the message type of m, making message types setters.
+func (t MessageType) AddTo(m *Message) error {
+	m.Header.Type = t
+	return nil
+}
+
+// Build resets m to an empty message with the magic cookie and a new random
+// transaction ID, then applies the setters in order, stopping at the first
+// error. The attribute slice of m is reused. Setters depending on the
+// attributes before them, such as MESSAGE-INTEGRITY and FINGERPRINT, must
+// come last.
+//
+// Example:
+//
+//	msg := new(stun.Message)
+//	err := stun.Build(msg, stun.BindingRequest,
+//		stun.NewUsername("alice:bob"),
+//		stun.NewShortTermIntegrity("secret"),
+//		stun.FingerprintAttribute{},
+//	)
+func Build(m *Message, setters ...Setter) error {
+	m.Header = Header{
+		MagicCookie:   magicCookie,
+		TransactionID: NewTransactionID(),
+	}
+	m.Attributes = m.Attributes[:0]
+	for _, s := range setters {
+		if err := s.AddTo(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Set applies the setters to the message in order (see Build).
+func (b *MessageBuilder) Set(setters ...Setter) *MessageBuilder {
+	for _, s := range setters {
+		if b.err != nil {
+			break
+		}
+		b.err = s.AddTo(b.msg)
+	}
+	return b
+}
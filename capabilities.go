@@ -0,0 +1,87 @@
+package stun
+
+import "encoding/binary"
+
+// Capability flags carried in the CAPABILITIES attribute
+const (
+	capNATBehaviorDiscovery uint32 = 1 << iota
+	capTURN
+	capTLS
+)
+
+// CapabilitiesLength is the size of the CAPABILITIES attribute value
+const CapabilitiesLength = 8
+
+// Capabilities is the value of the vendor-specific CAPABILITIES attribute,
+// with which a server advertises the features it supports so that clients
+// can discover them in a single round trip. Since the attribute lives in the
+// comprehension-optional range, other implementations simply ignore it.
+//
+//	 0                   1                   2                   3
+//	 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1
+//	+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+//	|                          Feature flags                  |L|T|N|
+//	+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+//	|           TLS Port            |           Reserved            |
+//	+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+//
+// N: NAT behavior discovery, T: TURN, L: TLS port present.
+type Capabilities struct {
+	// NATBehaviorDiscovery reports RFC 5780 support: the server has an
+	// alternate address and honors CHANGE-REQUEST.
+	NATBehaviorDiscovery bool
+	// TURN reports that the server also acts as a TURN relay.
+	TURN bool
+	// TLSPort is the port STUN over TLS is served on, 0 if not available.
+	TLSPort uint16
+}
+
+// AddTo appends the capabilities to m as a CAPABILITIES attribute.
+func (c Capabilities) AddTo(m *Message) error {
+	var flags uint32
+	if c.NATBehaviorDiscovery {
+		flags |= capNATBehaviorDiscovery
+	}
+	if c.TURN {
+		flags |= capTURN
+	}
+	if c.TLSPort != 0 {
+		flags |= capTLS
+	}
+
+	value := make([]byte, CapabilitiesLength)
+	binary.BigEndian.PutUint32(value[0:4], flags)
+	binary.BigEndian.PutUint16(value[4:6], c.TLSPort)
+	return m.Add(CapabilitiesAttr, value)
+}
+
+// GetFrom decodes the CAPABILITIES attribute of m into c. Unknown flags are
+// ignored so that newer servers remain readable.
+//
+// Returns ErrAttrNotFound if m has no CAPABILITIES attribute, which is the
+// case for servers that do not advertise their features.
+//
+// Example:
+//
+//	var caps stun.Capabilities
+//	if err := caps.GetFrom(resp); err == nil && caps.NATBehaviorDiscovery {
+//		// Run RFC 5780 NAT behavior discovery against this server
+//	}
+func (c *Capabilities) GetFrom(m *Message) error {
+	attr, ok := m.GetAttr(CapabilitiesAttr)
+	if !ok {
+		return ErrAttrNotFound
+	}
+	value := attr.rawValue()
+	if len(value) < CapabilitiesLength {
+		return ErrShortBuffer
+	}
+	flags := binary.BigEndian.Uint32(value[0:4])
+	c.NATBehaviorDiscovery = flags&capNATBehaviorDiscovery != 0
+	c.TURN = flags&capTURN != 0
+	c.TLSPort = 0
+	if flags&capTLS != 0 {
+		c.TLSPort = binary.BigEndian.Uint16(value[4:6])
+	}
+	return nil
+}
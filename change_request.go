@@ -0,0 +1,65 @@
+package stun
+
+// CHANGE-REQUEST flags (RFC 5780 §7.2)
+const (
+	changeIPFlag   = 0x04
+	changePortFlag = 0x02
+)
+
+// ChangeRequestAttribute is the value of a CHANGE-REQUEST attribute
+// (RFC 5780 §7.2). It asks the server to send the response from its
+// alternate IP address and/or port, which lets a client test the filtering
+// behavior of its NAT.
+//
+//	 0                   1                   2                   3
+//	 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1
+//	+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+//	|0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 A B 0|
+//	+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+type ChangeRequestAttribute struct {
+	ChangeIP   bool
+	ChangePort bool
+}
+
+// NewChangeRequest returns a Binding request asking the server to respond
+// from its alternate IP address and/or port.
+//
+// Example:
+//
+//	// Filtering behavior test II: response from the other IP and port
+//	msg, err := client.Dial(stun.NewChangeRequest(true, true))
+func NewChangeRequest(changeIP, changePort bool) *Message {
+	m := NewBindingRequest()
+	// AddTo never fails for CHANGE-REQUEST
+	_ = ChangeRequestAttribute{ChangeIP: changeIP, ChangePort: changePort}.AddTo(m)
+	return m
+}
+
+// AddTo appends the flags to m as a CHANGE-REQUEST attribute.
+func (c ChangeRequestAttribute) AddTo(m *Message) error {
+	value := make([]byte, 4)
+	if c.ChangeIP {
+		value[3] |= changeIPFlag
+	}
+	if c.ChangePort {
+		value[3] |= changePortFlag
+	}
+	return m.Add(ChangeRequest, value)
+}
+
+// GetFrom decodes the CHANGE-REQUEST attribute of m into c.
+//
+// Returns ErrAttrNotFound if m has no CHANGE-REQUEST attribute.
+func (c *ChangeRequestAttribute) GetFrom(m *Message) error {
+	attr, ok := m.GetAttr(ChangeRequest)
+	if !ok {
+		return ErrAttrNotFound
+	}
+	value := attr.rawValue()
+	if len(value) < 4 {
+		return ErrShortBuffer
+	}
+	c.ChangeIP = value[3]&changeIPFlag != 0
+	c.ChangePort = value[3]&changePortFlag != 0
+	return nil
+}
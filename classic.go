@@ -0,0 +1,85 @@
+package stun
+
+import "fmt"
+
+// SourceAddr is the value of a SOURCE-ADDRESS attribute (RFC 3489 §11.2.3):
+// the address and port a classic STUN server sent the response from.
+type SourceAddr MappedAddr
+
+// AddTo appends the address to m as a SOURCE-ADDRESS attribute.
+func (a SourceAddr) AddTo(m *Message) error {
+	return AddressAttribute{Type: SourceAddress}.Add(m, MappedAddr(a))
+}
+
+// GetFrom decodes the SOURCE-ADDRESS attribute of m into a.
+//
+// Returns ErrAttrNotFound if m has no SOURCE-ADDRESS attribute.
+func (a *SourceAddr) GetFrom(m *Message) error {
+	return getAddr(m, AddressAttribute{Type: SourceAddress}, (*MappedAddr)(a))
+}
+
+// ChangedAddr is the value of a CHANGED-ADDRESS attribute (RFC 3489
+// §11.2.3): the alternate address and port a classic STUN server answers
+// CHANGE-REQUEST from, which RFC 5780 renamed OTHER-ADDRESS.
+type ChangedAddr MappedAddr
+
+// AddTo appends the address to m as a CHANGED-ADDRESS attribute.
+func (a ChangedAddr) AddTo(m *Message) error {
+	return AddressAttribute{Type: ChangedAddress}.Add(m, MappedAddr(a))
+}
+
+// GetFrom decodes the CHANGED-ADDRESS attribute of m into a.
+//
+// Returns ErrAttrNotFound if m has no CHANGED-ADDRESS attribute.
+func (a *ChangedAddr) GetFrom(m *Message) error {
+	return getAddr(m, AddressAttribute{Type: ChangedAddress}, (*MappedAddr)(a))
+}
+
+// IsClassic reports whether the header lacks the magic cookie, as messages
+// of RFC 3489 clients and servers do: their transaction ID is 128 bits long,
+// its first 32 bits taking the place of the cookie.
+func (h Header) IsClassic() bool {
+	return h.MagicCookie != magicCookie
+}
+
+// CookieError is the error of NewMessage for a message without the magic
+// cookie. It wraps ErrInvalidCookie, and carries the field received instead
+// so that the caller can tell an RFC 3489 peer from garbage and either drop
+// the message or parse it again with NewClassicMessage.
+//
+// Example:
+//
+//	msg, err := stun.NewMessage(buf)
+//	var cookieErr *stun.CookieError
+//	if errors.As(err, &cookieErr) {
+//		msg, err = stun.NewClassicMessage(buf) // downgrade
+//	}
+type CookieError struct {
+	Cookie uint32
+}
+
+// Error implements the error interface.
+func (e *CookieError) Error() string {
+	return fmt.Sprintf("%v: 0x%08x", ErrInvalidCookie, e.Cookie)
+}
+
+// Unwrap returns ErrInvalidCookie.
+func (e *CookieError) Unwrap() error {
+	return ErrInvalidCookie
+}
+
+// NewClassicMessage parses buff like NewMessage, but also accepts the
+// messages of RFC 3489 implementations, which do not carry the magic cookie
+// (see Header.IsClassic).
+func NewClassicMessage(buff []byte) (*Message, error) {
+	return decodeMessage(buff, true)
+}
+
+// responseCookie returns the magic cookie field of the responses to req: the
+// one of req, which is the head of the transaction ID of classic requests.
+func responseCookie(req *Message) uint32 {
+	if req.Header.MagicCookie == 0 {
+		return magicCookie
+	}
+	return req.Header.MagicCookie
+}
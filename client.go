@@ -1,7 +1,13 @@
 package stun
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net"
+	"os"
+	"sync/atomic"
+	"time"
 )
 
 // Client represents a STUN client that can send binding requests to STUN servers
@@ -16,20 +22,50 @@ import (
 // Example:
 //
 //	client := stun.NewClient("stun.l.google.com:19302")
-//	msg, err := client.Dial(&stun.Message{
-//		Header: stun.Header{Type: stun.BindingRequest},
-//	})
+//	msg, err := client.Dial(stun.NewBindingRequest())
 type Client struct {
 	ServerAddr string
-	logger     *Logger
+	// UseUserHash makes Dial replace the USERNAME attribute of requests that
+	// also carry a REALM with the USERHASH attribute (RFC 8489 §14.4), so
+	// that the username is not sent in cleartext. Message integrity must be
+	// computed afterwards.
+	UseUserHash bool
+	// FixAttrOrder makes Dial move misplaced MESSAGE-INTEGRITY,
+	// MESSAGE-INTEGRITY-SHA256 and FINGERPRINT attributes to the end of
+	// requests (see FixAttrOrder) instead of failing with ErrAttrOrder.
+	FixAttrOrder bool
+	// ClassicSTUN makes Dial accept responses without the magic cookie from
+	// RFC 3489 servers (see NewClassicMessage), which report the mapped
+	// address in MAPPED-ADDRESS (see Message.GetMappedAddr) and their
+	// alternate address in CHANGED-ADDRESS.
+	ClassicSTUN bool
+	// MaxMessageSize is the size of the largest response Dial reads over
+	// UDP. Larger ones fail with an error wrapping ErrMessageTruncated. Zero
+	// selects the MaxMessageSize of the decode limits (see SetDecodeLimits).
+	MaxMessageSize int
+	logger         *Logger
+	conn           net.PacketConn
+	transport      Transport
+	// other is the alternate address of the server, learned from the
+	// OTHER-ADDRESS or CHANGED-ADDRESS of its responses, from which the
+	// responses to CHANGE-REQUEST are accepted.
+	other atomic.Pointer[otherAddr]
+}
+
+// otherAddr is the alternate address of a server.
+type otherAddr struct {
+	server *net.UDPAddr
+	addr   *net.UDPAddr
 }
 
 // NewClient creates a new STUN client with the specified server address.
-// The server address should be in the format "host:port".
+// The server address should be in the format "host:port", the port
+// defaulting to 3478, or a "stun:" URI (see ParseURI).
 //
 // Example:
 //
 //	client := stun.NewClient("stun.l.google.com:19302")
+//	client = stun.NewClient("stun:stun.l.google.com:19302")
 func NewClient(addr string) *Client {
 	return &Client{
 		ServerAddr: addr,
@@ -54,6 +90,51 @@ func NewClientWithLogger(addr string, logger *Logger) *Client {
 	}
 }
 
+// NewClientWithConn creates a new STUN client that sends its requests over
+// conn instead of opening a new UDP socket for every transaction, e.g. to
+// share a socket with other protocols or to run behind a simulated NAT.
+// The connection is not closed by the client. A nil logger selects the
+// default logger.
+//
+// Example:
+//
+//	conn, err := net.ListenPacket("udp4", ":0")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	client := stun.NewClientWithConn("stun.l.google.com:19302", conn, nil)
+func NewClientWithConn(addr string, conn net.PacketConn, logger *Logger) *Client {
+	if logger == nil {
+		logger = NewDefaultLogger()
+	}
+	return &Client{
+		ServerAddr: addr,
+		logger:     logger,
+		conn:       conn,
+	}
+}
+
+// NewClientWithTransport creates a new STUN client that exchanges its
+// messages through t instead of UDP, e.g. over HTTP or a WebSocket to a relay
+// on platforms without UDP sockets such as browsers (GOOS=js). addr only
+// names the server in logs. A nil logger selects the default logger.
+//
+// Example:
+//
+//	client := stun.NewClientWithTransport("relay", &stun.HTTPTransport{
+//		URL: "https://relay.example.com/stun",
+//	}, nil)
+func NewClientWithTransport(addr string, t Transport, logger *Logger) *Client {
+	if logger == nil {
+		logger = NewDefaultLogger()
+	}
+	return &Client{
+		ServerAddr: addr,
+		logger:     logger,
+		transport:  t,
+	}
+}
+
 // Dial sends a STUN binding request to the server and returns the response.
 // The method performs the complete STUN transaction:
 //   - Resolves the server address
@@ -83,66 +164,368 @@ func NewClientWithLogger(addr string, logger *Logger) *Client {
 //		log.Fatal(err)
 //	}
 //	fmt.Printf("Public IP: %s:%d\n", xorAddr.IP, xorAddr.Port)
+//
+// Dial waits for the response as long as it takes; use DialContext to bound
+// the transaction.
 func (client *Client) Dial(m *Message) (*Message, error) {
-	udpAddr, err := net.ResolveUDPAddr("udp4", client.ServerAddr)
-	if err != nil {
-		client.logger.LogError("Failed to resolve server address", err, map[string]interface{}{
-			"server_addr": client.ServerAddr,
-		})
+	return client.DialContext(context.Background(), m)
+}
+
+// DialContext is Dial honoring the cancellation and deadline of ctx while
+// resolving the server address, opening the socket, writing the request and
+// reading the response. If ctx is done first, it returns ctx.Err().
+//
+// Over UDP, datagrams that are not a response to m, or that come from
+// another address than the server, are dropped and the wait goes on, so
+// that off-path hosts cannot inject responses. The responses to a request
+// carrying CHANGE-REQUEST are accepted from the alternate address of the
+// server, learned from the OTHER-ADDRESS or CHANGED-ADDRESS of a previous
+// response; until one is known, any address differing from the server only
+// in the part the request asks to change is accepted.
+//
+// The deadlines of a connection shared with NewClientWithConn are set for
+// the duration of the transaction and cleared afterwards. Transports are
+// given ctx if they implement ContextTransport; other ones are abandoned
+// to finish their round trip in the background.
+//
+// Example:
+//
+//	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+//	defer cancel()
+//	msg, err := client.DialContext(ctx, stun.NewBindingRequest())
+//	if err != nil {
+//		log.Fatal(err) // context.DeadlineExceeded if the server never answered
+//	}
+func (client *Client) DialContext(ctx context.Context, m *Message) (*Message, error) {
+	if err := client.prepare(m); err != nil {
 		return nil, err
 	}
-
-	m.Header.MagicCookie = magicCookie
-	m.Header.Length = uint16(len(m.Attributes))
-	m.Header.TransactionID = [12]byte(randomTransactionID())
+	tlog := loggerWithTransaction(client.logger, m.Header.TransactionID, client.ServerAddr)
 
 	// Log the request being sent
 	client.logger.LogClientRequest(client.ServerAddr, m.Header.Type, m.Header.TransactionID)
 
-	encodedHeader := m.Header.Encode()
+	encodedMsg := m.Encode()
 
-	c, err := net.DialUDP("udp4", nil, udpAddr)
+	var msg *Message
+	var err error
+	if client.transport != nil {
+		msg, err = client.roundTripTransport(ctx, m, encodedMsg, tlog)
+	} else {
+		msg, err = client.roundTripUDP(ctx, m, encodedMsg, tlog)
+	}
 	if err != nil {
-		client.logger.LogError("Failed to dial UDP connection", err, map[string]interface{}{
-			"server_addr": client.ServerAddr,
-		})
 		return nil, err
 	}
-	defer c.Close()
 
-	client.logger.LogConnection(c.LocalAddr().String(), udpAddr.String(), "stun_client")
+	// Get XOR mapped address for logging
+	xorAddr, _ := msg.GetXorAddr()
+	client.logger.LogClientResponse(client.ServerAddr, msg.Header.Type, xorAddr)
+
+	return msg, nil
+}
 
-	_, err = c.Write(encodedHeader)
+// Indicate sends the indication m, such as a Binding indication keeping the
+// NAT binding of the client alive (RFC 5389 §10), without waiting for any
+// response. Its header is completed as by Dial.
+//
+// Returns ErrIndicationUnsupported for clients using a Transport.
+//
+// Example:
+//
+//	err := client.Indicate(&stun.Message{
+//		Header: stun.Header{Type: stun.BindingIndication},
+//	})
+func (client *Client) Indicate(m *Message) error {
+	if client.transport != nil {
+		return ErrIndicationUnsupported
+	}
+	if err := client.prepare(m); err != nil {
+		return err
+	}
+	tlog := loggerWithTransaction(client.logger, m.Header.TransactionID, client.ServerAddr)
+	client.logger.LogClientRequest(client.ServerAddr, m.Header.Type, m.Header.TransactionID)
+
+	udpAddr, err := client.resolveServer(context.Background())
 	if err != nil {
-		client.logger.LogError("Failed to write request to server", err, map[string]interface{}{
-			"server_addr":    client.ServerAddr,
-			"transaction_id": m.Header.TransactionID,
-		})
+		tlog.LogError("Failed to resolve server address", err)
+		return err
+	}
+	c := client.conn
+	if c == nil {
+		udpConn, err := net.ListenUDP(udpNetwork(udpAddr.IP), nil)
+		if err != nil {
+			tlog.LogError("Failed to dial UDP connection", err)
+			return err
+		}
+		defer udpConn.Close()
+		c = udpConn
+	}
+	if _, err := c.WriteTo(m.Encode(), udpAddr); err != nil {
+		tlog.LogError("Failed to write indication to server", err)
+		return err
+	}
+	return nil
+}
+
+// prepare applies the options of the client to the outgoing message m and
+// completes its header with the magic cookie and a new transaction ID, the
+// length being set by Encode.
+func (client *Client) prepare(m *Message) error {
+	if client.UseUserHash {
+		m.useUserHash()
+	}
+	if client.FixAttrOrder {
+		FixAttrOrder(m)
+	}
+	if err := m.CheckAttrOrder(); err != nil {
+		return err
+	}
+	m.Header.MagicCookie = magicCookie
+	m.Header.TransactionID = NewTransactionID()
+	return nil
+}
+
+// roundTripTransport exchanges the encoded request req through the transport
+// of the client and returns the response to it, until ctx is done, logging
+// failures to tlog.
+func (client *Client) roundTripTransport(ctx context.Context, req *Message, encodedMsg []byte, tlog *txLogger) (*Message, error) {
+	buff, err := client.exchange(ctx, encodedMsg)
+	if err != nil {
+		tlog.LogError("Failed to exchange request with server", err)
+		return nil, err
+	}
+	msg, err := decodeMessage(buff, client.ClassicSTUN)
+	if err == nil && !isResponseTo(msg, req) {
+		err = ErrUnexpectedResponse
+	}
+	if err != nil {
+		tlog.LogError("Failed to parse response message", err)
 		return nil, err
 	}
+	return msg, nil
+}
+
+// exchange sends the encoded request through the transport of the client
+// and returns the encoded response, until ctx is done.
+func (client *Client) exchange(ctx context.Context, req []byte) ([]byte, error) {
+	if t, ok := client.transport.(ContextTransport); ok {
+		return t.RoundTripContext(ctx, req)
+	}
+	if ctx.Done() == nil {
+		return client.transport.RoundTrip(req)
+	}
+	type result struct {
+		buff []byte
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		buff, err := client.transport.RoundTrip(req)
+		done <- result{buff, err}
+	}()
+	select {
+	case r := <-done:
+		return r.buff, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
 
-	buff := make([]byte, 2048)
-	_, _, err = c.ReadFromUDP(buff)
+// roundTripUDP sends the encoded request req to the server over UDP and
+// returns the response to it, until ctx is done, logging failures to tlog.
+func (client *Client) roundTripUDP(ctx context.Context, req *Message, encodedMsg []byte, tlog *txLogger) (*Message, error) {
+	udpAddr, err := client.resolveServer(ctx)
 	if err != nil {
-		client.logger.LogError("Failed to read response from server", err, map[string]interface{}{
-			"server_addr":    client.ServerAddr,
-			"transaction_id": m.Header.TransactionID,
-		})
+		err = contextError(ctx, err)
+		tlog.LogError("Failed to resolve server address", err)
 		return nil, err
 	}
 
-	msg, err := NewMessage(buff)
+	c := client.conn
+	if c == nil {
+		// The socket is left unconnected so that responses sent from the
+		// alternate address of the server (CHANGE-REQUEST) are received as well
+		var lc net.ListenConfig
+		udpConn, err := lc.ListenPacket(ctx, udpNetwork(udpAddr.IP), "")
+		if err != nil {
+			tlog.LogError("Failed to dial UDP connection", err)
+			return nil, err
+		}
+		defer udpConn.Close()
+		c = udpConn
+	}
+	defer watchContext(ctx, c)()
+
+	client.logger.LogConnection(c.LocalAddr().String(), udpAddr.String(), "stun_client")
+
+	_, err = c.WriteTo(encodedMsg, udpAddr)
 	if err != nil {
-		client.logger.LogError("Failed to parse response message", err, map[string]interface{}{
-			"server_addr":    client.ServerAddr,
-			"transaction_id": m.Header.TransactionID,
-		})
+		err = contextError(ctx, err)
+		tlog.LogError("Failed to write request to server", err)
 		return nil, err
 	}
 
-	// Get XOR mapped address for logging
-	xorAddr, _ := msg.GetXorAddr()
-	client.logger.LogClientResponse(client.ServerAddr, msg.Header.Type, xorAddr)
+	maxSize := readSize(client.MaxMessageSize)
+	buff := make([]byte, maxSize+1)
+	for {
+		n, from, err := c.ReadFrom(buff)
+		if err != nil {
+			err = contextError(ctx, err)
+			tlog.LogError("Failed to read response from server", err)
+			return nil, err
+		}
+		if !client.isResponseSource(from, udpAddr, req) {
+			tlog.Debug("Dropping datagram from unexpected source", map[string]interface{}{"source": from.String()})
+			continue
+		}
+		if err := checkRead(n, maxSize); err != nil {
+			tlog.LogError("Failed to read response from server", err)
+			return nil, err
+		}
+		msg, err := decodeMessage(buff[:n], client.ClassicSTUN)
+		if err != nil || !isResponseTo(msg, req) {
+			tlog.Debug("Dropping datagram not answering the request", map[string]interface{}{"source": from.String()})
+			continue
+		}
+		if sameUDPAddr(from, udpAddr) {
+			client.learnOtherAddr(udpAddr, msg)
+		}
+		return msg, nil
+	}
+}
 
-	return msg, nil
+// isResponseTo reports whether msg is a success or error response to req.
+func isResponseTo(msg, req *Message) bool {
+	return msg.IsSuccessResponseFor(req) || msg.IsErrorResponseFor(req)
+}
+
+// isResponseSource reports whether a response to req may come from the
+// address from: the server, or for a CHANGE-REQUEST its alternate address.
+func (client *Client) isResponseSource(from net.Addr, server *net.UDPAddr, req *Message) bool {
+	addr, ok := from.(*net.UDPAddr)
+	if !ok {
+		return false
+	}
+	var change ChangeRequestAttribute
+	if change.GetFrom(req) != nil {
+		return sameUDPAddr(addr, server)
+	}
+	var other *net.UDPAddr
+	if o := client.other.Load(); o != nil && sameUDPAddr(o.server, server) {
+		other = o.addr
+	}
+	ipOK := addr.IP.Equal(server.IP)
+	if change.ChangeIP {
+		ipOK = other == nil || addr.IP.Equal(other.IP)
+	}
+	portOK := addr.Port == server.Port
+	if change.ChangePort {
+		portOK = other == nil || addr.Port == other.Port
+	}
+	return ipOK && portOK
+}
+
+// learnOtherAddr records the alternate address of server reported by its
+// response msg, if any.
+func (client *Client) learnOtherAddr(server *net.UDPAddr, msg *Message) {
+	var other OtherAddr
+	if other.GetFrom(msg) != nil {
+		var changed ChangedAddr
+		if changed.GetFrom(msg) != nil {
+			return
+		}
+		other = OtherAddr(changed)
+	}
+	client.other.Store(&otherAddr{
+		server: server,
+		addr:   &net.UDPAddr{IP: other.IP, Port: int(other.Port)},
+	})
+}
+
+// sameUDPAddr reports whether a is the UDP address b.
+func sameUDPAddr(a net.Addr, b *net.UDPAddr) bool {
+	addr, ok := a.(*net.UDPAddr)
+	return ok && addr.IP.Equal(b.IP) && addr.Port == b.Port
+}
+
+// watchContext makes the I/O of c fail once ctx is done, by setting the
+// deadline of ctx on c and moving it to the past on cancellation. The
+// returned function clears the deadline, c possibly being shared.
+func watchContext(ctx context.Context, c net.PacketConn) func() {
+	if ctx.Done() == nil {
+		return func() {}
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		c.SetDeadline(deadline)
+	}
+	canceled := make(chan struct{})
+	stop := context.AfterFunc(ctx, func() {
+		c.SetDeadline(time.Unix(1, 0))
+		close(canceled)
+	})
+	return func() {
+		if !stop() {
+			// Wait for the cancellation not to set its deadline after ours
+			<-canceled
+		}
+		c.SetDeadline(time.Time{})
+	}
+}
+
+// contextError returns the error of ctx if err results from it being done,
+// and err otherwise.
+func contextError(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	if _, ok := ctx.Deadline(); ok && errors.Is(err, os.ErrDeadlineExceeded) {
+		return context.DeadlineExceeded
+	}
+	return err
+}
+
+// resolveServer resolves the UDP address of the server from ServerAddr, a
+// "host:port" address or a "stun:" URI, until ctx is done.
+func (client *Client) resolveServer(ctx context.Context) (*net.UDPAddr, error) {
+	hostport := HostPortWithDefault(client.ServerAddr, false)
+	if isURI(client.ServerAddr) {
+		u, err := ParseURI(client.ServerAddr)
+		if err != nil {
+			return nil, err
+		}
+		if u.Secure {
+			return nil, fmt.Errorf("%w: %s: TLS and DTLS are not supported", ErrInvalidURI, u)
+		}
+		hostport = u.HostPort()
+	}
+	return resolveUDPAddr(ctx, hostport)
+}
+
+// resolveUDPAddr is net.ResolveUDPAddr("udp", hostport) honoring ctx,
+// preferring IPv4 addresses as it does.
+func resolveUDPAddr(ctx context.Context, hostport string) (*net.UDPAddr, error) {
+	host, port, err := net.SplitHostPort(hostport)
+	if err != nil {
+		return nil, err
+	}
+	portnum, err := net.DefaultResolver.LookupPort(ctx, "udp", port)
+	if err != nil {
+		return nil, err
+	}
+	if host == "" {
+		return &net.UDPAddr{Port: portnum}, nil
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	addr := addrs[0]
+	for _, a := range addrs {
+		if a.IP.To4() != nil {
+			addr = a
+			break
+		}
+	}
+	return &net.UDPAddr{IP: addr.IP, Port: portnum, Zone: addr.Zone}, nil
 }
@@ -0,0 +1,137 @@
+package stun
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+// quietLogger only reports fatal errors, to keep test output readable.
+func quietLogger() *Logger {
+	return NewLogger(LoggerConfig{Level: FatalLevel})
+}
+
+// listenUDP returns a UDP socket on the loopback interface.
+func listenUDP(t *testing.T) *net.UDPConn {
+	t.Helper()
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// bindingResponse returns a Binding success response with transaction ID id
+// reporting addr as the mapped address.
+func bindingResponse(t *testing.T, id TransactionID, addr *net.UDPAddr) []byte {
+	t.Helper()
+	resp := NewBindingSuccess(id)
+	if err := (XorMappedAddr{IP: addr.IP, Port: uint16(addr.Port)}).AddTo(resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp.Encode()
+}
+
+func TestDialIgnoresUnrelatedDatagrams(t *testing.T) {
+	server := listenUDP(t)
+	spoofer := listenUDP(t)
+	spoofed := &net.UDPAddr{IP: net.IPv4(203, 0, 113, 66), Port: 6666}
+	mapped := &net.UDPAddr{IP: net.IPv4(198, 51, 100, 7), Port: 7777}
+
+	go func() {
+		buf := make([]byte, 1500)
+		n, client, err := server.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		req, err := NewMessage(buf[:n])
+		if err != nil {
+			return
+		}
+		id := req.Header.TransactionID
+		// An off-path host answering with the right transaction ID
+		spoofer.WriteToUDP(bindingResponse(t, id, spoofed), client)
+		// The server answering another transaction
+		server.WriteToUDP(bindingResponse(t, NewTransactionID(), spoofed), client)
+		// Not STUN at all
+		server.WriteToUDP([]byte{0x80, 0x00, 0x00, 0x00}, client)
+		server.WriteToUDP(bindingResponse(t, id, mapped), client)
+	}()
+
+	client := NewClientWithLogger(server.LocalAddr().String(), quietLogger())
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	resp, err := client.DialContext(ctx, NewBindingRequest())
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr, err := resp.GetXorAddr()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !addr.IP.Equal(mapped.IP) || int(addr.Port) != mapped.Port {
+		t.Fatalf("mapped address is %s:%d, want %s", addr.IP, addr.Port, mapped)
+	}
+}
+
+func TestDialContextDeadline(t *testing.T) {
+	server := listenUDP(t)
+	client := NewClientWithLogger(server.LocalAddr().String(), quietLogger())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := client.DialContext(ctx, NewBindingRequest()); err != context.DeadlineExceeded {
+		t.Fatalf("got %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestDialChangeRequestSource(t *testing.T) {
+	server := listenUDP(t)
+	other := listenUDP(t)
+	otherAddr := other.LocalAddr().(*net.UDPAddr)
+	mapped := &net.UDPAddr{IP: net.IPv4(198, 51, 100, 7), Port: 7777}
+
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, client, err := server.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			req, err := NewMessage(buf[:n])
+			if err != nil {
+				return
+			}
+			var change ChangeRequestAttribute
+			if change.GetFrom(req) != nil {
+				resp := NewBindingSuccess(req.Header.TransactionID)
+				(OtherAddr{IP: otherAddr.IP, Port: uint16(otherAddr.Port)}).AddTo(resp)
+				server.WriteToUDP(resp.Encode(), client)
+				continue
+			}
+			other.WriteToUDP(bindingResponse(t, req.Header.TransactionID, mapped), client)
+		}
+	}()
+
+	client := NewClientWithLogger(server.LocalAddr().String(), quietLogger())
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if _, err := client.DialContext(ctx, NewBindingRequest()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.DialContext(ctx, NewChangeRequest(false, true)); err != nil {
+		t.Fatalf("response from the alternate port: %v", err)
+	}
+
+	// A response from the alternate port to a request not asking for it is
+	// not accepted
+	short, cancelShort := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancelShort()
+	req := NewBindingRequest()
+	(ChangeRequestAttribute{ChangeIP: true}).AddTo(req)
+	if _, err := client.DialContext(short, req); err != context.DeadlineExceeded {
+		t.Fatalf("got %v, want %v", err, context.DeadlineExceeded)
+	}
+}
@@ -0,0 +1,19 @@
+package stun
+
+import "time"
+
+// Clock is the source of time of an Agent. Simulations replace the system
+// clock with a fake one advanced by the test (see stuntest.Clock), so that
+// timeouts are exercised without real sleeps.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+	// After returns a channel receiving the time once d has elapsed.
+	After(d time.Duration) <-chan time.Time
+}
+
+// systemClock is the Clock of the time package.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                         { return time.Now() }
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
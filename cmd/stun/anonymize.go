@@ -0,0 +1,326 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/lai0xn/stun/stuntest"
+)
+
+// errUnknownFormat is returned when the format of the input cannot be detected.
+var errUnknownFormat = errors.New("unknown input format, use -format")
+
+// Link types of the pcap file format supported by the anonymizer.
+const (
+	linkNull     = 0
+	linkEthernet = 1
+	linkRaw      = 101
+	linkLinuxSLL = 113
+	linkIPv4     = 228
+	linkIPv6     = 229
+)
+
+// pcapMagic and pcapMagicNano start classic pcap files with microsecond and
+// nanosecond timestamps, in the byte order of the writer.
+const (
+	pcapMagic     = 0xa1b2c3d4
+	pcapMagicNano = 0xa1b23c4d
+)
+
+func runAnonymize(args []string) error {
+	fs := flag.NewFlagSet("anonymize", flag.ExitOnError)
+	keyHex := fs.String("key", "", "hex encoded key the pseudonyms are derived from; random if empty")
+	format := fs.String("format", "auto", "input format: pcap, hex (one message per line), raw (one message) or auto")
+	output := fs.String("o", "", "output file; standard output if empty")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: stun anonymize [flags] <input>")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	key, err := hex.DecodeString(*keyHex)
+	if err != nil {
+		return fmt.Errorf("invalid key: %w", err)
+	}
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return err
+		}
+	}
+	anon := stuntest.NewAnonymizer(key)
+
+	in, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if *format == "auto" {
+		*format = detectFormat(in)
+	}
+
+	var out []byte
+	var kept, dropped int
+	switch *format {
+	case "pcap":
+		out, kept, dropped, err = anonymizePcap(anon, in)
+	case "hex":
+		out, kept, dropped, err = anonymizeHex(anon, in)
+	case "raw":
+		out, err = anon.Bytes(in)
+		kept = 1
+	default:
+		err = errUnknownFormat
+	}
+	if err != nil {
+		return err
+	}
+
+	w := io.Writer(os.Stdout)
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	if _, err := w.Write(out); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "anonymized %d messages, dropped %d packets\n", kept, dropped)
+	return nil
+}
+
+// detectFormat guesses the format of a corpus file from its first bytes.
+func detectFormat(in []byte) string {
+	if len(in) >= 4 {
+		for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+			if magic := order.Uint32(in); magic == pcapMagic || magic == pcapMagicNano {
+				return "pcap"
+			}
+		}
+	}
+	if len(in) >= 20 && binary.BigEndian.Uint32(in[4:8]) == 0x2112A442 {
+		return "raw"
+	}
+	return "hex"
+}
+
+// anonymizeHex anonymizes a file holding one hex encoded message per line.
+// Blank lines are kept; comment lines, starting with '#', are dropped as
+// they may mention addresses.
+func anonymizeHex(anon *stuntest.Anonymizer, in []byte) ([]byte, int, int, error) {
+	var out bytes.Buffer
+	kept, dropped := 0, 0
+	scanner := bufio.NewScanner(bytes.NewReader(in))
+	scanner.Buffer(nil, 1<<20)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+			out.WriteByte('\n')
+			continue
+		case strings.HasPrefix(line, "#"):
+			dropped++
+			continue
+		}
+		raw, err := hex.DecodeString(strings.Join(strings.Fields(line), ""))
+		if err != nil {
+			return nil, 0, 0, fmt.Errorf("line %d: %w", n, err)
+		}
+		msg, err := anon.Bytes(raw)
+		if err != nil {
+			return nil, 0, 0, fmt.Errorf("line %d: %w", n, err)
+		}
+		out.WriteString(hex.EncodeToString(msg))
+		out.WriteByte('\n')
+		kept++
+	}
+	return out.Bytes(), kept, dropped, scanner.Err()
+}
+
+// anonymizePcap anonymizes a classic pcap capture. STUN over UDP packets have
+// their IP addresses mapped as in the STUN attributes, their link layer
+// addresses cleared and their checksums recomputed; every other packet,
+// including IP fragments and truncated captures, is dropped so that the
+// output holds nothing that was not anonymized.
+func anonymizePcap(anon *stuntest.Anonymizer, in []byte) ([]byte, int, int, error) {
+	if len(in) < 24 {
+		return nil, 0, 0, errUnknownFormat
+	}
+	var order binary.ByteOrder = binary.LittleEndian
+	if magic := order.Uint32(in); magic != pcapMagic && magic != pcapMagicNano {
+		order = binary.BigEndian
+	}
+	linkType := order.Uint32(in[20:24]) & 0x0FFFFFFF
+
+	out := append([]byte(nil), in[:24]...)
+	kept, dropped := 0, 0
+	for rest := in[24:]; len(rest) > 0; {
+		if len(rest) < 16 {
+			return nil, 0, 0, io.ErrUnexpectedEOF
+		}
+		captured, original := order.Uint32(rest[8:12]), order.Uint32(rest[12:16])
+		if uint32(len(rest)-16) < captured {
+			return nil, 0, 0, io.ErrUnexpectedEOF
+		}
+		record, frame := rest[:16], rest[16:16+captured]
+		rest = rest[16+captured:]
+
+		if captured != original {
+			dropped++
+			continue
+		}
+		packet, ok := anonymizeFrame(anon, linkType, frame)
+		if !ok {
+			dropped++
+			continue
+		}
+		hdr := append([]byte(nil), record...)
+		order.PutUint32(hdr[8:12], uint32(len(packet)))
+		order.PutUint32(hdr[12:16], uint32(len(packet)))
+		out = append(out, hdr...)
+		out = append(out, packet...)
+		kept++
+	}
+	return out, kept, dropped, nil
+}
+
+// anonymizeFrame anonymizes a link layer frame holding a STUN over UDP
+// packet, and reports false for any other frame.
+func anonymizeFrame(anon *stuntest.Anonymizer, linkType uint32, frame []byte) ([]byte, bool) {
+	var link []byte
+	switch linkType {
+	case linkEthernet:
+		if len(frame) < 14 {
+			return nil, false
+		}
+		link = append([]byte(nil), frame[:14]...)
+		for ethertype := binary.BigEndian.Uint16(link[12:14]); ethertype == 0x8100 || ethertype == 0x88a8; ethertype = binary.BigEndian.Uint16(link[len(link)-2:]) {
+			if len(frame) < len(link)+4 {
+				return nil, false
+			}
+			link = append(link, frame[len(link):len(link)+4]...)
+		}
+		clear(link[:12])
+	case linkLinuxSLL:
+		if len(frame) < 16 {
+			return nil, false
+		}
+		link = append([]byte(nil), frame[:16]...)
+		clear(link[6:14])
+	case linkNull:
+		if len(frame) < 4 {
+			return nil, false
+		}
+		link = append([]byte(nil), frame[:4]...)
+	case linkRaw, linkIPv4, linkIPv6:
+	default:
+		return nil, false
+	}
+
+	packet, ok := anonymizeIP(anon, frame[len(link):])
+	if !ok {
+		return nil, false
+	}
+	return append(link, packet...), true
+}
+
+// anonymizeIP anonymizes an IPv4 or IPv6 packet holding STUN over UDP.
+// Bytes past the end of the packet, such as Ethernet padding, are dropped.
+func anonymizeIP(anon *stuntest.Anonymizer, packet []byte) ([]byte, bool) {
+	if len(packet) < 1 {
+		return nil, false
+	}
+	var header, src, dst, udp []byte
+	version := packet[0] >> 4
+	switch version {
+	case 4:
+		if len(packet) < 20 {
+			return nil, false
+		}
+		ihl := int(packet[0]&0x0F) * 4
+		total := int(binary.BigEndian.Uint16(packet[2:4]))
+		fragmented := binary.BigEndian.Uint16(packet[6:8])&0x3FFF != 0
+		if ihl < 20 || total < ihl || len(packet) < total || packet[9] != 17 || fragmented {
+			return nil, false
+		}
+		header = append([]byte(nil), packet[:ihl]...)
+		src, dst = header[12:16], header[16:20]
+		udp = packet[ihl:total]
+	case 6:
+		if len(packet) < 40 {
+			return nil, false
+		}
+		total := 40 + int(binary.BigEndian.Uint16(packet[4:6]))
+		if len(packet) < total || packet[6] != 17 {
+			return nil, false
+		}
+		header = append([]byte(nil), packet[:40]...)
+		src, dst = header[8:24], header[24:40]
+		udp = packet[40:total]
+	default:
+		return nil, false
+	}
+	if len(udp) < 8 || int(binary.BigEndian.Uint16(udp[4:6])) != len(udp) {
+		return nil, false
+	}
+
+	payload, err := anon.Bytes(udp[8:])
+	if err != nil {
+		return nil, false
+	}
+	copy(src, anon.IP(net.IP(src)))
+	copy(dst, anon.IP(net.IP(dst)))
+
+	segment := make([]byte, 8+len(payload))
+	copy(segment, udp[:4])
+	binary.BigEndian.PutUint16(segment[4:6], uint16(len(segment)))
+	copy(segment[8:], payload)
+
+	pseudo := append(append([]byte(nil), src...), dst...)
+	pseudo = append(pseudo, 0, 17, byte(len(segment)>>8), byte(len(segment)))
+	sum := checksum(append(pseudo, segment...))
+	if sum == 0 {
+		sum = 0xFFFF
+	}
+	binary.BigEndian.PutUint16(segment[6:8], sum)
+
+	if version == 6 {
+		binary.BigEndian.PutUint16(header[4:6], uint16(len(segment)))
+	} else {
+		binary.BigEndian.PutUint16(header[2:4], uint16(len(header)+len(segment)))
+		header[10], header[11] = 0, 0
+		binary.BigEndian.PutUint16(header[10:12], checksum(header))
+	}
+	return append(header, segment...), true
+}
+
+// checksum returns the Internet checksum of b (RFC 1071).
+func checksum(b []byte) uint16 {
+	var sum uint32
+	for ; len(b) >= 2; b = b[2:] {
+		sum += uint32(b[0])<<8 | uint32(b[1])
+	}
+	if len(b) == 1 {
+		sum += uint32(b[0]) << 8
+	}
+	for sum > 0xFFFF {
+		sum = sum>>16 + sum&0xFFFF
+	}
+	return ^uint16(sum)
+}
@@ -0,0 +1,391 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/lai0xn/stun"
+)
+
+// Probe statuses
+const (
+	statusPass = "pass"
+	statusFail = "fail"
+	statusSkip = "skip"
+)
+
+// probeResult is the outcome of a single conformance probe.
+type probeResult struct {
+	Name   string `json:"name"`
+	RFC    string `json:"rfc"`
+	Status string `json:"status"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// conformanceReport is the structured report printed by the conformance command.
+type conformanceReport struct {
+	Server  string        `json:"server"`
+	Client  string        `json:"client"`
+	Results []probeResult `json:"results"`
+	Passed  int           `json:"passed"`
+	Failed  int           `json:"failed"`
+	Skipped int           `json:"skipped"`
+}
+
+// errFailedProbes is returned when at least one probe failed, so that the
+// command exits with a non-zero status.
+var errFailedProbes = errors.New("some probes failed")
+
+// prober runs probes against a single server.
+type prober struct {
+	server  *net.UDPAddr
+	timeout time.Duration
+
+	// binding is the response to the basic Binding request, shared by the
+	// probes inspecting its attributes.
+	binding *stun.Message
+}
+
+// probe is a single conformance check. It returns the status and a detail
+// message explaining failures and skips.
+type probe struct {
+	name string
+	rfc  string
+	run  func(p *prober) (string, string)
+}
+
+var probes = []probe{
+	{"binding-success", "RFC 5389 §7.3", (*prober).probeBinding},
+	{"transaction-id-echo", "RFC 5389 §7.3", (*prober).probeTransactionID},
+	{"magic-cookie", "RFC 5389 §6", (*prober).probeMagicCookie},
+	{"xor-mapped-address", "RFC 5389 §15.2", (*prober).probeXorMappedAddress},
+	{"ignore-optional-attribute", "RFC 5389 §7.3", (*prober).probeOptionalAttribute},
+	{"reject-required-attribute", "RFC 5389 §7.3.1", (*prober).probeRequiredAttribute},
+	{"other-address", "RFC 5780 §7.4", (*prober).probeOtherAddress},
+	{"change-port", "RFC 5780 §7.2", (*prober).probeChangePort},
+	{"change-ip", "RFC 5780 §7.2", (*prober).probeChangeIP},
+	{"response-origin", "RFC 5780 §7.3", (*prober).probeResponseOrigin},
+	{"padded-attribute", "RFC 8489 §14", (*prober).probePaddedAttribute},
+}
+
+func runConformance(args []string) error {
+	fs := flag.NewFlagSet("conformance", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	timeout := fs.Duration("timeout", 2*time.Second, "time to wait for each response")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: stun conformance [-json] [-timeout 2s] <host[:port]>")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	server, err := net.ResolveUDPAddr("udp", stun.HostPortWithDefault(fs.Arg(0), false))
+	if err != nil {
+		return err
+	}
+
+	p := &prober{server: server, timeout: *timeout}
+	report := conformanceReport{Server: server.String(), Client: stun.Features().Version}
+	for _, pr := range probes {
+		status, detail := pr.run(p)
+		report.Results = append(report.Results, probeResult{
+			Name:   pr.name,
+			RFC:    pr.rfc,
+			Status: status,
+			Detail: detail,
+		})
+		switch status {
+		case statusPass:
+			report.Passed++
+		case statusFail:
+			report.Failed++
+		default:
+			report.Skipped++
+		}
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			return err
+		}
+	} else {
+		printReport(report)
+	}
+
+	if report.Failed > 0 {
+		return errFailedProbes
+	}
+	return nil
+}
+
+func printReport(r conformanceReport) {
+	fmt.Printf("Conformance report for %s (stun %s)\n\n", r.Server, r.Client)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PROBE\tRFC\tSTATUS\tDETAIL")
+	for _, res := range r.Results {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", res.Name, res.RFC, res.Status, res.Detail)
+	}
+	w.Flush()
+	fmt.Printf("\n%d passed, %d failed, %d skipped\n", r.Passed, r.Failed, r.Skipped)
+}
+
+// newRequest returns a Binding request with a fresh transaction ID.
+func newRequest() *stun.Message {
+	return &stun.Message{Header: stun.Header{
+		Type:          stun.BindingRequest,
+		MagicCookie:   0x2112A442,
+		TransactionID: stun.NewTransactionID(),
+	}}
+}
+
+// network returns the network of the sockets reaching the server, of its
+// address family.
+func (p *prober) network() string {
+	if p.server.IP.To4() != nil {
+		return "udp4"
+	}
+	return "udp6"
+}
+
+// transact sends req to dst and waits for a response with the same
+// transaction ID, returning it along with the address it came from.
+func (p *prober) transact(req *stun.Message, dst *net.UDPAddr) (*stun.Message, *net.UDPAddr, error) {
+	conn, err := net.ListenUDP(p.network(), nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer conn.Close()
+
+	if _, err := conn.WriteToUDP(req.Encode(), dst); err != nil {
+		return nil, nil, err
+	}
+
+	buf := make([]byte, 2048)
+	deadline := time.Now().Add(p.timeout)
+	for {
+		if err := conn.SetReadDeadline(deadline); err != nil {
+			return nil, nil, err
+		}
+		n, from, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			return nil, nil, err
+		}
+		resp, err := stun.NewMessage(buf[:n])
+		if err != nil || resp.Header.TransactionID != req.Header.TransactionID {
+			continue // Not a response to this request
+		}
+		return resp, from, nil
+	}
+}
+
+// basicBinding returns the response to a plain Binding request, sending it once.
+func (p *prober) basicBinding() (*stun.Message, error) {
+	if p.binding != nil {
+		return p.binding, nil
+	}
+	resp, _, err := p.transact(newRequest(), p.server)
+	if err != nil {
+		return nil, err
+	}
+	p.binding = resp
+	return resp, nil
+}
+
+func (p *prober) probeBinding() (string, string) {
+	req := newRequest()
+	resp, _, err := p.transact(req, p.server)
+	if err != nil {
+		return statusFail, err.Error()
+	}
+	if !resp.IsSuccessResponseFor(req) {
+		return statusFail, "unexpected response " + resp.Header.Type.String()
+	}
+	p.binding = resp
+	return statusPass, ""
+}
+
+func (p *prober) probeTransactionID() (string, string) {
+	req := newRequest()
+	conn, err := net.ListenUDP(p.network(), nil)
+	if err != nil {
+		return statusFail, err.Error()
+	}
+	defer conn.Close()
+
+	if _, err := conn.WriteToUDP(req.Encode(), p.server); err != nil {
+		return statusFail, err.Error()
+	}
+	conn.SetReadDeadline(time.Now().Add(p.timeout))
+	buf := make([]byte, 2048)
+	n, _, err := conn.ReadFromUDP(buf)
+	if err != nil {
+		return statusFail, err.Error()
+	}
+	resp, err := stun.NewMessage(buf[:n])
+	if err != nil {
+		return statusFail, err.Error()
+	}
+	if resp.Header.TransactionID != req.Header.TransactionID {
+		return statusFail, "response carries a different transaction ID"
+	}
+	return statusPass, ""
+}
+
+func (p *prober) probeMagicCookie() (string, string) {
+	resp, err := p.basicBinding()
+	if err != nil {
+		return statusFail, err.Error()
+	}
+	// NewMessage rejects responses without the magic cookie, so any decoded
+	// response passes; check the value anyway in case decoding gets lenient.
+	if resp.Header.MagicCookie != 0x2112A442 {
+		return statusFail, fmt.Sprintf("magic cookie is 0x%08x", resp.Header.MagicCookie)
+	}
+	return statusPass, ""
+}
+
+func (p *prober) probeXorMappedAddress() (string, string) {
+	resp, err := p.basicBinding()
+	if err != nil {
+		return statusFail, err.Error()
+	}
+	addr, err := resp.GetXorAddr()
+	if err != nil {
+		return statusFail, err.Error()
+	}
+	return statusPass, fmt.Sprintf("%s:%d", addr.IP, addr.Port)
+}
+
+func (p *prober) probeOptionalAttribute() (string, string) {
+	req := newRequest()
+	req.Add(0xC0FF, []byte("conformance"))
+	resp, _, err := p.transact(req, p.server)
+	if err != nil {
+		return statusFail, err.Error()
+	}
+	if !resp.IsSuccessResponseFor(req) {
+		return statusFail, "unknown comprehension-optional attribute caused " + resp.Header.Type.String()
+	}
+	return statusPass, ""
+}
+
+func (p *prober) probeRequiredAttribute() (string, string) {
+	req := newRequest()
+	req.Add(0x7FFF, []byte{0, 0, 0, 0})
+	resp, _, err := p.transact(req, p.server)
+	if err != nil {
+		return statusFail, err.Error()
+	}
+	if !resp.IsErrorResponseFor(req) {
+		return statusFail, "expected a 420 error response, got " + resp.Header.Type.String()
+	}
+	var code stun.ErrorCodeAttribute
+	if err := code.GetFrom(resp); err != nil || code.Code != stun.CodeUnknownAttribute {
+		return statusFail, fmt.Sprintf("expected error code 420, got %v", code)
+	}
+	var unknown stun.UnknownAttributes
+	if err := unknown.GetFrom(resp); err != nil {
+		return statusFail, "no UNKNOWN-ATTRIBUTES in 420 response"
+	}
+	for _, t := range unknown {
+		if t == 0x7FFF {
+			return statusPass, ""
+		}
+	}
+	return statusFail, "UNKNOWN-ATTRIBUTES does not list the unknown attribute"
+}
+
+func (p *prober) probeOtherAddress() (string, string) {
+	resp, err := p.basicBinding()
+	if err != nil {
+		return statusFail, err.Error()
+	}
+	var other stun.OtherAddr
+	if err := other.GetFrom(resp); err != nil {
+		return statusSkip, "no OTHER-ADDRESS, server does not support RFC 5780"
+	}
+	return statusPass, fmt.Sprintf("%s:%d", other.IP, other.Port)
+}
+
+func (p *prober) probeChangePort() (string, string) {
+	return p.probeChange(false, true)
+}
+
+func (p *prober) probeChangeIP() (string, string) {
+	return p.probeChange(true, false)
+}
+
+// probeChange checks that a CHANGE-REQUEST is answered from the expected address.
+func (p *prober) probeChange(changeIP, changePort bool) (string, string) {
+	resp, err := p.basicBinding()
+	if err != nil {
+		return statusFail, err.Error()
+	}
+	var other stun.OtherAddr
+	if err := other.GetFrom(resp); err != nil {
+		return statusSkip, "no OTHER-ADDRESS, server does not support RFC 5780"
+	}
+
+	req := newRequest()
+	(stun.ChangeRequestAttribute{ChangeIP: changeIP, ChangePort: changePort}).AddTo(req)
+	resp, from, err := p.transact(req, p.server)
+	if err != nil {
+		return statusFail, err.Error()
+	}
+	if !resp.IsSuccessResponseFor(req) {
+		return statusFail, "unexpected response " + resp.Header.Type.String()
+	}
+
+	wantIP, wantPort := p.server.IP, p.server.Port
+	if changeIP {
+		wantIP = other.IP
+	}
+	if changePort {
+		wantPort = int(other.Port)
+	}
+	if !from.IP.Equal(wantIP) || from.Port != wantPort {
+		return statusFail, fmt.Sprintf("response came from %s, want %s", from, net.JoinHostPort(wantIP.String(), fmt.Sprint(wantPort)))
+	}
+	return statusPass, ""
+}
+
+func (p *prober) probeResponseOrigin() (string, string) {
+	resp, err := p.basicBinding()
+	if err != nil {
+		return statusFail, err.Error()
+	}
+	var origin stun.ResponseOriginAddr
+	if err := origin.GetFrom(resp); err != nil {
+		return statusSkip, "no RESPONSE-ORIGIN in response"
+	}
+	if !origin.IP.Equal(p.server.IP) || int(origin.Port) != p.server.Port {
+		return statusFail, fmt.Sprintf("RESPONSE-ORIGIN is %s:%d, request was sent to %s", origin.IP, origin.Port, p.server)
+	}
+	return statusPass, ""
+}
+
+func (p *prober) probePaddedAttribute() (string, string) {
+	// An attribute whose length is not a multiple of 4 must be padded and the
+	// padding ignored by the server
+	req := newRequest()
+	req.Add(0xC0FE, []byte{1, 2, 3, 4, 5})
+	resp, _, err := p.transact(req, p.server)
+	if err != nil {
+		return statusFail, err.Error()
+	}
+	if !resp.IsSuccessResponseFor(req) {
+		return statusFail, "padded attribute caused " + resp.Header.Type.String()
+	}
+	return statusPass, ""
+}
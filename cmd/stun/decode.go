@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/lai0xn/stun"
+)
+
+// textAttrs are the attributes whose value is printed as text.
+var textAttrs = map[stun.StunAttribute]bool{
+	stun.Username:        true,
+	stun.Realm:           true,
+	stun.Nonce:           true,
+	stun.Software:        true,
+	stun.AlternateDomain: true,
+}
+
+// addrAttrs are the codecs of the attributes whose value is printed as a
+// transport address.
+var addrAttrs = map[stun.StunAttribute]stun.AddressAttribute{
+	stun.MappedAddress:     {Type: stun.MappedAddress},
+	stun.SourceAddress:     {Type: stun.SourceAddress},
+	stun.ChangedAddress:    {Type: stun.ChangedAddress},
+	stun.AlternateServer:   {Type: stun.AlternateServer},
+	stun.ResponseOrigin:    {Type: stun.ResponseOrigin},
+	stun.OtherAddress:      {Type: stun.OtherAddress},
+	stun.XORMappedAddress:  {Type: stun.XORMappedAddress, XOR: true},
+	stun.XORPeerAddress:    {Type: stun.XORPeerAddress, XOR: true},
+	stun.XORRelayedAddress: {Type: stun.XORRelayedAddress, XOR: true},
+}
+
+// decodedAttr is an attribute as printed by the decode command.
+type decodedAttr struct {
+	Type    string `json:"type"`
+	Name    string `json:"name,omitempty"`
+	Length  int    `json:"length"`
+	Value   string `json:"value"`
+	Decoded string `json:"decoded,omitempty"`
+}
+
+// decodedMessage is a message as printed by the decode command.
+type decodedMessage struct {
+	Line          int           `json:"line"`
+	Type          string        `json:"type"`
+	TransactionID string        `json:"transaction_id"`
+	Length        int           `json:"length"`
+	Attributes    []decodedAttr `json:"attributes"`
+}
+
+func runDecode(args []string) error {
+	fs := flag.NewFlagSet("decode", flag.ExitOnError)
+	fromLog := fs.Bool("from-log", false, "extract hex encoded messages from arbitrary log text")
+	asJSON := fs.Bool("json", false, "print one JSON object per message")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: stun decode [-from-log] [-json] [file]")
+		fmt.Fprintln(fs.Output(), "\nDecodes hex encoded STUN messages, one per line unless -from-log is")
+		fmt.Fprintln(fs.Output(), "set, read from file or standard input.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() > 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	var in io.Reader = os.Stdin
+	if fs.NArg() == 1 {
+		f, err := os.Open(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	print := func(lm stun.LoggedMessage) error {
+		d := describe(lm)
+		if *asJSON {
+			return json.NewEncoder(out).Encode(d)
+		}
+		printDecoded(out, d)
+		return nil
+	}
+
+	if *fromLog {
+		found := 0
+		for lm, err := range stun.DecodeHexStream(in) {
+			if err != nil {
+				return err
+			}
+			if err := print(lm); err != nil {
+				return err
+			}
+			found++
+		}
+		if found == 0 {
+			fmt.Fprintln(os.Stderr, "stun decode: no STUN message found")
+		}
+		return nil
+	}
+
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(nil, 1<<20)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		raw, err := hex.DecodeString(strings.Join(strings.Fields(line), ""))
+		if err != nil {
+			return fmt.Errorf("line %d: %w", n, err)
+		}
+		// The stream decoder only yields well-formed messages
+		var m *stun.Message
+		for lm, err := range stun.DecodeHexStream(strings.NewReader(line)) {
+			if err == nil && len(lm.Raw) == len(raw) {
+				m = lm.Message
+			}
+			break
+		}
+		if m == nil {
+			return fmt.Errorf("line %d: not a well-formed STUN message", n)
+		}
+		if err := print(stun.LoggedMessage{Line: n, Text: line, Raw: raw, Message: m}); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// describe returns the printable form of lm.
+func describe(lm stun.LoggedMessage) decodedMessage {
+	m := lm.Message
+	d := decodedMessage{
+		Line:          lm.Line,
+		Type:          m.Header.Type.String(),
+		TransactionID: m.Header.TransactionID.String(),
+		Length:        int(m.Header.Length),
+		Attributes:    []decodedAttr{},
+	}
+	for _, attr := range m.Attributes {
+		a := decodedAttr{
+			Type:   fmt.Sprintf("0x%04x", uint16(attr.Type)),
+			Name:   attr.Type.Name(),
+			Length: int(attr.Length),
+			Value:  hex.EncodeToString(attr.Value),
+		}
+		if codec, ok := addrAttrs[attr.Type]; ok {
+			if addr, err := codec.Decode(attr.Value, m.Header.TransactionID); err == nil {
+				a.Decoded = fmt.Sprintf("%s:%d", addr.IP, addr.Port)
+			}
+		} else if textAttrs[attr.Type] {
+			a.Decoded = fmt.Sprintf("%q", attr.Value)
+		}
+		d.Attributes = append(d.Attributes, a)
+	}
+	return d
+}
+
+// printDecoded writes d in human readable form.
+func printDecoded(w io.Writer, d decodedMessage) {
+	fmt.Fprintf(w, "line %d: %s, transaction %s, length %d\n", d.Line, d.Type, d.TransactionID, d.Length)
+	for _, a := range d.Attributes {
+		name := a.Name
+		if name == "" {
+			name = "unknown"
+		}
+		value := a.Decoded
+		if value == "" {
+			value = a.Value
+		}
+		fmt.Fprintf(w, "  %s %s (%d bytes): %s\n", a.Type, name, a.Length, value)
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/lai0xn/stun"
+)
+
+// runFeatures prints the support matrix of the build of the stun package
+// linked into the command.
+func runFeatures(args []string) error {
+	fs := flag.NewFlagSet("features", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print the support matrix as JSON")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: stun features [-json]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	m := stun.Features()
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(m)
+	}
+
+	fmt.Printf("Version:    %s\n", m.Version)
+	fmt.Printf("Transports: %s\n", strings.Join(m.Transports, ", "))
+	fmt.Println("RFCs:")
+	for _, rfc := range m.RFCs {
+		if rfc.Notes != "" {
+			fmt.Printf("  RFC %-5d %s (%s)\n", rfc.RFC, rfc.Title, rfc.Notes)
+		} else {
+			fmt.Printf("  RFC %-5d %s\n", rfc.RFC, rfc.Title)
+		}
+	}
+	fmt.Println("Attributes:")
+	for _, attr := range m.Attributes {
+		if attr.Registered {
+			fmt.Printf("  0x%04x %s (registered)\n", uint16(attr.Type), attr.Name)
+		} else {
+			fmt.Printf("  0x%04x %s\n", uint16(attr.Type), attr.Name)
+		}
+	}
+	return nil
+}
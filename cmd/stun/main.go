@@ -0,0 +1,57 @@
+// Command stun is a command line companion to the stun package.
+//
+// Usage:
+//
+//	stun conformance [-json] [-timeout 2s] <server>
+//	stun soak [-duration 1h] [-interval 30s] [-workers 8]
+//	stun anonymize [-key hex] [-format auto] [-o out] <capture>
+//	stun decode [-from-log] [-json] [file]
+//	stun features [-json]
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+const usage = `Usage: stun <command> [flags] [args]
+
+Commands:
+  conformance   run RFC 5389/5780/8489 probes against a server and report the results
+  soak          run client and server in-process for a long time and check for leaks
+  anonymize     rewrite addresses and credentials of a capture so it can be shared
+  decode        print hex encoded messages, or those found in logs with -from-log
+  features      print the RFCs, attributes and transports supported by this build
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "conformance":
+		err = runConformance(os.Args[2:])
+	case "soak":
+		err = runSoak(os.Args[2:])
+	case "anonymize":
+		err = runAnonymize(os.Args[2:])
+	case "decode":
+		err = runDecode(os.Args[2:])
+	case "features":
+		err = runFeatures(os.Args[2:])
+	case "help", "-h", "-help", "--help":
+		fmt.Print(usage)
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "stun: unknown command %q\n\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "stun %s: %v\n", os.Args[1], err)
+		os.Exit(1)
+	}
+}
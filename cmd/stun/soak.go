@@ -0,0 +1,158 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lai0xn/stun"
+	"github.com/lai0xn/stun/stuntest"
+)
+
+// errLeak is returned when the soak run detects a goroutine or memory leak.
+var errLeak = errors.New("leak detected")
+
+// soakSnapshot is a point-in-time view of the runtime metrics watched for leaks.
+type soakSnapshot struct {
+	At           time.Duration
+	Goroutines   int
+	HeapAlloc    uint64
+	HeapObjects  uint64
+	Transactions uint64
+	Errors       uint64
+}
+
+func runSoak(args []string) error {
+	fs := flag.NewFlagSet("soak", flag.ExitOnError)
+	duration := fs.Duration("duration", time.Hour, "how long to run")
+	interval := fs.Duration("interval", 30*time.Second, "how often to print a runtime snapshot")
+	workers := fs.Int("workers", 8, "number of concurrent clients")
+	rate := fs.Duration("rate", 10*time.Millisecond, "delay between two transactions of a client")
+	heapGrowth := fs.Float64("max-heap-growth", 2, "maximum heap growth factor between warm-up and the end of the run")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: stun soak [flags]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	baseGoroutines := runtime.NumGoroutine()
+
+	env, err := stuntest.NewEnv(stuntest.FullCone, stun.ServerConfig{})
+	if err != nil {
+		return err
+	}
+
+	var (
+		transactions atomic.Uint64
+		failures     atomic.Uint64
+		wg           sync.WaitGroup
+	)
+	stop := make(chan struct{})
+	for i := 0; i < *workers; i++ {
+		client, err := env.NewClient(env.ServerAddr)
+		if err != nil {
+			env.Close()
+			return err
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				case <-time.After(*rate):
+				}
+				if _, err := env.MappedAddr(client); err != nil {
+					failures.Add(1)
+					continue
+				}
+				transactions.Add(1)
+			}
+		}()
+	}
+
+	start := time.Now()
+	snapshot := func() soakSnapshot {
+		runtime.GC()
+		var ms runtime.MemStats
+		runtime.ReadMemStats(&ms)
+		return soakSnapshot{
+			At:           time.Since(start).Round(time.Second),
+			Goroutines:   runtime.NumGoroutine(),
+			HeapAlloc:    ms.HeapAlloc,
+			HeapObjects:  ms.HeapObjects,
+			Transactions: transactions.Load(),
+			Errors:       failures.Load(),
+		}
+	}
+	printSnapshot := func(s soakSnapshot) {
+		fmt.Printf("%-10s goroutines=%-5d heap=%-10d objects=%-8d transactions=%-10d errors=%d\n",
+			s.At, s.Goroutines, s.HeapAlloc, s.HeapObjects, s.Transactions, s.Errors)
+	}
+
+	// The first interval is a warm-up: pools and buffers reach their steady
+	// size, so the baseline for heap growth is taken after it.
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	deadline := time.After(*duration)
+
+	var warm *soakSnapshot
+	running := true
+	for running {
+		select {
+		case <-ticker.C:
+			s := snapshot()
+			printSnapshot(s)
+			if warm == nil {
+				warm = &s
+			}
+		case <-deadline:
+			running = false
+		}
+	}
+	final := snapshot()
+	printSnapshot(final)
+
+	close(stop)
+	wg.Wait()
+	env.Close()
+
+	var leaks []string
+	if n := settledGoroutines(baseGoroutines, 5*time.Second); n > baseGoroutines {
+		leaks = append(leaks, fmt.Sprintf("%d goroutines still running after shutdown (started with %d)", n, baseGoroutines))
+		buf := make([]byte, 1<<20)
+		fmt.Fprintf(os.Stderr, "%s\n", buf[:runtime.Stack(buf, true)])
+	}
+	if warm != nil && float64(final.HeapAlloc) > float64(warm.HeapAlloc)**heapGrowth {
+		leaks = append(leaks, fmt.Sprintf("heap grew from %d to %d bytes", warm.HeapAlloc, final.HeapAlloc))
+	}
+
+	fmt.Printf("\n%d transactions, %d errors in %s\n", final.Transactions, final.Errors, final.At)
+	for _, l := range leaks {
+		fmt.Println("LEAK:", l)
+	}
+	if len(leaks) > 0 {
+		return errLeak
+	}
+	fmt.Println("no leak detected")
+	return nil
+}
+
+// settledGoroutines waits up to timeout for the number of goroutines to
+// drop back to want, returning the last observed count.
+func settledGoroutines(want int, timeout time.Duration) int {
+	deadline := time.Now().Add(timeout)
+	for {
+		n := runtime.NumGoroutine()
+		if n <= want || time.Now().After(deadline) {
+			return n
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
@@ -0,0 +1,115 @@
+package stun
+
+// knownAttrs are the comprehension-required attributes understood by the
+// package. Comprehension-optional attributes need no listing since unknown
+// ones are simply ignored.
+var knownAttrs = map[StunAttribute]bool{
+	MappedAddress:          true,
+	ChangeRequest:          true,
+	SourceAddress:          true,
+	ChangedAddress:         true,
+	Username:               true,
+	MessageIntegrity:       true,
+	ErrorCode:              true,
+	UnknownStunAttributes:  true,
+	ChannelNumber:          true,
+	Lifetime:               true,
+	XORPeerAddress:         true,
+	Data:                   true,
+	Realm:                  true,
+	Nonce:                  true,
+	XORRelayedAddress:      true,
+	EvenPort:               true,
+	RequestedTransport:     true,
+	DontFragment:           true,
+	AccessTokenAttr:        true,
+	MessageIntegritySHA256: true,
+	PasswordAlgorithm:      true,
+	UserHash:               true,
+	XORMappedAddress:       true,
+	ReservationToken:       true,
+	Priority:               true,
+	UseCandidate:           true,
+	Padding:                true,
+}
+
+// ComprehensionRequired reports whether the attribute type is in the
+// comprehension-required range 0x0000-0x7FFF (RFC 5389 §15): a message
+// carrying such an attribute that the receiver does not understand must not
+// be processed.
+func (t StunAttribute) ComprehensionRequired() bool {
+	return t < 0x8000
+}
+
+// UnknownRequiredAttrs returns the comprehension-required attribute types of
+// m that are neither defined by the package, registered with RegisterAttr nor
+// listed in known, each type once. It returns nil if there are none.
+//
+// Example:
+//
+//	if unknown := resp.UnknownRequiredAttrs(); unknown != nil {
+//		// RFC 5389 §7.3.3: the transaction has failed
+//		return fmt.Errorf("unknown attributes in response: %v", unknown)
+//	}
+func (m Message) UnknownRequiredAttrs(known ...StunAttribute) UnknownAttributes {
+	var unknown UnknownAttributes
+	registered := registeredAttrs()
+next:
+	for _, attr := range m.Attributes {
+		t := attr.Type
+		if !t.ComprehensionRequired() || knownAttrs[t] {
+			continue
+		}
+		if _, ok := registered[t]; ok {
+			continue
+		}
+		for _, k := range known {
+			if k == t {
+				continue next
+			}
+		}
+		for _, u := range unknown {
+			if u == t {
+				continue next
+			}
+		}
+		unknown = append(unknown, t)
+	}
+	return unknown
+}
+
+// UnknownAttributesPolicy returns middleware enforcing RFC 5389 §7.3.1:
+// requests carrying comprehension-required attributes the server does not
+// understand (see Message.UnknownRequiredAttrs) are answered with a 420
+// (Unknown Attribute) error listing them in UNKNOWN-ATTRIBUTES, and such
+// indications are dropped. known lists the attributes handled by the
+// application beyond those of the package and registered codecs.
+//
+// The server applies the policy inside the configured middleware, so that
+// authentication runs first as RFC 5389 §10 requires, with
+// ServerConfig.KnownAttributes as known.
+func UnknownAttributesPolicy(known ...StunAttribute) Middleware {
+	return func(next Handler) Handler {
+		return func(req *Request) (*Message, error) {
+			class := req.Message.Header.Type.Class()
+			if class != ClassRequest && class != ClassIndication {
+				return next(req)
+			}
+			unknown := req.Message.UnknownRequiredAttrs(known...)
+			if unknown == nil {
+				return next(req)
+			}
+			if class == ClassIndication {
+				return nil, nil
+			}
+			resp, err := NewErrorResponse(req.Message, CodeUnknownAttribute)
+			if err != nil {
+				return nil, err
+			}
+			if err := unknown.AddTo(resp); err != nil {
+				return nil, err
+			}
+			return resp, nil
+		}
+	}
+}
@@ -1,6 +1,9 @@
 package stun
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 // STUN Message Types
 type MessageType uint16
@@ -20,6 +23,11 @@ const (
 	// It contains the client's mapped address and port, allowing NAT traversal.
 	BindingResponse MessageType = 0x0101
 
+	// BindingIndication represents the Binding Indication message type
+	// (0x0011), which is sent without expecting any response, typically to
+	// keep NAT bindings alive (RFC 5389 §10).
+	BindingIndication MessageType = 0x0011
+
 	// ErrorResponse represents the Error Response message type (0x0111),
 	// which is sent by the STUN server when there is an error processing the request.
 	// It includes an error code and description to notify the client of the issue.
@@ -38,6 +46,20 @@ const (
 	// which indicates the IP address and port used by the client in NAT traversal.
 	MappedAddress StunAttribute = 0x0001
 
+	// ChangeRequest represents the CHANGE-REQUEST attribute (0x0003),
+	// which asks the server to respond from its alternate IP address and/or port (RFC 5780).
+	ChangeRequest StunAttribute = 0x0003
+
+	// SourceAddress represents the SOURCE-ADDRESS attribute (0x0004) of
+	// RFC 3489, which carries the address and port the response was sent from.
+	// It is superseded by RESPONSE-ORIGIN.
+	SourceAddress StunAttribute = 0x0004
+
+	// ChangedAddress represents the CHANGED-ADDRESS attribute (0x0005) of
+	// RFC 3489, which carries the alternate address and port CHANGE-REQUEST
+	// responses are sent from. It is superseded by OTHER-ADDRESS.
+	ChangedAddress StunAttribute = 0x0005
+
 	// Username represents the USERNAME attribute (0x0006),
 	// which is used for authentication purposes in STUN messages.
 	Username StunAttribute = 0x0006
@@ -54,6 +76,22 @@ const (
 	// which lists any attributes in the message that are not understood by the receiver.
 	UnknownStunAttributes StunAttribute = 0x000A
 
+	// ChannelNumber represents the CHANNEL-NUMBER attribute (0x000C),
+	// which identifies the channel a TURN peer is bound to (RFC 5766).
+	ChannelNumber StunAttribute = 0x000C
+
+	// Lifetime represents the LIFETIME attribute (0x000D),
+	// which carries the remaining lifetime of a TURN allocation (RFC 5766).
+	Lifetime StunAttribute = 0x000D
+
+	// XORPeerAddress represents the XOR-PEER-ADDRESS attribute (0x0012),
+	// which carries the address of a TURN peer (RFC 5766).
+	XORPeerAddress StunAttribute = 0x0012
+
+	// Data represents the DATA attribute (0x0013),
+	// which carries the application payload of Send and Data indications (RFC 5766).
+	Data StunAttribute = 0x0013
+
 	// Realm represents the REALM attribute (0x0014),
 	// which is used for realm-based authentication (often with the NONCE attribute).
 	Realm StunAttribute = 0x0014
@@ -62,9 +100,106 @@ const (
 	// which is used for nonce-based authentication and to prevent replay attacks.
 	Nonce StunAttribute = 0x0015
 
+	// XORRelayedAddress represents the XOR-RELAYED-ADDRESS attribute (0x0016),
+	// which carries the relayed address allocated by the TURN server (RFC 5766).
+	XORRelayedAddress StunAttribute = 0x0016
+
+	// EvenPort represents the EVEN-PORT attribute (0x0018),
+	// which asks the TURN server for an even relayed port (RFC 5766).
+	EvenPort StunAttribute = 0x0018
+
+	// RequestedTransport represents the REQUESTED-TRANSPORT attribute (0x0019),
+	// which selects the transport protocol of a TURN allocation (RFC 5766).
+	RequestedTransport StunAttribute = 0x0019
+
+	// DontFragment represents the DONT-FRAGMENT attribute (0x001A),
+	// which asks the TURN server to set the DF bit on relayed packets (RFC 5766).
+	DontFragment StunAttribute = 0x001A
+
+	// AccessTokenAttr represents the ACCESS-TOKEN attribute (0x001B), a token
+	// issued by an authorization server for third-party authorization (RFC 7635).
+	AccessTokenAttr StunAttribute = 0x001B
+
+	// MessageIntegritySHA256 represents the MESSAGE-INTEGRITY-SHA256 attribute (0x001C),
+	// an HMAC-SHA256 of the message, possibly truncated (RFC 8489).
+	MessageIntegritySHA256 StunAttribute = 0x001C
+
+	// PasswordAlgorithm represents the PASSWORD-ALGORITHM attribute (0x001D),
+	// the key derivation selected by the client (RFC 8489).
+	PasswordAlgorithm StunAttribute = 0x001D
+
+	// UserHash represents the USERHASH attribute (0x001E), the SHA-256 of the
+	// username and realm, sent instead of USERNAME (RFC 8489).
+	UserHash StunAttribute = 0x001E
+
 	// XORMappedAddress represents the XOR-MAPPED-ADDRESS attribute (0x0020),
 	// which is similar to MAPPED-ADDRESS but uses XOR to obscure the actual IP address for added security.
 	XORMappedAddress StunAttribute = 0x0020
+
+	// ReservationToken represents the RESERVATION-TOKEN attribute (0x0022),
+	// which identifies a relayed port reserved by a previous allocation (RFC 5766).
+	ReservationToken StunAttribute = 0x0022
+
+	// Priority represents the PRIORITY attribute (0x0024),
+	// which carries the priority of the ICE candidate in connectivity checks (RFC 8445).
+	Priority StunAttribute = 0x0024
+
+	// UseCandidate represents the USE-CANDIDATE attribute (0x0025),
+	// which the controlling ICE agent uses to nominate a candidate pair (RFC 8445).
+	UseCandidate StunAttribute = 0x0025
+
+	// Padding represents the PADDING attribute (0x0026),
+	// which pads a message to a chosen size to probe fragmentation and path MTU (RFC 5780).
+	Padding StunAttribute = 0x0026
+
+	// PasswordAlgorithms represents the PASSWORD-ALGORITHMS attribute (0x8002),
+	// the key derivations supported by the server (RFC 8489).
+	PasswordAlgorithms StunAttribute = 0x8002
+
+	// AlternateDomain represents the ALTERNATE-DOMAIN attribute (0x8003), the
+	// domain name of the alternate server for (D)TLS certificate validation (RFC 8489).
+	AlternateDomain StunAttribute = 0x8003
+
+	// Software represents the SOFTWARE attribute (0x8022), a textual
+	// description of the software of the sender.
+	Software StunAttribute = 0x8022
+
+	// AlternateServer represents the ALTERNATE-SERVER attribute (0x8023), the
+	// address a 300 (Try Alternate) response redirects the client to.
+	AlternateServer StunAttribute = 0x8023
+
+	// FingerprintAttr represents the FINGERPRINT attribute (0x8028), a CRC-32
+	// of the message that must be its last attribute (see Fingerprint).
+	FingerprintAttr StunAttribute = 0x8028
+
+	// ICEControlled represents the ICE-CONTROLLED attribute (0x8029),
+	// which carries the tie-breaker of an agent in the controlled role (RFC 8445).
+	ICEControlled StunAttribute = 0x8029
+
+	// ICEControlling represents the ICE-CONTROLLING attribute (0x802A),
+	// which carries the tie-breaker of an agent in the controlling role (RFC 8445).
+	ICEControlling StunAttribute = 0x802A
+
+	// ResponseOrigin represents the RESPONSE-ORIGIN attribute (0x802B),
+	// which carries the transport address the response was sent from (RFC 5780).
+	ResponseOrigin StunAttribute = 0x802B
+
+	// OtherAddress represents the OTHER-ADDRESS attribute (0x802C),
+	// which advertises the alternate IP address and port of the server (RFC 5780).
+	OtherAddress StunAttribute = 0x802C
+
+	// ThirdPartyAuthorization represents the THIRD-PARTY-AUTHORIZATION attribute
+	// (0x802E), the authorization server a STUN server accepts tokens from (RFC 7635).
+	ThirdPartyAuthorization StunAttribute = 0x802E
+
+	// MobilityTicket represents the MOBILITY-TICKET attribute (0x8030), which
+	// lets a TURN client keep its allocation across address changes (RFC 8016).
+	MobilityTicket StunAttribute = 0x8030
+
+	// CapabilitiesAttr represents the vendor-specific CAPABILITIES attribute (0xC0A5),
+	// which advertises the features supported by this server implementation.
+	// It is comprehension-optional, so other implementations ignore it.
+	CapabilitiesAttr StunAttribute = 0xC0A5
 )
 
 var (
@@ -72,29 +207,260 @@ var (
 	ErrShortBuffer   = errors.New("buffer too short for reading")
 	ErrInvalidCookie = errors.New("invalid magic cookie")
 	ErrShortWrite    = errors.New("short byte write")
+	ErrAttrTooLong   = errors.New("attribute value too long")
+
+	ErrInvalidQuotedString = errors.New("invalid quoted-string")
+	ErrIntegrityMismatch   = errors.New("message integrity mismatch")
+
+	// ErrIntegrityLength is returned for a MESSAGE-INTEGRITY-SHA256 whose
+	// length is not a multiple of 4 between 16 and 32 bytes.
+	ErrIntegrityLength = errors.New("invalid MESSAGE-INTEGRITY-SHA256 length")
+
+	// ErrUserHashLength is returned for a USERHASH attribute that is not
+	// UserHashLength bytes long.
+	ErrUserHashLength = errors.New("invalid USERHASH length")
+
+	// ErrUserHashMismatch is returned by Message.CheckUserHash when the
+	// USERHASH does not match the expected username and realm.
+	ErrUserHashMismatch = errors.New("USERHASH mismatch")
+
+	// ErrNoPasswordAlgorithm is returned when no supported password
+	// algorithm is available.
+	ErrNoPasswordAlgorithm = errors.New("no supported password algorithm")
+
+	// ErrInvalidAccessToken is returned for an ACCESS-TOKEN that is malformed
+	// or cannot be decrypted with the key of the STUN server.
+	ErrInvalidAccessToken = errors.New("invalid access token")
+
+	// ErrAccessTokenExpired is returned for an ACCESS-TOKEN past its lifetime.
+	ErrAccessTokenExpired = errors.New("access token expired")
+
+	// ErrInvalidProxyHeader is returned for a datagram from a trusted proxy
+	// that does not start with a valid PROXY protocol version 2 header.
+	ErrInvalidProxyHeader = errors.New("invalid PROXY protocol header")
+
+	// ErrInvalidUTF8 is wrapped by the errors returned for text attribute
+	// values that are not valid UTF-8.
+	ErrInvalidUTF8 = errors.New("attribute value is not valid UTF-8")
+
+	// ErrStaleNonce is returned by NonceManager.Verify for a nonce that was
+	// not issued by the manager or has expired.
+	ErrStaleNonce = errors.New("stale nonce")
+
+	// ErrReplayedRequest is returned by NonceManager.Verify for a transaction
+	// already seen with the same nonce.
+	ErrReplayedRequest = errors.New("replayed request")
+
+	// ErrUnsupportedAddr is returned for a network address that carries no
+	// IP address and port.
+	ErrUnsupportedAddr = errors.New("address has no IP and port")
+
+	// ErrAttrRegistered is returned by RegisterAttr for an attribute type
+	// that already has a codec.
+	ErrAttrRegistered = errors.New("attribute codec already registered")
+
+	// ErrAttrNotRegistered is returned by Message.Value and Message.AddValue
+	// for an attribute type without a registered codec.
+	ErrAttrNotRegistered = errors.New("no codec registered for attribute")
+
+	// ErrAttrOrder is returned for messages whose MESSAGE-INTEGRITY,
+	// MESSAGE-INTEGRITY-SHA256 or FINGERPRINT attributes are not last, in
+	// that order.
+	ErrAttrOrder = errors.New("invalid attribute order")
+
+	// ErrStringPrep is returned by OpaqueString and SASLprep for strings
+	// that cannot be prepared as credentials.
+	ErrStringPrep = errors.New("invalid credential string")
+
+	// ErrFingerprintMissing is returned by CheckFingerprint for a message
+	// whose last attribute is not FINGERPRINT.
+	ErrFingerprintMissing = errors.New("FINGERPRINT attribute missing")
+
+	// ErrFingerprintMismatch is returned by CheckFingerprint when the
+	// FINGERPRINT of a message does not match its content.
+	ErrFingerprintMismatch = errors.New("FINGERPRINT mismatch")
+
+	// ErrMessageTooLarge is returned when decoding a message larger than
+	// DecodeLimits.MaxMessageSize, and when marshaling one whose attributes
+	// exceed the 16-bit length of the header.
+	ErrMessageTooLarge = errors.New("message too large")
+
+	// ErrTooManyAttributes is returned when decoding a message with more
+	// attributes than DecodeLimits.MaxAttributes.
+	ErrTooManyAttributes = errors.New("too many attributes")
+
+	// ErrAttrTooLarge is returned when decoding an attribute value longer
+	// than DecodeLimits.MaxAttrLength.
+	ErrAttrTooLarge = errors.New("attribute too large")
+
+	// ErrInvalidURI is returned by ParseURI and ParseTURNURI for malformed or
+	// unsupported URIs.
+	ErrInvalidURI = errors.New("invalid URI")
+
+	// ErrInvalidMessage is wrapped by the errors of Message.Validate.
+	ErrInvalidMessage = errors.New("invalid message")
+
+	// ErrNotSTUN is returned when decoding a packet whose message type has
+	// one of its two most significant bits set, which no STUN message has
+	// (RFC 5389 §6): RTP, DTLS or random traffic sharing the port.
+	ErrNotSTUN = errors.New("not a STUN message")
+
+	// ErrMessageTruncated is returned when a datagram exceeds the
+	// MaxMessageSize of the server, client or agent reading it, instead of
+	// decoding the truncated message.
+	ErrMessageTruncated = errors.New("message truncated")
+
+	// ErrNotSuccessResponse is wrapped by the *ResponseError of GetXorAddr
+	// for a message that is not a success response.
+	ErrNotSuccessResponse = errors.New("not a success response")
+
+	// ErrUnexpectedResponse is returned by Client.Dial when the message
+	// received through a Transport is not a response to the request.
+	ErrUnexpectedResponse = errors.New("unexpected response")
+
+	// ErrTransportClosed is returned by a Transport whose connection to the
+	// relay is closed.
+	ErrTransportClosed = errors.New("transport closed")
+
+	// ErrServerNotListening is returned by Server.WriteTo before Listen has
+	// opened the listening socket.
+	ErrServerNotListening = errors.New("server is not listening")
+
+	// ErrIndicationUnsupported is returned by Client.Indicate for clients
+	// using a Transport, which carries request/response exchanges only.
+	ErrIndicationUnsupported = errors.New("indications not supported by the transport")
+
+	// ErrAgentClosed is returned by the methods of an Agent once it is closed.
+	ErrAgentClosed = errors.New("agent closed")
+
+	// ErrAgentBusy is returned by Agent.Do when the agent already has
+	// AgentConfig.MaxPending outstanding transactions and its queue is full.
+	ErrAgentBusy = errors.New("agent busy: too many outstanding transactions")
+
+	// ErrTransactionTimeout is returned when no response to a request arrives
+	// within the transaction timeout.
+	ErrTransactionTimeout = errors.New("transaction timed out")
+
+	// ErrOwnerPrefix is returned by Agent.RegisterOwner for an empty or too
+	// long transaction ID prefix.
+	ErrOwnerPrefix = errors.New("invalid transaction owner prefix")
+
+	// ErrOwnerConflict is returned by Agent.RegisterOwner when the prefix
+	// overlaps the prefix of a registered owner.
+	ErrOwnerConflict = errors.New("transaction owner prefix already registered")
+
+	// ErrDropStatsUnsupported is returned by ReadDropStats on platforms that
+	// do not expose kernel UDP drop counters.
+	ErrDropStatsUnsupported = errors.New("kernel drop statistics not supported on this platform")
 )
 
 // StunAttribute Lengths, attributes with 0 as value have variable lengths
 const (
-	MappedAddressLength         = 8  // 8 bytes for MAPPED-ADDRESS (IPv4 Value only)
-	MessageIntegrityLength      = 20 // 20 bytes for MESSAGE-INTEGRITY (SHA1 HMAC digest)
-	ErrorCodeLength             = 4  // 4 bytes minimal for ERROR-CODE (not including reason phrase)
-	UnknownStunAttributesLength = 0  // Unknown attributes are variable length
-	RealmLength                 = 0  // REALM is variable length
-	NonceLength                 = 0  // NONCE is variable length
-	XORMappedAddressLength      = 8  // 8 bytes for XOR-MAPPED-ADDRESS (IPv4 Value only)
+	MappedAddressLength          = 8  // 8 bytes for MAPPED-ADDRESS (IPv4 Value only)
+	MessageIntegrityLength       = 20 // 20 bytes for MESSAGE-INTEGRITY (SHA1 HMAC digest)
+	MessageIntegritySHA256Length = 32 // 32 bytes for an untruncated MESSAGE-INTEGRITY-SHA256
+	UserHashLength               = 32 // 32 bytes for USERHASH (SHA-256 digest)
+	ErrorCodeLength              = 4  // 4 bytes minimal for ERROR-CODE (not including reason phrase)
+	UnknownStunAttributesLength  = 0  // Unknown attributes are variable length
+	RealmLength                  = 0  // REALM is variable length
+	NonceLength                  = 0  // NONCE is variable length
+	XORMappedAddressLength       = 8  // 8 bytes for XOR-MAPPED-ADDRESS (IPv4 Value only)
 )
 
-// String returns the string representation of the MessageType
-func (mt MessageType) String() string {
-	switch mt {
-	case BindingRequest:
-		return "BindingRequest"
-	case BindingResponse:
-		return "BindingResponse"
-	case ErrorResponse:
-		return "ErrorResponse"
+// Method is the STUN method carried in the message type (e.g. Binding, Allocate).
+type Method uint16
+
+// STUN and TURN methods (RFC 5389 §18.1, RFC 5766 §13)
+const (
+	MethodBinding          Method = 0x001
+	MethodAllocate         Method = 0x003
+	MethodRefresh          Method = 0x004
+	MethodSend             Method = 0x006
+	MethodData             Method = 0x007
+	MethodCreatePermission Method = 0x008
+	MethodChannelBind      Method = 0x009
+)
+
+// MessageClass is the class carried in the message type (request, indication,
+// success response or error response).
+type MessageClass uint8
+
+const (
+	ClassRequest         MessageClass = 0x00
+	ClassIndication      MessageClass = 0x01
+	ClassSuccessResponse MessageClass = 0x02
+	ClassErrorResponse   MessageClass = 0x03
+)
+
+// NewMessageType builds the 14-bit message type from a method and a class.
+// The class bits C0 and C1 are interleaved with the method bits:
+//
+//	 0                 1
+//	 2  3  4 5 6 7 8 9 0 1 2 3 4 5
+//	+--+--+-+-+-+-+-+-+-+-+-+-+-+-+
+//	|M |M |M|M|M|C|M|M|M|C|M|M|M|M|
+//	|11|10|9|8|7|1|6|5|4|0|3|2|1|0|
+//	+--+--+-+-+-+-+-+-+-+-+-+-+-+-+
+func NewMessageType(method Method, class MessageClass) MessageType {
+	m := uint16(method)
+	c := uint16(class)
+	t := m&0x000F | (m&0x0070)<<1 | (m&0x0F80)<<2
+	t |= (c&0x01)<<4 | (c&0x02)<<7
+	return MessageType(t)
+}
+
+// Method returns the method encoded in the message type.
+func (mt MessageType) Method() Method {
+	t := uint16(mt)
+	return Method(t&0x000F | (t&0x00E0)>>1 | (t&0x3E00)>>2)
+}
+
+// Class returns the class encoded in the message type.
+func (mt MessageType) Class() MessageClass {
+	t := uint16(mt)
+	return MessageClass((t>>4)&0x01 | (t>>7)&0x02)
+}
+
+// String returns the string representation of the Method
+func (m Method) String() string {
+	switch m {
+	case MethodBinding:
+		return "Binding"
+	case MethodAllocate:
+		return "Allocate"
+	case MethodRefresh:
+		return "Refresh"
+	case MethodSend:
+		return "Send"
+	case MethodData:
+		return "Data"
+	case MethodCreatePermission:
+		return "CreatePermission"
+	case MethodChannelBind:
+		return "ChannelBind"
 	default:
-		return "Unknown"
+		return fmt.Sprintf("Method(0x%03x)", uint16(m))
 	}
 }
+
+// String returns the string representation of the MessageClass
+func (c MessageClass) String() string {
+	switch c {
+	case ClassRequest:
+		return "Request"
+	case ClassIndication:
+		return "Indication"
+	case ClassSuccessResponse:
+		return "Success Response"
+	case ClassErrorResponse:
+		return "Error Response"
+	default:
+		return fmt.Sprintf("Class(0x%02x)", uint8(c))
+	}
+}
+
+// String returns the string representation of the MessageType, decoded as
+// method followed by class (e.g. "Binding Success Response").
+func (mt MessageType) String() string {
+	return mt.Method().String() + " " + mt.Class().String()
+}
@@ -0,0 +1,82 @@
+package stun
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+// secretFiles are the files handling credentials, keys, tokens and nonces,
+// as listed in the forbidigo exclusions of .golangci.yml.
+var secretFiles = regexp.MustCompile(`^(integrity|access_token|userhash|nonce|password_algorithm|signing|hmac_pool)\.go$`)
+
+// variableTimeFuncs are the comparisons whose duration depends on how much
+// of their inputs match.
+var variableTimeFuncs = map[string]map[string]bool{
+	"bytes":   {"Equal": true, "Compare": true},
+	"reflect": {"DeepEqual": true},
+	"strings": {"EqualFold": true, "Compare": true},
+}
+
+// TestSecretsComparedInConstantTime enforces the forbidigo rule of
+// .golangci.yml as part of the tests: the authentication code must compare
+// secrets with hmac.Equal or subtle.ConstantTimeCompare.
+func TestSecretsComparedInConstantTime(t *testing.T) {
+	names, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fset := token.NewFileSet()
+	checked := 0
+	for _, name := range names {
+		if !secretFiles.MatchString(name) {
+			continue
+		}
+		src, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f, err := parser.ParseFile(fset, name, src, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checked++
+		ast.Inspect(f, func(n ast.Node) bool {
+			sel, ok := n.(*ast.SelectorExpr)
+			if !ok {
+				return true
+			}
+			if pkg, ok := sel.X.(*ast.Ident); ok && variableTimeFuncs[pkg.Name][sel.Sel.Name] {
+				t.Errorf("%s: %s.%s compares in variable time, use hmac.Equal or subtle.ConstantTimeCompare",
+					fset.Position(sel.Pos()), pkg.Name, sel.Sel.Name)
+			}
+			return true
+		})
+	}
+	if checked == 0 {
+		t.Fatal("no authentication source file found")
+	}
+}
+
+func TestIntegrityCheckRejectsEveryByte(t *testing.T) {
+	key := NewShortTermIntegrity("secret")
+	m := NewBindingRequest()
+	if err := key.AddTo(m); err != nil {
+		t.Fatal(err)
+	}
+	mac := m.Attributes[len(m.Attributes)-1].Value
+	for i := range mac {
+		mac[i] ^= 0x01
+		if err := key.Check(m); err != ErrIntegrityMismatch {
+			t.Fatalf("HMAC altered at byte %d: got %v, want %v", i, err, ErrIntegrityMismatch)
+		}
+		mac[i] ^= 0x01
+	}
+	if err := key.Check(m); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -76,11 +76,23 @@
 //   - XOR-MAPPED-ADDRESS attribute
 //   - Transaction ID generation and validation
 //   - Magic cookie validation
-//   - IPv4 address support
+//   - IPv4 and IPv6 addresses, served on a single dual-stack socket
 //
 // The library follows RFC 5389 specifications and includes proper error handling
 // for malformed messages, network issues, and protocol violations.
 //
+// Portability:
+//
+// The package is pure Go and builds on every platform supported by the Go
+// toolchain. Platform-specific fast paths, such as the kernel drop counters
+// of ReadDropStats, live in files constrained by build tags (drops_linux.go)
+// next to a portable fallback (drops_other.go) with the same API, so that
+// callers never need build tags of their own. On js/wasm the package is
+// supported client-side only: messages can be encoded and decoded, and a
+// Client created with NewClientWithTransport runs discovery through a relay
+// over HTTP (HTTPTransport) or a WebSocket (DialWebSocket), as the runtime
+// offers no UDP sockets.
+//
 // For more information about the STUN protocol, see RFC 5389:
 // https://tools.ietf.org/html/rfc5389
 package stun
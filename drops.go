@@ -0,0 +1,61 @@
+package stun
+
+import (
+	"errors"
+	"net"
+	"time"
+)
+
+// DropStats holds kernel-level counters of datagrams dropped before the
+// application could read them, which the read loop never observes.
+type DropStats struct {
+	// SocketDrops is the drop counter of the listening socket itself,
+	// typically caused by a full receive buffer.
+	SocketDrops uint64
+	// UDPInErrors is the system-wide count of UDP datagrams that could not be delivered.
+	UDPInErrors uint64
+	// UDPRcvbufErrors is the system-wide count of UDP datagrams dropped
+	// because a receive buffer was full.
+	UDPRcvbufErrors uint64
+}
+
+// watchDrops samples the kernel drop counters of conn every interval until
+// done is closed, publishing them as metrics and logging a warning whenever
+// the socket drop counter grows.
+func (s *Server) watchDrops(conn *net.UDPConn, interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var last DropStats
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+
+		stats, err := ReadDropStats(conn)
+		if err != nil {
+			s.logger.LogError("Failed to read kernel drop statistics", err, map[string]interface{}{
+				"local_addr": conn.LocalAddr().String(),
+			})
+			if errors.Is(err, ErrDropStatsUnsupported) {
+				return
+			}
+			continue
+		}
+
+		setGauge(MetricSocketDrops, int64(stats.SocketDrops))
+		setGauge(MetricUDPInErrors, int64(stats.UDPInErrors))
+		setGauge(MetricUDPRcvbufErrors, int64(stats.UDPRcvbufErrors))
+
+		if stats.SocketDrops > last.SocketDrops {
+			s.logger.Warn("Kernel dropped UDP packets", map[string]interface{}{
+				"local_addr": conn.LocalAddr().String(),
+				"dropped":    stats.SocketDrops - last.SocketDrops,
+				"total":      stats.SocketDrops,
+			})
+		}
+		last = stats
+	}
+}
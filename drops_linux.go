@@ -0,0 +1,106 @@
+//go:build linux
+
+package stun
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ReadDropStats reads the kernel drop counters for conn from /proc/net/udp,
+// /proc/net/udp6 and /proc/net/snmp.
+func ReadDropStats(conn *net.UDPConn) (DropStats, error) {
+	var stats DropStats
+
+	port, _, err := GetPortAndIPFromAddr(conn.LocalAddr())
+	if err != nil {
+		return stats, err
+	}
+
+	for _, path := range []string{"/proc/net/udp", "/proc/net/udp6"} {
+		drops, err := readSocketDrops(path, port)
+		if err != nil && !os.IsNotExist(err) {
+			return stats, err
+		}
+		stats.SocketDrops += drops
+	}
+
+	stats.UDPInErrors, stats.UDPRcvbufErrors, err = readUDPErrors("/proc/net/snmp")
+	if err != nil {
+		return stats, err
+	}
+	return stats, nil
+}
+
+// readSocketDrops sums the "drops" column of the sockets bound to port in a
+// /proc/net/udp formatted file.
+func readSocketDrops(path string, port int) (uint64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	suffix := fmt.Sprintf(":%04X", port)
+	var drops uint64
+
+	scanner := bufio.NewScanner(f)
+	scanner.Scan() // Skip the header line
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 13 || !strings.HasSuffix(fields[1], suffix) {
+			continue
+		}
+		n, err := strconv.ParseUint(fields[len(fields)-1], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid drops column in %s: %v", path, err)
+		}
+		drops += n
+	}
+	return drops, scanner.Err()
+}
+
+// readUDPErrors returns the InErrors and RcvbufErrors counters of the "Udp:"
+// section of /proc/net/snmp.
+func readUDPErrors(path string) (inErrors, rcvbufErrors uint64, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+
+	var names []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || fields[0] != "Udp:" {
+			continue
+		}
+		// The section is a header line of counter names followed by a line of values
+		if names == nil {
+			names = fields
+			continue
+		}
+		for i := 1; i < len(fields) && i < len(names); i++ {
+			v, err := strconv.ParseUint(fields[i], 10, 64)
+			if err != nil {
+				return 0, 0, fmt.Errorf("invalid %s counter in %s: %v", names[i], path, err)
+			}
+			switch names[i] {
+			case "InErrors":
+				inErrors = v
+			case "RcvbufErrors":
+				rcvbufErrors = v
+			}
+		}
+		return inErrors, rcvbufErrors, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, 0, err
+	}
+	return 0, 0, fmt.Errorf("no Udp section in %s", path)
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package stun
+
+import "net"
+
+// ReadDropStats is only implemented on Linux.
+func ReadDropStats(conn *net.UDPConn) (DropStats, error) {
+	return DropStats{}, ErrDropStatsUnsupported
+}
@@ -0,0 +1,220 @@
+package stun
+
+import "fmt"
+
+// MaxReasonLength is the maximum size in bytes of the reason phrase of an
+// ERROR-CODE attribute (RFC 5389 §15.6), which must also have fewer than 128
+// characters.
+const MaxReasonLength = 763
+
+// Error codes of the ERROR-CODE attribute defined by RFC 5389 §15.6, RFC 5766
+// §15, RFC 5245 §21.3 and RFC 8489 §18.3.
+const (
+	CodeTryAlternate         = 300
+	CodeBadRequest           = 400
+	CodeUnauthorized         = 401
+	CodeForbidden            = 403
+	CodeUnknownAttribute     = 420
+	CodeAllocationMismatch   = 437
+	CodeStaleNonce           = 438
+	CodeWrongCredentials     = 441
+	CodeUnsupportedTransport = 442
+	CodeAllocationQuota      = 486
+	CodeRoleConflict         = 487
+	CodeServerError          = 500
+	CodeInsufficientCapacity = 508
+)
+
+// errorReasons are the reason phrases suggested by the RFCs for each code.
+var errorReasons = map[int]string{
+	CodeTryAlternate:         "Try Alternate",
+	CodeBadRequest:           "Bad Request",
+	CodeUnauthorized:         "Unauthorized",
+	CodeForbidden:            "Forbidden",
+	CodeUnknownAttribute:     "Unknown Attribute",
+	CodeAllocationMismatch:   "Allocation Mismatch",
+	CodeStaleNonce:           "Stale Nonce",
+	CodeWrongCredentials:     "Wrong Credentials",
+	CodeUnsupportedTransport: "Unsupported Transport Protocol",
+	CodeAllocationQuota:      "Allocation Quota Reached",
+	CodeRoleConflict:         "Role Conflict",
+	CodeServerError:          "Server Error",
+	CodeInsufficientCapacity: "Insufficient Capacity",
+}
+
+// ErrorReason returns the default reason phrase of code, or an empty string
+// for codes without one.
+func ErrorReason(code int) string {
+	return errorReasons[code]
+}
+
+// ErrorCodeAttribute is the value of an ERROR-CODE attribute (RFC 5389 §15.6):
+// a numeric code in the range 300-699 and a UTF-8 reason phrase.
+//
+//	 0                   1                   2                   3
+//	 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1
+//	+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+//	|           Reserved, should be 0         |Class|     Number    |
+//	+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+//	|      Reason Phrase (variable)                                ..
+//	+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+type ErrorCodeAttribute struct {
+	Code   int
+	Reason string
+}
+
+// AddTo appends the error code to m as an ERROR-CODE attribute.
+//
+// Returns a *TextLengthError, matching ErrAttrTooLong, if the reason phrase
+// has 128 characters or more or exceeds MaxReasonLength bytes, and an error
+// wrapping ErrInvalidUTF8 if it is not valid UTF-8.
+func (e ErrorCodeAttribute) AddTo(m *Message) error {
+	if e.Code < 300 || e.Code > 699 {
+		return fmt.Errorf("invalid error code: %d", e.Code)
+	}
+	if err := reasonText.Check(e.Reason); err != nil {
+		return err
+	}
+	value := make([]byte, ErrorCodeLength+len(e.Reason))
+	value[2] = byte(e.Code / 100)
+	value[3] = byte(e.Code % 100)
+	copy(value[ErrorCodeLength:], e.Reason)
+	return m.Add(ErrorCode, value)
+}
+
+// GetFrom decodes the ERROR-CODE attribute of m into e.
+//
+// Returns ErrAttrNotFound if m has no ERROR-CODE attribute, and the errors of
+// AddTo if the received reason phrase is invalid.
+func (e *ErrorCodeAttribute) GetFrom(m *Message) error {
+	attr, ok := m.GetAttr(ErrorCode)
+	if !ok {
+		return ErrAttrNotFound
+	}
+	value := attr.rawValue()
+	if len(value) < ErrorCodeLength {
+		return ErrShortBuffer
+	}
+	reason := string(value[ErrorCodeLength:])
+	if err := reasonText.Check(reason); err != nil {
+		return err
+	}
+	e.Code = int(value[2]&0x07)*100 + int(value[3])
+	e.Reason = reason
+	return nil
+}
+
+// Error implements the error interface.
+func (e ErrorCodeAttribute) Error() string {
+	return fmt.Sprintf("%d %s", e.Code, e.Reason)
+}
+
+// ResponseError is the error of GetXorAddr for a message that is not a
+// success response. It wraps ErrNotSuccessResponse and, for an error response
+// with a valid ERROR-CODE, that error code, so that the caller can tell a
+// rejected request, e.g. a 401 to retry with credentials, from a message of
+// the wrong class.
+//
+// Example:
+//
+//	addr, err := resp.GetXorAddr()
+//	var code stun.ErrorCodeAttribute
+//	if errors.As(err, &code) && code.Code == stun.CodeUnauthorized {
+//		// retry with credentials
+//	}
+type ResponseError struct {
+	Type      MessageType
+	ErrorCode *ErrorCodeAttribute // nil unless the message has a valid ERROR-CODE
+}
+
+// newResponseError returns the *ResponseError of m.
+func newResponseError(m *Message) *ResponseError {
+	e := &ResponseError{Type: m.Header.Type}
+	var code ErrorCodeAttribute
+	if m.Header.Type.Class() == ClassErrorResponse && code.GetFrom(m) == nil {
+		e.ErrorCode = &code
+	}
+	return e
+}
+
+// Error implements the error interface.
+func (e *ResponseError) Error() string {
+	if e.ErrorCode != nil {
+		return fmt.Sprintf("%v: %s %v", ErrNotSuccessResponse, e.Type, *e.ErrorCode)
+	}
+	return fmt.Sprintf("%v: %s", ErrNotSuccessResponse, e.Type)
+}
+
+// Unwrap returns ErrNotSuccessResponse and, if any, the error code.
+func (e *ResponseError) Unwrap() []error {
+	if e.ErrorCode != nil {
+		return []error{ErrNotSuccessResponse, *e.ErrorCode}
+	}
+	return []error{ErrNotSuccessResponse}
+}
+
+// UnknownAttributes is the value of an UNKNOWN-ATTRIBUTES attribute
+// (RFC 5389 §15.9): the comprehension-required attribute types a server did
+// not understand, sent with a 420 error response.
+type UnknownAttributes []StunAttribute
+
+// AddTo appends the list to m as an UNKNOWN-ATTRIBUTES attribute.
+func (u UnknownAttributes) AddTo(m *Message) error {
+	value := make([]byte, 2*len(u))
+	for i, t := range u {
+		value[2*i] = byte(t >> 8)
+		value[2*i+1] = byte(t & 0xFF)
+	}
+	return m.Add(UnknownStunAttributes, value)
+}
+
+// GetFrom decodes the UNKNOWN-ATTRIBUTES attribute of m into u.
+//
+// Returns ErrAttrNotFound if m has no UNKNOWN-ATTRIBUTES attribute.
+func (u *UnknownAttributes) GetFrom(m *Message) error {
+	attr, ok := m.GetAttr(UnknownStunAttributes)
+	if !ok {
+		return ErrAttrNotFound
+	}
+	value := attr.rawValue()
+	list := make(UnknownAttributes, 0, len(value)/2)
+	for i := 0; i+1 < len(value); i += 2 {
+		list = append(list, StunAttribute(uint16(value[i])<<8|uint16(value[i+1])))
+	}
+	*u = list
+	return nil
+}
+
+// NewErrorResponse builds the error response to req with the given code and
+// its default reason phrase: same method and transaction ID, error response
+// class and an ERROR-CODE attribute. Further attributes, such as
+// UNKNOWN-ATTRIBUTES for a 420, can be added to the returned message.
+//
+// Returns an error if code is not in the range 300-699.
+//
+// Example:
+//
+//	resp, err := stun.NewErrorResponse(req.Message, stun.CodeStaleNonce)
+//	if err != nil {
+//		return nil, err
+//	}
+//	resp.SetNonce(nonce)
+//	return resp, nil
+func NewErrorResponse(req *Message, code int) (*Message, error) {
+	return newErrorResponse(req, code, ErrorReason(code))
+}
+
+// newErrorResponse is NewErrorResponse with a custom reason phrase.
+func newErrorResponse(req *Message, code int, reason string) (*Message, error) {
+	resp := &Message{
+		Header: Header{
+			Type:          NewMessageType(req.Header.Type.Method(), ClassErrorResponse),
+			MagicCookie:   responseCookie(req),
+			TransactionID: req.Header.TransactionID,
+		},
+	}
+	if err := (ErrorCodeAttribute{Code: code, Reason: reason}).AddTo(resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
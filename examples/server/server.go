@@ -3,19 +3,19 @@ package main
 import (
 	"time"
 
-	stunlib "github.com/lai0xn/stun"
+	"github.com/lai0xn/stun"
 )
 
 func main() {
 	// Create a custom logger with JSON format for production
-	logger := stunlib.NewLogger(stunlib.LoggerConfig{
-		Level:      stunlib.InfoLevel,
+	logger := stun.NewLogger(stun.LoggerConfig{
+		Level:      stun.InfoLevel,
 		Format:     "json",
 		Output:     "stdout",
 		ShowCaller: true,
 	})
 
-	srv := stunlib.NewServer(stunlib.ServerConfig{
+	srv := stun.NewServer(stun.ServerConfig{
 		Addr:    "127.0.0.1",
 		Port:    "3478",
 		Timeout: 30 * time.Second,
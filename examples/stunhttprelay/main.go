@@ -0,0 +1,92 @@
+// Command stunhttprelay relays STUN messages POSTed over HTTP to a plain UDP
+// STUN server, for clients without UDP sockets such as Go programs compiled
+// to WebAssembly for browsers (see stun.HTTPTransport).
+//
+// Every relayed datagram starts with a PROXY protocol version 2 header
+// carrying the address of the HTTP client, so a backend configured with
+// ServerConfig.TrustedProxies reflects that address in its responses:
+//
+//	stunhttprelay -listen :8080 -backend 127.0.0.1:3478
+package main
+
+import (
+	"flag"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/lai0xn/stun"
+)
+
+// maxMessageSize bounds the requests and responses relayed.
+const maxMessageSize = 2048
+
+func main() {
+	listen := flag.String("listen", ":8080", "HTTP address to accept requests on")
+	backend := flag.String("backend", "127.0.0.1:3478", "UDP address of the STUN server")
+	timeout := flag.Duration("timeout", 2*time.Second, "how long to wait for the backend response")
+	origin := flag.String("allow-origin", "*", "Access-Control-Allow-Origin of responses, for browser clients")
+	flag.Parse()
+
+	backendAddr, err := net.ResolveUDPAddr("udp", *backend)
+	if err != nil {
+		log.Fatal(err)
+	}
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", *origin)
+		if r.Method != http.MethodPost {
+			http.Error(w, "POST a STUN message", http.StatusMethodNotAllowed)
+			return
+		}
+		resp, err := relay(r, backendAddr, *timeout)
+		if err != nil {
+			log.Printf("%s: %v", r.RemoteAddr, err)
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write(resp)
+	})
+	log.Printf("relaying http:%s to udp:%s", *listen, backendAddr)
+	log.Fatal(http.ListenAndServe(*listen, nil))
+}
+
+// relay forwards the message in the body of r to the backend over a
+// dedicated UDP socket and returns the response.
+func relay(r *http.Request, backendAddr *net.UDPAddr, timeout time.Duration) ([]byte, error) {
+	msg, err := io.ReadAll(io.LimitReader(r.Body, maxMessageSize))
+	if err != nil {
+		return nil, err
+	}
+	client, err := net.ResolveTCPAddr("tcp", r.RemoteAddr)
+	if err != nil {
+		return nil, err
+	}
+	local, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr)
+	if !ok {
+		return nil, net.UnknownNetworkError("no local address")
+	}
+
+	udp, err := net.DialUDP("udp", nil, backendAddr)
+	if err != nil {
+		return nil, err
+	}
+	defer udp.Close()
+
+	pkt, err := stun.AppendProxyHeader(nil, client, local)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := udp.Write(append(pkt, msg...)); err != nil {
+		return nil, err
+	}
+	udp.SetReadDeadline(time.Now().Add(timeout))
+	buff := make([]byte, maxMessageSize)
+	n, err := udp.Read(buff)
+	if err != nil {
+		return nil, err
+	}
+	return buff[:n], nil
+}
@@ -0,0 +1,121 @@
+// Command stunsproxy terminates stuns: (STUN over TLS) connections and relays
+// the STUN messages they carry to a plain UDP STUN server, for clients on
+// networks that only let TLS through.
+//
+// Every relayed datagram starts with a PROXY protocol version 2 header
+// carrying the address of the TLS client, so a backend configured with
+// ServerConfig.TrustedProxies reflects that address in its responses:
+//
+//	stunsproxy -listen :5349 -cert cert.pem -key key.pem -backend 127.0.0.1:3478
+package main
+
+import (
+	"crypto/tls"
+	"encoding/binary"
+	"flag"
+	"io"
+	"log"
+	"net"
+
+	"github.com/lai0xn/stun"
+)
+
+// headerLength is the size of the STUN message header, whose length field
+// frames messages on stream transports (RFC 5389 §7.2.2).
+const headerLength = 20
+
+func main() {
+	listen := flag.String("listen", ":5349", "TLS address to accept stuns: clients on")
+	certFile := flag.String("cert", "cert.pem", "TLS certificate file")
+	keyFile := flag.String("key", "key.pem", "TLS private key file")
+	backend := flag.String("backend", "127.0.0.1:3478", "UDP address of the STUN server")
+	flag.Parse()
+
+	cert, err := tls.LoadX509KeyPair(*certFile, *keyFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	backendAddr, err := net.ResolveUDPAddr("udp", *backend)
+	if err != nil {
+		log.Fatal(err)
+	}
+	ln, err := tls.Listen("tcp", *listen, &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("relaying stuns:%s to udp:%s", ln.Addr(), backendAddr)
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			log.Print(err)
+			continue
+		}
+		go relay(conn, backendAddr)
+	}
+}
+
+// relay forwards the messages of the client connection to the backend over
+// a dedicated UDP socket, so that responses can be told apart per client, and
+// writes the responses back to the client.
+func relay(client net.Conn, backendAddr *net.UDPAddr) {
+	defer client.Close()
+
+	udp, err := net.DialUDP("udp", nil, backendAddr)
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	defer udp.Close()
+
+	go func() {
+		buff := make([]byte, 2048)
+		for {
+			n, err := udp.Read(buff)
+			if err != nil {
+				return
+			}
+			if _, err := client.Write(buff[:n]); err != nil {
+				return
+			}
+		}
+	}()
+
+	header, err := stun.AppendProxyHeader(nil, client.RemoteAddr(), client.LocalAddr())
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	for {
+		msg, err := readMessage(client)
+		if err != nil {
+			if err != io.EOF {
+				log.Printf("%s: %v", client.RemoteAddr(), err)
+			}
+			return
+		}
+		if _, err := udp.Write(append(header[:len(header):len(header)], msg...)); err != nil {
+			log.Print(err)
+			return
+		}
+	}
+}
+
+// readMessage reads one STUN message from the stream, using the length of
+// its header.
+func readMessage(r io.Reader) ([]byte, error) {
+	header := make([]byte, headerLength)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	length := int(binary.BigEndian.Uint16(header[2:4]))
+	msg := make([]byte, headerLength+length)
+	copy(msg, header)
+	if _, err := io.ReadFull(r, msg[headerLength:]); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
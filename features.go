@@ -0,0 +1,34 @@
+package stun
+
+// Feature flag names understood by the server. Flags let operators enable
+// experimental behaviors per listener through ServerConfig.Features without
+// code changes.
+const (
+	// FeatureCapabilities advertises the CAPABILITIES attribute in Binding
+	// responses, derived from the server configuration when
+	// ServerConfig.Capabilities is not set.
+	FeatureCapabilities = "capabilities"
+	// FeatureRFC8489 enables the behaviors introduced by RFC 8489.
+	FeatureRFC8489 = "rfc8489"
+	// FeatureLegacy3489 enables compatibility with classic RFC 3489 STUN.
+	FeatureLegacy3489 = "legacy-3489"
+)
+
+// FeatureFlags is a set of named feature flags. Flags that are not present
+// are disabled.
+type FeatureFlags map[string]bool
+
+// Enabled reports whether the flag name is enabled.
+func (f FeatureFlags) Enabled(name string) bool {
+	return f[name]
+}
+
+// clone returns a copy of the flags, so the server is not affected by later
+// changes to the configuration map.
+func (f FeatureFlags) clone() FeatureFlags {
+	c := make(FeatureFlags, len(f))
+	for name, enabled := range f {
+		c[name] = enabled
+	}
+	return c
+}
@@ -0,0 +1,100 @@
+package stun
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+)
+
+// fingerprintXOR is XORed with the CRC-32 of a message to form its
+// FINGERPRINT (RFC 5389 §15.5).
+const fingerprintXOR = 0x5354554e
+
+// fingerprintSize is the encoded size of the FINGERPRINT attribute.
+const fingerprintSize = 8
+
+// Fingerprint returns the value of the FINGERPRINT attribute of the encoded
+// message msg, which holds the message up to, and excluding, that attribute.
+// The length field of the header must already account for the 8 bytes of
+// the attribute. FingerprintAttribute adds it to a Message.
+func Fingerprint(msg []byte) uint32 {
+	return crc32.ChecksumIEEE(msg) ^ fingerprintXOR
+}
+
+// FingerprintAttribute is the FINGERPRINT attribute (RFC 5389 §15.5), which
+// must be the last attribute of a message.
+//
+// Example:
+//
+//	err := stun.Build(msg, stun.BindingRequest, stun.NewUsername("alice:bob"),
+//		stun.NewShortTermIntegrity("secret"), stun.FingerprintAttribute{})
+type FingerprintAttribute struct{}
+
+// AddTo appends the FINGERPRINT of m, computed over the message with its
+// attributes so far, as its last attribute.
+func (FingerprintAttribute) AddTo(m *Message) error {
+	input := m.appendIntegrityInput(nil, len(m.Attributes), m.attrsLength()+fingerprintSize, false)
+	value := make([]byte, 4)
+	binary.BigEndian.PutUint32(value, Fingerprint(input))
+	return m.Add(FingerprintAttr, value)
+}
+
+// Check verifies the FINGERPRINT attribute of m, which must be the last one.
+//
+// Returns ErrFingerprintMissing if the last attribute of m is not a
+// FINGERPRINT with a 4-byte value and ErrFingerprintMismatch if it does not
+// match.
+func (FingerprintAttribute) Check(m *Message) error {
+	n := len(m.Attributes) - 1
+	if n < 0 {
+		return ErrFingerprintMissing
+	}
+	last := &m.Attributes[n]
+	if last.Type != FingerprintAttr || last.Length != 4 || len(last.Value) < 4 {
+		return ErrFingerprintMissing
+	}
+	input := m.appendIntegrityInput(nil, n, m.attrsLength(), true)
+	if binary.BigEndian.Uint32(last.rawValue()) != Fingerprint(input) {
+		return ErrFingerprintMismatch
+	}
+	return nil
+}
+
+// CheckFingerprint verifies the raw buffer msg without parsing it: that it
+// starts with a STUN header carrying the magic cookie, that the last of the
+// attributes covered by the header length is FINGERPRINT, and that the
+// fingerprint matches. Demultiplexers sharing a socket between STUN and
+// other protocols can call it to cheaply tell STUN packets apart (RFC 7983).
+// Bytes past the length announced by the header are ignored.
+//
+// Returns ErrShortBuffer if msg is shorter than its header or the length it
+// announces, ErrNotSTUN if the two most significant bits of the first byte
+// are set, ErrInvalidCookie if the magic cookie is missing,
+// ErrFingerprintMissing if the message has no trailing FINGERPRINT and
+// ErrFingerprintMismatch if it does not match.
+func CheckFingerprint(msg []byte) error {
+	if len(msg) < 20 {
+		return ErrShortBuffer
+	}
+	if msg[0]&0xC0 != 0 {
+		return ErrNotSTUN
+	}
+	if binary.BigEndian.Uint32(msg[4:8]) != magicCookie {
+		return ErrInvalidCookie
+	}
+	end := 20 + int(binary.BigEndian.Uint16(msg[2:4]))
+	if end > len(msg) {
+		return ErrShortBuffer
+	}
+	if end-20 < fingerprintSize {
+		return ErrFingerprintMissing
+	}
+	attr := msg[end-fingerprintSize : end]
+	if StunAttribute(binary.BigEndian.Uint16(attr[0:2])) != FingerprintAttr ||
+		binary.BigEndian.Uint16(attr[2:4]) != 4 {
+		return ErrFingerprintMissing
+	}
+	if binary.BigEndian.Uint32(attr[4:8]) != Fingerprint(msg[:end-fingerprintSize]) {
+		return ErrFingerprintMismatch
+	}
+	return nil
+}
@@ -0,0 +1,24 @@
+package stun_test
+
+import (
+	"testing"
+
+	"github.com/lai0xn/stun"
+)
+
+func FuzzCheckFingerprint(f *testing.F) {
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if stun.CheckFingerprint(data) != nil {
+			return
+		}
+		// A buffer passing the check must decode to a message whose
+		// FINGERPRINT checks as well
+		m, err := stun.NewMessage(data)
+		if err != nil {
+			return
+		}
+		if err := (stun.FingerprintAttribute{}).Check(m); err != nil {
+			t.Fatalf("CheckFingerprint accepts %x, FingerprintAttribute.Check does not: %v", data, err)
+		}
+	})
+}
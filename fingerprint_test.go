@@ -0,0 +1,56 @@
+package stun
+
+import "testing"
+
+// fingerprinted returns an encoded Binding request ending with a
+// FINGERPRINT.
+func fingerprinted(t *testing.T) []byte {
+	t.Helper()
+	m := NewBindingRequest()
+	if err := (FingerprintAttribute{}).AddTo(m); err != nil {
+		t.Fatal(err)
+	}
+	return m.Encode()
+}
+
+func TestCheckFingerprint(t *testing.T) {
+	valid := fingerprinted(t)
+	edit := func(f func(b []byte) []byte) []byte {
+		return f(append([]byte(nil), valid...))
+	}
+	for _, tc := range []struct {
+		name string
+		msg  []byte
+		want error
+	}{
+		{"valid", valid, nil},
+		{"trailing bytes", edit(func(b []byte) []byte { return append(b, 0, 0, 0, 0) }), nil},
+		{"short header", valid[:19], ErrShortBuffer},
+		{"truncated", valid[:len(valid)-1], ErrShortBuffer},
+		{"first bits 10", edit(func(b []byte) []byte { b[0] |= 0x80; return b }), ErrNotSTUN},
+		{"first bits 01", edit(func(b []byte) []byte { b[0] |= 0x40; return b }), ErrNotSTUN},
+		{"no cookie", edit(func(b []byte) []byte { b[4] ^= 0xff; return b }), ErrInvalidCookie},
+		{"no attributes", edit(func(b []byte) []byte { b[3] = 0; return b[:20] }), ErrFingerprintMissing},
+		{"mismatch", edit(func(b []byte) []byte { b[len(b)-1] ^= 1; return b }), ErrFingerprintMismatch},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := CheckFingerprint(tc.msg); err != tc.want {
+				t.Fatalf("got %v, want %v", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestFingerprintCheckShortValue(t *testing.T) {
+	m := NewBindingRequest()
+	// Built by hand, announcing more bytes than the value holds
+	m.Attributes = append(m.Attributes, Attribute{
+		Type:         FingerprintAttr,
+		Length:       4,
+		PaddedLength: 4,
+		Value:        []byte{1, 2},
+	})
+	if err := (FingerprintAttribute{}).Check(m); err != ErrFingerprintMissing {
+		t.Fatalf("got %v, want %v", err, ErrFingerprintMissing)
+	}
+}
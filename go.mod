@@ -2,6 +2,9 @@ module github.com/lai0xn/stun
 
 go 1.23.2
 
-require github.com/sirupsen/logrus v1.9.3
+require (
+	github.com/sirupsen/logrus v1.9.3
+	golang.org/x/text v0.26.0
+)
 
 require golang.org/x/sys v0.0.0-20220715151400-c0bba94af5f8 // indirect
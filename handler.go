@@ -0,0 +1,54 @@
+package stun
+
+import "net"
+
+// Request is an inbound STUN message handed to a Handler, together with the
+// transport addresses it was received on.
+type Request struct {
+	Message    *Message
+	RemoteAddr *net.UDPAddr
+	LocalAddr  net.Addr
+
+	// conn is the socket the response is sent from. It starts as the socket
+	// the request was received on and may be switched by CHANGE-REQUEST.
+	conn *net.UDPConn
+	// features are the feature flags of the listener that received the request.
+	features FeatureFlags
+}
+
+// Feature reports whether the named feature flag is enabled on the listener
+// that received the request.
+//
+// Example:
+//
+//	if req.Feature(stun.FeatureRFC8489) {
+//		// Experimental RFC 8489 behavior
+//	}
+func (r *Request) Feature(name string) bool {
+	return r.features.Enabled(name)
+}
+
+// Handler builds the response to an inbound request. Returning a nil message
+// means no response is sent.
+type Handler func(req *Request) (*Message, error)
+
+// Middleware wraps a Handler to run logic before or after it, such as
+// authentication, response signing or metrics.
+//
+// Example:
+//
+//	logRequests := func(next stun.Handler) stun.Handler {
+//		return func(req *stun.Request) (*stun.Message, error) {
+//			log.Printf("request from %s", req.RemoteAddr)
+//			return next(req)
+//		}
+//	}
+type Middleware func(next Handler) Handler
+
+// chain wraps h with the middleware, the first one being the outermost.
+func chain(h Handler, middleware ...Middleware) Handler {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		h = middleware[i](h)
+	}
+	return h
+}
@@ -17,16 +17,33 @@ package stun
 
 // Header represents the STUN message header.
 type Header struct {
-	Type          MessageType // Type of STUN message (e.g., Binding Request, Binding Response)
-	Length        uint16      // Length of the message or attribute data
+	Type          MessageType   // Type of STUN message (e.g., Binding Request, Binding Response)
+	Length        uint16        // Length of the message or attribute data
 	MagicCookie   uint32
-	TransactionID [12]byte // 12-byte Transaction ID to uniquely identify the request/response
+	TransactionID TransactionID // 12-byte Transaction ID to uniquely identify the request/response
 }
 
-// DecodeHeader takes a byte slice (buff) and decodes it into a STUN message header.
-func decodeHeader(buff []byte) (*Header, error) {
-	// Create a new Header object to store the decoded values
-	header := new(Header)
+// decodeHeader takes a byte slice (buff) and decodes it into a STUN message header.
+// It returns ErrShortBuffer if buff is shorter than a header, and ErrNotSTUN
+// if the two most significant bits of the message type, reserved by RFC 5389
+// §6, are not zero. The header is returned by value, so decoding does not
+// allocate.
+func decodeHeader(buff []byte) (Header, error) {
+	var header Header
+	if err := header.decode(buff); err != nil {
+		return Header{}, err
+	}
+	return header, nil
+}
+
+// decode decodes buff into the header, in place, as decodeHeader does.
+func (header *Header) decode(buff []byte) error {
+	if len(buff) < headrLength {
+		return ErrShortBuffer
+	}
+	if buff[0]&0xC0 != 0 {
+		return ErrNotSTUN
+	}
 
 	// Decode the STUN message type (2 bytes) and assign it to header.Type
 	// Combine the first byte and second byte into a uint16 value using bitwise shifting
@@ -41,15 +58,10 @@ func decodeHeader(buff []byte) (*Header, error) {
 	// MagicCookie is a fixed 4-byte value, so we combine 4 bytes (from index 4 to 7)
 	// into a uint32 value using bitwise shifting and OR-ing the individual bytes
 	header.MagicCookie = uint32(uint32(buff[4])<<24 | uint32(buff[5])<<16 | uint32(buff[6])<<8 | uint32(buff[7]))
-	if header.MagicCookie != magicCookie {
-		return nil, ErrInvalidCookie
-	}
 	// Copy the remaining bytes (Transaction ID) into the header.TransactionID field
 	// The TransactionID is 12 bytes long, so we copy from index 8 to the end of the buffer
 	copy(header.TransactionID[:], buff[8:])
-
-	// Return the decoded header
-	return header, nil
+	return nil
 }
 
 func encodeHeader(header Header) []byte {
@@ -79,3 +91,13 @@ func encodeHeader(header Header) []byte {
 func (h *Header) Encode() []byte {
 	return encodeHeader(*h)
 }
+
+// appendTo appends the 20-byte encoded header to buff.
+func (h *Header) appendTo(buff []byte) []byte {
+	buff = append(buff,
+		byte(h.Type>>8), byte(h.Type&0xff),
+		byte(h.Length>>8), byte(h.Length&0xff),
+		byte(h.MagicCookie>>24), byte(h.MagicCookie>>16), byte(h.MagicCookie>>8), byte(h.MagicCookie&0xff),
+	)
+	return append(buff, h.TransactionID[:]...)
+}
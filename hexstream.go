@@ -0,0 +1,166 @@
+package stun
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"iter"
+	"strings"
+)
+
+// maxHexLine bounds the lines DecodeHexStream reads. Longer lines end the
+// scan with bufio.ErrTooLong.
+const maxHexLine = 1 << 20
+
+// LoggedMessage is a STUN message found in text by DecodeHexStream.
+type LoggedMessage struct {
+	// Line is the number of the line holding the message, starting at 1.
+	Line int
+	// Text is that line, for the context logged around the message.
+	Text string
+	// Raw is the encoded message.
+	Raw []byte
+	// Message is the decoded message.
+	Message *Message
+}
+
+// DecodeHexStream scans text read from r, such as syslog or application
+// logs, for hex encoded STUN messages and yields them decoded, in order, for
+// postmortems where only logs were kept. Blobs may be written in lower or
+// upper case, and their bytes or groups of bytes may be separated by single
+// spaces, colons or dashes, or prefixed with 0x:
+//
+//	Jan 12 10:01:02 edge stund[812]: rx 000100002112a442b7e7a701bc34d686fa87dfae
+//	dump: 00 01 00 00 21 12 A4 42 B7 E7 A7 01 BC 34 D6 86 FA 87 DF AE
+//
+// A message is recognized by its magic cookie and must be complete and well
+// formed: its attributes must exactly fill the length of its header. Hex
+// surrounding it on the line, e.g. a prefix added by the logger, is skipped,
+// and a blob may hold several messages. Lines holding none are ignored.
+//
+// The iteration stops at the first read error, yielded with a zero
+// LoggedMessage.
+//
+// Example:
+//
+//	for lm, err := range stun.DecodeHexStream(os.Stdin) {
+//		if err != nil {
+//			log.Fatal(err)
+//		}
+//		fmt.Println(lm.Line, lm.Message.Header.Type)
+//	}
+func DecodeHexStream(r io.Reader) iter.Seq2[LoggedMessage, error] {
+	return func(yield func(LoggedMessage, error) bool) {
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(nil, maxHexLine)
+		for n := 1; scanner.Scan(); n++ {
+			line := scanner.Text()
+			for _, blob := range hexBlobs(line) {
+				for _, raw := range findMessages(blob) {
+					m, err := NewMessage(raw)
+					if err != nil {
+						continue
+					}
+					if !yield(LoggedMessage{Line: n, Text: line, Raw: raw, Message: m}, nil) {
+						return
+					}
+				}
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			yield(LoggedMessage{}, err)
+		}
+	}
+}
+
+// hexBlobs returns the runs of hex digits of line, lower cased, with the
+// separators and 0x prefixes allowed by DecodeHexStream removed. Runs too
+// short to hold a STUN header are omitted.
+func hexBlobs(line string) []string {
+	var blobs []string
+	var blob strings.Builder
+	flush := func() {
+		if blob.Len() >= 40 {
+			blobs = append(blobs, blob.String())
+		}
+		blob.Reset()
+	}
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case c == '0' && i+2 < len(line) && (line[i+1] == 'x' || line[i+1] == 'X') &&
+			isHexDigit(line[i+2]) && (i == 0 || !isHexDigit(line[i-1])):
+			i++ // 0x prefix
+		case isHexDigit(c):
+			if c >= 'A' && c <= 'F' {
+				c += 'a' - 'A'
+			}
+			blob.WriteByte(c)
+		case (c == ' ' || c == ':' || c == '-') && blob.Len() > 0 && i+1 < len(line) &&
+			isHexDigit(line[i+1]):
+			// Separator inside the blob
+		default:
+			flush()
+		}
+	}
+	flush()
+	return blobs
+}
+
+// isHexDigit reports whether c is a hexadecimal digit.
+func isHexDigit(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
+
+// findMessages returns the well-formed STUN messages encoded in the hex
+// digits of blob, located by their magic cookie.
+func findMessages(blob string) [][]byte {
+	var msgs [][]byte
+	for from := 0; ; {
+		i := strings.Index(blob[from:], "2112a442")
+		if i < 0 {
+			return msgs
+		}
+		start := from + i - 8
+		from += i + 1
+		if start < 0 {
+			continue
+		}
+		digits := blob[start:]
+		buf, err := hex.DecodeString(digits[:len(digits)&^1])
+		if err != nil {
+			continue
+		}
+		size, ok := messageSize(buf)
+		if !ok {
+			continue
+		}
+		msgs = append(msgs, buf[:size])
+		from = start + 2*size
+	}
+}
+
+// messageSize returns the size of the STUN message at the start of buf, if
+// it starts with a header carrying the magic cookie followed by attributes
+// that exactly fill the length it announces.
+func messageSize(buf []byte) (int, bool) {
+	if len(buf) < 20 || buf[0]&0xC0 != 0 || binary.BigEndian.Uint32(buf[4:8]) != magicCookie {
+		return 0, false
+	}
+	length := int(binary.BigEndian.Uint16(buf[2:4]))
+	if length%4 != 0 || len(buf) < 20+length {
+		return 0, false
+	}
+	for attrs := buf[20 : 20+length]; len(attrs) > 0; {
+		if len(attrs) < 4 {
+			return 0, false
+		}
+		size := 4 + paddedLength(int(binary.BigEndian.Uint16(attrs[2:4])))
+		if size > len(attrs) {
+			return 0, false
+		}
+		attrs = attrs[size:]
+	}
+	return 20 + length, true
+}
@@ -0,0 +1,124 @@
+package stun
+
+import (
+	"container/list"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"hash"
+	"hash/maphash"
+	"sync"
+)
+
+// maxHMACPools bounds the number of credentials for which HMAC states are
+// cached. The least recently used credentials are evicted beyond it, so
+// long-term keys and per-session access-token keys do not accumulate over
+// the life of the process.
+const maxHMACPools = 1024
+
+// hmacShards is the number of independently locked parts of the cache, so
+// that concurrent HMACs under different keys rarely contend.
+const hmacShards = 16
+
+// hmacAlgorithm selects the hash function of an HMAC state.
+type hmacAlgorithm uint8
+
+const (
+	hmacSHA1   hmacAlgorithm = iota // MESSAGE-INTEGRITY
+	hmacSHA256                      // MESSAGE-INTEGRITY-SHA256
+)
+
+// hash returns the constructor of the hash function of the algorithm.
+func (a hmacAlgorithm) hash() func() hash.Hash {
+	if a == hmacSHA256 {
+		return sha256.New
+	}
+	return sha1.New
+}
+
+// hmacPoolKey identifies a pool of HMAC states by algorithm and key.
+type hmacPoolKey struct {
+	alg hmacAlgorithm
+	key string
+}
+
+// hmacPoolEntry is a pool of the cache, with the key it is stored under.
+type hmacPoolEntry struct {
+	key  hmacPoolKey
+	pool *sync.Pool
+}
+
+// hmacShard is a part of the cache: an LRU list of pools, most recently
+// used first, indexed by key.
+type hmacShard struct {
+	mu    sync.Mutex
+	lru   list.List
+	pools map[hmacPoolKey]*list.Element
+}
+
+// hmacPools keeps a pool of keyed HMAC states per credential, so that the
+// integrity path of a busy authenticated server does not allocate a new
+// HMAC (and its inner and outer hashes) for every packet.
+var hmacPools struct {
+	seed   maphash.Seed
+	shards [hmacShards]hmacShard
+}
+
+func init() {
+	hmacPools.seed = maphash.MakeSeed()
+	for i := range hmacPools.shards {
+		hmacPools.shards[i].pools = make(map[hmacPoolKey]*list.Element)
+	}
+}
+
+// integrityBufPool holds the scratch buffers used to serialize the part of a
+// message covered by MESSAGE-INTEGRITY.
+var integrityBufPool = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, 0, 512)
+		return &b
+	},
+}
+
+// acquireHMAC returns a reset HMAC state of the algorithm keyed with key, and
+// the pool to hand it back to with releaseHMAC once the digest has been read.
+func acquireHMAC(alg hmacAlgorithm, key []byte) (hash.Hash, *sync.Pool) {
+	p := hmacPool(alg, key)
+	h := p.Get().(hash.Hash)
+	h.Reset()
+	return h, p
+}
+
+// releaseHMAC returns h to the pool it was acquired from.
+func releaseHMAC(p *sync.Pool, h hash.Hash) {
+	p.Put(h)
+}
+
+// hmacPool returns the pool for the algorithm and key, creating it and
+// evicting the least recently used pool of its shard if needed.
+func hmacPool(alg hmacAlgorithm, key []byte) *sync.Pool {
+	s := &hmacPools.shards[maphash.Bytes(hmacPools.seed, key)%hmacShards]
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.pools[hmacPoolKey{alg, string(key)}]; ok {
+		s.lru.MoveToFront(e)
+		return e.Value.(*hmacPoolEntry).pool
+	}
+	if s.lru.Len() >= maxHMACPools/hmacShards {
+		oldest := s.lru.Back()
+		s.lru.Remove(oldest)
+		delete(s.pools, oldest.Value.(*hmacPoolEntry).key)
+	}
+	k := append([]byte(nil), key...)
+	entry := &hmacPoolEntry{
+		key: hmacPoolKey{alg, string(k)},
+		pool: &sync.Pool{
+			New: func() interface{} {
+				return hmac.New(alg.hash(), k)
+			},
+		},
+	}
+	s.pools[entry.key] = s.lru.PushFront(entry)
+	return entry.pool
+}
@@ -0,0 +1,102 @@
+package stun
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"fmt"
+	"testing"
+)
+
+// hmacPoolCount returns the number of pools in the cache.
+func hmacPoolCount() int {
+	n := 0
+	for i := range hmacPools.shards {
+		s := &hmacPools.shards[i]
+		s.mu.Lock()
+		n += s.lru.Len()
+		s.mu.Unlock()
+	}
+	return n
+}
+
+func TestHMACPoolBounded(t *testing.T) {
+	hot := []byte("hot key")
+	for i := 0; i < 4*maxHMACPools; i++ {
+		hmacPool(hmacSHA1, []byte(fmt.Sprintf("key %d", i)))
+		hmacPool(hmacSHA1, hot)
+	}
+	if n := hmacPoolCount(); n > maxHMACPools {
+		t.Fatalf("%d pools cached, want at most %d", n, maxHMACPools)
+	}
+	if hmacPool(hmacSHA1, hot) != hmacPool(hmacSHA1, hot) {
+		t.Fatal("the pool of a recently used key was evicted")
+	}
+}
+
+func TestHMACPoolDigest(t *testing.T) {
+	key := []byte("secret")
+	for i := 0; i < 3; i++ {
+		h, p := acquireHMAC(hmacSHA1, key)
+		h.Write([]byte("input"))
+		got := h.Sum(nil)
+		releaseHMAC(p, h)
+
+		want := hmac.New(sha1.New, key)
+		want.Write([]byte("input"))
+		if !hmac.Equal(got, want.Sum(nil)) {
+			t.Fatalf("pooled HMAC %x differs from hmac.New %x", got, want.Sum(nil))
+		}
+	}
+}
+
+// benchmarkRequest returns a Binding request carrying MESSAGE-INTEGRITY
+// computed with key.
+func benchmarkRequest(b *testing.B, key Integrity) *Message {
+	b.Helper()
+	m := NewBindingRequest()
+	if err := (UsernameAttribute("alice:bob")).AddTo(m); err != nil {
+		b.Fatal(err)
+	}
+	if err := key.AddTo(m); err != nil {
+		b.Fatal(err)
+	}
+	return m
+}
+
+func BenchmarkHMAC(b *testing.B) {
+	key := []byte("benchmark key")
+	input := make([]byte, 120)
+
+	b.Run("pooled", func(b *testing.B) {
+		b.ReportAllocs()
+		var sum []byte
+		for i := 0; i < b.N; i++ {
+			h, p := acquireHMAC(hmacSHA1, key)
+			h.Write(input)
+			sum = h.Sum(sum[:0])
+			releaseHMAC(p, h)
+		}
+	})
+	b.Run("hmac.New", func(b *testing.B) {
+		b.ReportAllocs()
+		var sum []byte
+		for i := 0; i < b.N; i++ {
+			h := hmac.New(sha1.New, key)
+			h.Write(input)
+			sum = h.Sum(sum[:0])
+		}
+	})
+}
+
+func BenchmarkIntegrityCheck(b *testing.B) {
+	key := NewLongTermIntegrity("alice", "example.org", "secret")
+	m := benchmarkRequest(b, key)
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if err := key.Check(m); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,123 @@
+package stun
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// EncodeHook transforms a message right before it is serialized by Encode.
+// It works on a deep copy of the message (see Message.Clone), so the caller's
+// Message and attribute values are never modified, and Header.Length is
+// recomputed after the hooks have run.
+//
+// MESSAGE-INTEGRITY, MESSAGE-INTEGRITY-SHA256 and FINGERPRINT are computed
+// by their AddTo when added, over the attributes as they are then, not as
+// hooks later serialize them: a hook changing the attributes they cover
+// makes them fail to verify at the receiver.
+type EncodeHook func(m *Message)
+
+// DecodeHook transforms a message right after NewMessage has parsed it.
+// Returning an error makes NewMessage fail with that error. Integrity and
+// fingerprint checks run on the transformed message, so a hook changing the
+// attributes they cover makes them fail.
+type DecodeHook func(m *Message) error
+
+// hookChain is an immutable set of registered hooks.
+type hookChain struct {
+	encode []EncodeHook
+	decode []DecodeHook
+}
+
+var (
+	hooksMu sync.Mutex
+	hooks   atomic.Pointer[hookChain]
+)
+
+// AddEncodeHook registers a hook run by Encode before serialization, after
+// the hooks registered before it.
+//
+// Hooks let research or vendor deployments apply transformations agreed out
+// of band, such as eliding attributes both ends know about, without forking
+// the codec. By default no hook is registered and Encode is unaffected.
+//
+// Example:
+//
+//	// Never send SOFTWARE on this deployment
+//	stun.AddEncodeHook(func(m *stun.Message) {
+//		attrs := m.Attributes[:0]
+//		for _, attr := range m.Attributes {
+//			if attr.Type != stun.Software {
+//				attrs = append(attrs, attr)
+//			}
+//		}
+//		m.Attributes = attrs
+//	})
+func AddEncodeHook(h EncodeHook) {
+	hooksMu.Lock()
+	defer hooksMu.Unlock()
+
+	c := currentHooks()
+	hooks.Store(&hookChain{
+		encode: append(append([]EncodeHook(nil), c.encode...), h),
+		decode: c.decode,
+	})
+}
+
+// AddDecodeHook registers a hook run by NewMessage after parsing, after the
+// hooks registered before it. By default no hook is registered.
+func AddDecodeHook(h DecodeHook) {
+	hooksMu.Lock()
+	defer hooksMu.Unlock()
+
+	c := currentHooks()
+	hooks.Store(&hookChain{
+		encode: c.encode,
+		decode: append(append([]DecodeHook(nil), c.decode...), h),
+	})
+}
+
+// ResetHooks unregisters every encode and decode hook.
+func ResetHooks() {
+	hooksMu.Lock()
+	defer hooksMu.Unlock()
+
+	hooks.Store(&hookChain{})
+}
+
+// currentHooks returns the registered hooks.
+func currentHooks() *hookChain {
+	if c := hooks.Load(); c != nil {
+		return c
+	}
+	return &hookChain{}
+}
+
+// applyEncodeHooks returns m unchanged when no hook is registered, otherwise
+// a deep copy of m transformed by the hooks with a recomputed Header.Length.
+func applyEncodeHooks(m *Message) *Message {
+	c := hooks.Load()
+	if c == nil || len(c.encode) == 0 {
+		return m
+	}
+
+	out := m.Clone()
+	for _, h := range c.encode {
+		h(out)
+	}
+	out.Header.Length = out.attrsLength()
+	return out
+}
+
+// applyDecodeHooks runs the registered decode hooks on m.
+func applyDecodeHooks(m *Message) error {
+	c := hooks.Load()
+	if c == nil {
+		return nil
+	}
+	for _, h := range c.decode {
+		if err := h(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package stun
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestEncodeHookDoesNotModifyMessage(t *testing.T) {
+	t.Cleanup(ResetHooks)
+	AddEncodeHook(func(m *Message) {
+		for _, attr := range m.Attributes {
+			for i := range attr.Value {
+				attr.Value[i] = 'x'
+			}
+		}
+		m.Attributes = m.Attributes[:1]
+	})
+
+	m := NewBindingRequest()
+	for _, v := range []string{"abc", "def"} {
+		if err := m.Add(Software, []byte(v)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	before := m.Clone()
+
+	enc := m.Encode()
+	if want := []byte{0x80, 0x22, 0x00, 0x03, 'x', 'x', 'x', 0}; !bytes.Equal(enc[headrLength:], want) {
+		t.Fatalf("hook output encoded to %x, want %x", enc[headrLength:], want)
+	}
+	if !m.Equal(before) {
+		t.Fatalf("message changed by the encode hook: %+v, want %+v", m, before)
+	}
+}
+
+func TestDecodeHookTransformsMessage(t *testing.T) {
+	t.Cleanup(ResetHooks)
+	AddDecodeHook(func(m *Message) error {
+		m.Remove(Software)
+		return nil
+	})
+
+	m := NewBindingRequest()
+	if err := m.Add(Software, []byte("abc")); err != nil {
+		t.Fatal(err)
+	}
+	got, err := NewMessage(m.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := got.GetAttr(Software); ok {
+		t.Fatal("SOFTWARE kept by the decode hook removing it")
+	}
+}
+
+func TestDecodeHookErrorAbortsDecode(t *testing.T) {
+	t.Cleanup(ResetHooks)
+	errRejected := errors.New("rejected by hook")
+	AddDecodeHook(func(m *Message) error { return errRejected })
+
+	buf := NewBindingRequest().Encode()
+	if _, err := NewMessage(buf); err != errRejected {
+		t.Fatalf("NewMessage: got %v, want %v", err, errRejected)
+	}
+	var m Message
+	if err := m.Decode(buf); err != errRejected {
+		t.Fatalf("Decode: got %v, want %v", err, errRejected)
+	}
+	if m.Header != (Header{}) || len(m.Attributes) != 0 {
+		t.Fatalf("message not reset after a failed Decode: %+v", m)
+	}
+}
+
+func TestPacketWriteSendsBytesAsIs(t *testing.T) {
+	resp := NewBindingSuccess(NewTransactionID())
+	if err := resp.Add(Software, []byte("abc")); err != nil {
+		t.Fatal(err)
+	}
+	buf := resp.Encode()
+
+	// The response is already encoded: hooks must not run on it again
+	t.Cleanup(ResetHooks)
+	AddDecodeHook(func(m *Message) error { return errors.New("decode hook run on write") })
+	AddEncodeHook(func(m *Message) { m.Attributes = nil })
+
+	conn, peer := listenUDP(t), listenUDP(t)
+	p := &Packet{con: conn}
+	if _, err := p.Write(buf, peer.LocalAddr().(*net.UDPAddr)); err != nil {
+		t.Fatal(err)
+	}
+	got := make([]byte, 1500)
+	peer.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, err := peer.Read(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got[:n], buf) {
+		t.Fatalf("sent %x, want %x", got[:n], buf)
+	}
+}
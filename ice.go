@@ -0,0 +1,202 @@
+package stun
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+)
+
+// PriorityAttribute is the value of a PRIORITY attribute (RFC 8445 §7.1.1):
+// the priority a peer-reflexive candidate learned from the connectivity
+// check would get, carried in every ICE Binding request.
+type PriorityAttribute uint32
+
+// AddTo appends the priority to m as a PRIORITY attribute.
+//
+// Example:
+//
+//	msg := &stun.Message{Header: stun.Header{Type: stun.BindingRequest}}
+//	if err := stun.PriorityAttribute(1853824767).AddTo(msg); err != nil {
+//		log.Fatal(err)
+//	}
+func (p PriorityAttribute) AddTo(m *Message) error {
+	value := make([]byte, 4)
+	binary.BigEndian.PutUint32(value, uint32(p))
+	return m.Add(Priority, value)
+}
+
+// GetFrom decodes the PRIORITY attribute of m into p.
+//
+// Returns ErrAttrNotFound if m has no PRIORITY attribute.
+func (p *PriorityAttribute) GetFrom(m *Message) error {
+	attr, ok := m.GetAttr(Priority)
+	if !ok {
+		return ErrAttrNotFound
+	}
+	value := attr.rawValue()
+	if len(value) != 4 {
+		return ErrShortBuffer
+	}
+	*p = PriorityAttribute(binary.BigEndian.Uint32(value))
+	return nil
+}
+
+// UseCandidateAttribute represents the USE-CANDIDATE attribute (RFC 8445 §7.1.2),
+// a flag without value with which the controlling agent nominates a candidate pair.
+type UseCandidateAttribute struct{}
+
+// AddTo appends an empty USE-CANDIDATE attribute to m.
+func (UseCandidateAttribute) AddTo(m *Message) error {
+	return m.Add(UseCandidate, nil)
+}
+
+// IsSet reports whether m carries the USE-CANDIDATE attribute.
+//
+// Example:
+//
+//	if (stun.UseCandidateAttribute{}).IsSet(req) {
+//		// The controlling agent nominated this pair
+//	}
+func (UseCandidateAttribute) IsSet(m *Message) bool {
+	_, ok := m.GetAttr(UseCandidate)
+	return ok
+}
+
+// ICEControllingAttribute is the value of an ICE-CONTROLLING attribute
+// (RFC 8445 §7.1.3): the tie-breaker of an agent that believes it is in the
+// controlling role.
+type ICEControllingAttribute uint64
+
+// AddTo appends the tie-breaker to m as an ICE-CONTROLLING attribute.
+func (a ICEControllingAttribute) AddTo(m *Message) error {
+	return addTieBreaker(m, ICEControlling, uint64(a))
+}
+
+// GetFrom decodes the ICE-CONTROLLING attribute of m into a.
+//
+// Returns ErrAttrNotFound if m has no ICE-CONTROLLING attribute.
+func (a *ICEControllingAttribute) GetFrom(m *Message) error {
+	v, err := getTieBreaker(m, ICEControlling)
+	if err != nil {
+		return err
+	}
+	*a = ICEControllingAttribute(v)
+	return nil
+}
+
+// ICEControlledAttribute is the value of an ICE-CONTROLLED attribute
+// (RFC 8445 §7.1.3): the tie-breaker of an agent that believes it is in the
+// controlled role.
+type ICEControlledAttribute uint64
+
+// AddTo appends the tie-breaker to m as an ICE-CONTROLLED attribute.
+func (a ICEControlledAttribute) AddTo(m *Message) error {
+	return addTieBreaker(m, ICEControlled, uint64(a))
+}
+
+// GetFrom decodes the ICE-CONTROLLED attribute of m into a.
+//
+// Returns ErrAttrNotFound if m has no ICE-CONTROLLED attribute.
+func (a *ICEControlledAttribute) GetFrom(m *Message) error {
+	v, err := getTieBreaker(m, ICEControlled)
+	if err != nil {
+		return err
+	}
+	*a = ICEControlledAttribute(v)
+	return nil
+}
+
+// NewTieBreaker returns a random 64-bit tie-breaker, generated once per ICE session.
+func NewTieBreaker() uint64 {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return binary.BigEndian.Uint64(b[:])
+}
+
+// ICERole is the role of an ICE agent.
+type ICERole int
+
+const (
+	RoleControlling ICERole = iota
+	RoleControlled
+)
+
+// String returns the string representation of the ICERole
+func (r ICERole) String() string {
+	switch r {
+	case RoleControlling:
+		return "controlling"
+	case RoleControlled:
+		return "controlled"
+	default:
+		return "unknown"
+	}
+}
+
+// RoleConflictOutcome is the action an agent takes when it receives a
+// Binding request, as decided by ResolveRoleConflict.
+type RoleConflictOutcome int
+
+const (
+	// NoRoleConflict means the roles are consistent and the request is processed normally.
+	NoRoleConflict RoleConflictOutcome = iota
+	// SwitchRole means the local agent must switch to the other role and then
+	// process the request.
+	SwitchRole
+	// RespondRoleConflict means the local agent keeps its role and rejects the
+	// request with a 487 (Role Conflict) error response.
+	RespondRoleConflict
+)
+
+// ResolveRoleConflict applies the role conflict rules of RFC 8445 §7.3.1.1
+// to a Binding request received by an agent in the given role with the given
+// tie-breaker. The agent with the larger tie-breaker wins the controlling role.
+//
+// Example:
+//
+//	switch stun.ResolveRoleConflict(role, tieBreaker, req) {
+//	case stun.SwitchRole:
+//		role = 1 - role
+//	case stun.RespondRoleConflict:
+//		// Reply with a 487 Role Conflict error response
+//	}
+func ResolveRoleConflict(role ICERole, tieBreaker uint64, req *Message) RoleConflictOutcome {
+	switch role {
+	case RoleControlling:
+		var remote ICEControllingAttribute
+		if remote.GetFrom(req) != nil {
+			return NoRoleConflict
+		}
+		if tieBreaker >= uint64(remote) {
+			return RespondRoleConflict
+		}
+		return SwitchRole
+	case RoleControlled:
+		var remote ICEControlledAttribute
+		if remote.GetFrom(req) != nil {
+			return NoRoleConflict
+		}
+		if tieBreaker >= uint64(remote) {
+			return SwitchRole
+		}
+		return RespondRoleConflict
+	}
+	return NoRoleConflict
+}
+
+func addTieBreaker(m *Message, t StunAttribute, v uint64) error {
+	value := make([]byte, 8)
+	binary.BigEndian.PutUint64(value, v)
+	return m.Add(t, value)
+}
+
+func getTieBreaker(m *Message, t StunAttribute) (uint64, error) {
+	attr, ok := m.GetAttr(t)
+	if !ok {
+		return 0, ErrAttrNotFound
+	}
+	value := attr.rawValue()
+	if len(value) != 8 {
+		return 0, ErrShortBuffer
+	}
+	return binary.BigEndian.Uint64(value), nil
+}
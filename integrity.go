@@ -0,0 +1,225 @@
+package stun
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+)
+
+// Integrity is the key used to compute and verify the MESSAGE-INTEGRITY
+// attribute (RFC 5389 §15.4), an HMAC-SHA1 over the message up to the
+// attribute itself.
+type Integrity []byte
+
+// NewShortTermIntegrity returns the integrity key for the short-term credential
+// mechanism, which is the password itself, used as is. RFC 5389 §15.4
+// prepares the password with SASLprep first, which only matters for
+// non-ASCII passwords: use NewShortTermIntegrityPrepared for those.
+func NewShortTermIntegrity(password string) Integrity {
+	return Integrity(password)
+}
+
+// NewShortTermIntegrityPrepared returns the integrity key for the short-term
+// credential mechanism, SASLprep(password), as RFC 5389 §15.4 derives it.
+//
+// Returns an error wrapping ErrStringPrep if the password cannot be
+// prepared (see SASLprep).
+//
+// Example:
+//
+//	key, err := stun.NewShortTermIntegrityPrepared(password)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+func NewShortTermIntegrityPrepared(password string) (Integrity, error) {
+	password, err := SASLprep(password)
+	if err != nil {
+		return nil, err
+	}
+	return NewShortTermIntegrity(password), nil
+}
+
+// NewLongTermIntegrity returns the integrity key for the long-term credential
+// mechanism: MD5(username ":" realm ":" password). The values are hashed as
+// is; RFC 5389 peers expect NewLongTermIntegrityPrepared, and RFC 8489 peers
+// NewLongTermIntegrityWithAlgorithm, which prepare them.
+func NewLongTermIntegrity(username, realm, password string) Integrity {
+	k := md5.Sum([]byte(username + ":" + realm + ":" + password))
+	return Integrity(k[:])
+}
+
+// NewLongTermIntegrityPrepared returns the integrity key for the long-term
+// credential mechanism as RFC 5389 §15.4 derives it: MD5(username ":"
+// SASLprep(realm) ":" SASLprep(password)). The username is hashed as is,
+// being already prepared as sent in USERNAME.
+//
+// Returns an error wrapping ErrStringPrep if realm or password cannot be
+// prepared (see SASLprep).
+func NewLongTermIntegrityPrepared(username, realm, password string) (Integrity, error) {
+	realm, err := SASLprep(realm)
+	if err != nil {
+		return nil, err
+	}
+	password, err = SASLprep(password)
+	if err != nil {
+		return nil, err
+	}
+	return NewLongTermIntegrity(username, realm, password), nil
+}
+
+// AddTo computes the MESSAGE-INTEGRITY of m with the key and appends it as
+// the attribute. Any attribute added afterwards, other than FINGERPRINT,
+// invalidates the integrity check at the receiver.
+//
+// Example:
+//
+//	key := stun.NewShortTermIntegrity("secret")
+//	if err := key.AddTo(msg); err != nil {
+//		log.Fatal(err)
+//	}
+func (i Integrity) AddTo(m *Message) error {
+	length := m.attrsLength() + 4 + MessageIntegrityLength
+	mac := i.compute(hmacSHA1, m, len(m.Attributes), length, false, nil)
+	return m.Add(MessageIntegrity, mac)
+}
+
+// Check verifies the MESSAGE-INTEGRITY attribute of m against the key.
+//
+// The HMACs are compared in constant time.
+//
+// Returns ErrAttrNotFound if m has no MESSAGE-INTEGRITY attribute and
+// ErrIntegrityMismatch if the HMAC does not match.
+func (i Integrity) Check(m *Message) error {
+	idx, length := -1, uint16(0)
+	for n, attr := range m.Attributes {
+		length += uint16(4 + attr.PaddedLength)
+		if attr.Type == MessageIntegrity {
+			idx = n
+			break
+		}
+	}
+	if idx < 0 {
+		return ErrAttrNotFound
+	}
+	var sum [MessageIntegrityLength]byte
+	want := i.compute(hmacSHA1, m, idx, length, true, sum[:0])
+	if !hmac.Equal(m.Attributes[idx].rawValue(), want) {
+		return ErrIntegrityMismatch
+	}
+	return nil
+}
+
+// Truncation bounds of MESSAGE-INTEGRITY-SHA256 (RFC 8489 §14.6).
+const minIntegritySHA256Length = 16
+
+// IntegritySHA256 computes and verifies the MESSAGE-INTEGRITY-SHA256
+// attribute (RFC 8489 §14.6), an HMAC-SHA256 over the message up to the
+// attribute itself. Key is the same credential as for MESSAGE-INTEGRITY.
+//
+// Length is the number of leading bytes of the HMAC that AddTo sends: a
+// multiple of 4 between 16 and 32, zero selecting the full 32 bytes. Check
+// verifies whatever truncation the sender chose.
+type IntegritySHA256 struct {
+	Key    Integrity
+	Length int
+}
+
+// NewIntegritySHA256 returns an untruncated MESSAGE-INTEGRITY-SHA256 setter
+// and checker for key.
+func NewIntegritySHA256(key Integrity) IntegritySHA256 {
+	return IntegritySHA256{Key: key}
+}
+
+// AddTo computes the MESSAGE-INTEGRITY-SHA256 of m and appends it as the
+// attribute, truncated to i.Length bytes. It may follow a MESSAGE-INTEGRITY
+// attribute, in which case that attribute is covered by the HMAC.
+//
+// Returns ErrIntegrityLength if i.Length is not a valid truncation.
+//
+// Example:
+//
+//	mi := stun.NewIntegritySHA256(stun.NewShortTermIntegrity("secret"))
+//	if err := mi.AddTo(msg); err != nil {
+//		log.Fatal(err)
+//	}
+func (i IntegritySHA256) AddTo(m *Message) error {
+	n := i.Length
+	if n == 0 {
+		n = MessageIntegritySHA256Length
+	}
+	if !validIntegritySHA256Length(n) {
+		return ErrIntegrityLength
+	}
+	length := m.attrsLength() + 4 + uint16(n)
+	mac := i.Key.compute(hmacSHA256, m, len(m.Attributes), length, false, nil)
+	return m.Add(MessageIntegritySHA256, mac[:n])
+}
+
+// Check verifies the MESSAGE-INTEGRITY-SHA256 attribute of m, comparing as
+// many leading bytes of the HMAC as the attribute carries, in constant time.
+//
+// Returns ErrAttrNotFound if m has no MESSAGE-INTEGRITY-SHA256 attribute,
+// ErrIntegrityLength if its length is not a valid truncation and
+// ErrIntegrityMismatch if the HMAC does not match.
+func (i IntegritySHA256) Check(m *Message) error {
+	idx, length := -1, uint16(0)
+	for n, attr := range m.Attributes {
+		length += uint16(4 + attr.PaddedLength)
+		if attr.Type == MessageIntegritySHA256 {
+			idx = n
+			break
+		}
+	}
+	if idx < 0 {
+		return ErrAttrNotFound
+	}
+	got := m.Attributes[idx].rawValue()
+	if !validIntegritySHA256Length(len(got)) {
+		return ErrIntegrityLength
+	}
+	var sum [MessageIntegritySHA256Length]byte
+	want := i.Key.compute(hmacSHA256, m, idx, length, true, sum[:0])
+	if !hmac.Equal(got, want[:len(got)]) {
+		return ErrIntegrityMismatch
+	}
+	return nil
+}
+
+// validIntegritySHA256Length reports whether n bytes is an allowed
+// MESSAGE-INTEGRITY-SHA256 truncation.
+func validIntegritySHA256Length(n int) bool {
+	return n >= minIntegritySHA256Length && n <= MessageIntegritySHA256Length && n%4 == 0
+}
+
+// compute appends to dst the HMAC of the first n attributes of m, with the
+// header length set to length, over the padding received if raw is set (see
+// appendIntegrityInput). Both the HMAC state and the serialization buffer
+// are pooled.
+func (i Integrity) compute(alg hmacAlgorithm, m *Message, n int, length uint16, raw bool, dst []byte) []byte {
+	buf := integrityBufPool.Get().(*[]byte)
+	input := m.appendIntegrityInput((*buf)[:0], n, length, raw)
+
+	h, pool := acquireHMAC(alg, i)
+	h.Write(input)
+	dst = h.Sum(dst)
+	releaseHMAC(pool, h)
+
+	*buf = input
+	integrityBufPool.Put(buf)
+	return dst
+}
+
+// appendIntegrityInput appends to buff the bytes covered by a
+// MESSAGE-INTEGRITY placed after the first n attributes: the header, with its
+// length set to length so that it accounts for the integrity attribute itself,
+// followed by those attributes. Checks set raw, the sender having covered the
+// padding bytes it sent, while the values added by this package cover the
+// zero padding Encode emits.
+func (m *Message) appendIntegrityInput(buff []byte, n int, length uint16, raw bool) []byte {
+	header := m.Header
+	header.Length = length
+	buff = header.appendTo(buff)
+	for i := range m.Attributes[:n] {
+		buff = m.Attributes[i].appendTo(buff, raw)
+	}
+	return buff
+}
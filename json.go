@@ -0,0 +1,189 @@
+package stun
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+)
+
+// addrCodecs are the codecs of the address attributes of the package, used
+// to print their value as a transport address.
+var addrCodecs = map[StunAttribute]AddressAttribute{
+	MappedAddress:     {Type: MappedAddress},
+	SourceAddress:     {Type: SourceAddress},
+	ChangedAddress:    {Type: ChangedAddress},
+	AlternateServer:   {Type: AlternateServer},
+	ResponseOrigin:    {Type: ResponseOrigin},
+	OtherAddress:      {Type: OtherAddress},
+	XORMappedAddress:  {Type: XORMappedAddress, XOR: true},
+	XORPeerAddress:    {Type: XORPeerAddress, XOR: true},
+	XORRelayedAddress: {Type: XORRelayedAddress, XOR: true},
+}
+
+// headerJSON is the JSON form of a Header.
+type headerJSON struct {
+	Type          string `json:"type"`
+	Method        string `json:"method,omitempty"`
+	Class         string `json:"class,omitempty"`
+	Length        uint16 `json:"length"`
+	MagicCookie   string `json:"magic_cookie"`
+	TransactionID string `json:"transaction_id"`
+}
+
+// attrJSON is the JSON form of an Attribute.
+type attrJSON struct {
+	Type    string `json:"type"`
+	Name    string `json:"name,omitempty"`
+	Length  int    `json:"length"`
+	Value   string `json:"value"`
+	Padding string `json:"padding,omitempty"`
+	Decoded string `json:"decoded,omitempty"`
+}
+
+// messageJSON is the JSON form of a Message.
+type messageJSON struct {
+	Header     Header     `json:"header"`
+	Attributes []attrJSON `json:"attributes"`
+}
+
+// MarshalJSON encodes the header as a JSON object with the message type,
+// magic cookie and transaction ID in hexadecimal, and the method and class of
+// the type spelled out for readers:
+//
+//	{"type":"0x0001","method":"Binding","class":"Request","length":0,
+//	 "magic_cookie":"0x2112a442","transaction_id":"b7e7a701bc34d686fa87dfae"}
+func (h Header) MarshalJSON() ([]byte, error) {
+	return json.Marshal(headerJSON{
+		Type:          fmt.Sprintf("0x%04x", uint16(h.Type)),
+		Method:        h.Type.Method().String(),
+		Class:         h.Type.Class().String(),
+		Length:        h.Length,
+		MagicCookie:   fmt.Sprintf("0x%08x", h.MagicCookie),
+		TransactionID: h.TransactionID.String(),
+	})
+}
+
+// UnmarshalJSON decodes a header encoded by MarshalJSON. The method and
+// class are ignored, the type being authoritative.
+func (h *Header) UnmarshalJSON(data []byte) error {
+	var j headerJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	t, err := strconv.ParseUint(j.Type, 0, 16)
+	if err != nil {
+		return fmt.Errorf("invalid message type %q", j.Type)
+	}
+	cookie, err := strconv.ParseUint(j.MagicCookie, 0, 32)
+	if err != nil {
+		return fmt.Errorf("invalid magic cookie %q", j.MagicCookie)
+	}
+	id, err := hex.DecodeString(j.TransactionID)
+	if err != nil || len(id) != len(h.TransactionID) {
+		return fmt.Errorf("invalid transaction ID %q", j.TransactionID)
+	}
+	h.Type = MessageType(t)
+	h.Length = j.Length
+	h.MagicCookie = uint32(cookie)
+	copy(h.TransactionID[:], id)
+	return nil
+}
+
+// MarshalJSON encodes the attribute as a JSON object with its type and
+// value in hexadecimal, its name if known (see StunAttribute.Name), its
+// length and, for a decoded attribute padded with non-zero bytes, its
+// padding:
+//
+//	{"type":"0x8022","name":"SOFTWARE","length":3,"value":"616263"}
+func (a Attribute) MarshalJSON() ([]byte, error) {
+	return json.Marshal(a.toJSON())
+}
+
+func (a Attribute) toJSON() attrJSON {
+	return attrJSON{
+		Type:    fmt.Sprintf("0x%04x", uint16(a.Type)),
+		Name:    a.Type.Name(),
+		Length:  int(a.Length),
+		Value:   hex.EncodeToString(a.rawValue()),
+		Padding: hex.EncodeToString(a.padding),
+	}
+}
+
+// UnmarshalJSON decodes an attribute encoded by MarshalJSON. The length is
+// that of the value; the name and length given are ignored.
+func (a *Attribute) UnmarshalJSON(data []byte) error {
+	var j attrJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	return a.fromJSON(j)
+}
+
+func (a *Attribute) fromJSON(j attrJSON) error {
+	t, err := strconv.ParseUint(j.Type, 0, 16)
+	if err != nil {
+		return fmt.Errorf("invalid attribute type %q", j.Type)
+	}
+	value, err := hex.DecodeString(j.Value)
+	if err != nil || len(value) > 0xFFFF {
+		return fmt.Errorf("invalid value of attribute %s", j.Type)
+	}
+	padding, err := hex.DecodeString(j.Padding)
+	if err != nil || len(padding) != 0 && len(padding) != paddedLength(len(value))-len(value) {
+		return fmt.Errorf("invalid padding of attribute %s", j.Type)
+	}
+	*a = newAttr(StunAttribute(t), value)
+	a.padding = padding
+	if len(padding) == 0 {
+		a.padding = nil
+	}
+	return nil
+}
+
+// MarshalJSON encodes the message as a JSON object with its header and
+// attributes (see Header.MarshalJSON and Attribute.MarshalJSON). The
+// attributes carrying a transport address or text also have a "decoded"
+// member, e.g. "192.0.2.1:32853" or "[2001:db8::1]:32853" for an
+// XOR-MAPPED-ADDRESS, for logs and tooling to read without a STUN decoder:
+//
+//	data, err := json.Marshal(msg)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	fmt.Println(string(data))
+func (m Message) MarshalJSON() ([]byte, error) {
+	j := messageJSON{Header: m.Header, Attributes: make([]attrJSON, 0, len(m.Attributes))}
+	for _, attr := range m.Attributes {
+		a := attr.toJSON()
+		if codec, ok := addrCodecs[attr.Type]; ok {
+			if addr, err := codec.Decode(attr.rawValue(), m.Header.TransactionID); err == nil {
+				a.Decoded = net.JoinHostPort(addr.IP.String(), strconv.Itoa(int(addr.Port)))
+			}
+		} else if _, ok := attrMaxLengths[attr.Type]; ok {
+			a.Decoded = string(attr.rawValue())
+		}
+		j.Attributes = append(j.Attributes, a)
+	}
+	return json.Marshal(j)
+}
+
+// UnmarshalJSON decodes a message encoded by MarshalJSON, ignoring the
+// decoded values. Header.Length is kept as given, so that fixtures of
+// inconsistent messages survive the round trip; Encode recomputes it.
+func (m *Message) UnmarshalJSON(data []byte) error {
+	var j messageJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	attrs := make(Attributes, len(j.Attributes))
+	for i, a := range j.Attributes {
+		if err := attrs[i].fromJSON(a); err != nil {
+			return err
+		}
+	}
+	m.Header = j.Header
+	m.Attributes = attrs
+	return nil
+}
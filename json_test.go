@@ -0,0 +1,51 @@
+package stun
+
+import (
+	"encoding/json"
+	"net"
+	"testing"
+)
+
+func TestMarshalJSONDecodedAddr(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		addr *net.UDPAddr
+		want string
+	}{
+		{"IPv4", &net.UDPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 32853}, "192.0.2.1:32853"},
+		{"IPv6", &net.UDPAddr{IP: net.ParseIP("2001:db8::1"), Port: 1234}, "[2001:db8::1]:1234"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewBindingSuccess(NewTransactionID())
+			if err := (XorMappedAddr{IP: tt.addr.IP, Port: uint16(tt.addr.Port)}).AddTo(m); err != nil {
+				t.Fatal(err)
+			}
+			data, err := json.Marshal(m)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var j struct {
+				Attributes []struct {
+					Decoded string `json:"decoded"`
+				} `json:"attributes"`
+			}
+			if err := json.Unmarshal(data, &j); err != nil {
+				t.Fatal(err)
+			}
+			if got := j.Attributes[0].Decoded; got != tt.want {
+				t.Fatalf("decoded %q, want %q", got, tt.want)
+			}
+			if _, err := net.ResolveUDPAddr("udp", j.Attributes[0].Decoded); err != nil {
+				t.Fatalf("decoded address does not parse back: %v", err)
+			}
+
+			var back Message
+			if err := json.Unmarshal(data, &back); err != nil {
+				t.Fatal(err)
+			}
+			if !back.Equal(m) {
+				t.Fatalf("round trip gave %+v, want %+v", back, m)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package stun
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync/atomic"
+)
+
+// DecodeLimits bound what NewMessage accepts from the network, so that
+// adversarial packets cannot make a server allocate or loop without bound. A
+// zero field leaves the corresponding quantity bounded by the wire format
+// only.
+type DecodeLimits struct {
+	// MaxAttributes is the maximum number of attributes of a message.
+	MaxAttributes int
+	// MaxAttrLength is the maximum length of an attribute value, in bytes.
+	MaxAttrLength int
+	// MaxMessageSize is the maximum size of a message, header included, in
+	// bytes.
+	MaxMessageSize int
+}
+
+// DefaultDecodeLimits are the limits in effect until SetDecodeLimits is
+// called. They are generous for STUN and TURN over UDP, whose messages fit in
+// a datagram of the path MTU.
+var DefaultDecodeLimits = DecodeLimits{
+	MaxAttributes:  128,
+	MaxAttrLength:  8192,
+	MaxMessageSize: 16384,
+}
+
+var decodeLimits atomic.Pointer[DecodeLimits]
+
+// SetDecodeLimits replaces the limits enforced by NewMessage and the
+// decoders built on it, for every message decoded afterwards.
+//
+// Example:
+//
+//	// Relay large TURN Data attributes over TCP
+//	limits := stun.DefaultDecodeLimits
+//	limits.MaxAttrLength = 65535
+//	limits.MaxMessageSize = 0
+//	stun.SetDecodeLimits(limits)
+func SetDecodeLimits(l DecodeLimits) {
+	decodeLimits.Store(&l)
+}
+
+// CurrentDecodeLimits returns the limits enforced by NewMessage.
+func CurrentDecodeLimits() DecodeLimits {
+	if l := decodeLimits.Load(); l != nil {
+		return *l
+	}
+	return DefaultDecodeLimits
+}
+
+// checkSize returns an error wrapping ErrMessageTooLarge if a message of size
+// bytes exceeds the limits.
+func (l DecodeLimits) checkSize(size int) error {
+	if l.MaxMessageSize > 0 && size > l.MaxMessageSize {
+		return fmt.Errorf("%w: %d bytes, max %d", ErrMessageTooLarge, size, l.MaxMessageSize)
+	}
+	return nil
+}
+
+// maxMessageSize is the size of the largest message the wire format can
+// describe: the header and 65535 bytes of attributes.
+const maxMessageSize = headrLength + 0xFFFF
+
+// readSize returns the size of the messages a reader configured with max
+// accepts: max if set, else the MaxMessageSize of the decode limits, else the
+// largest message.
+func readSize(max int) int {
+	if max > 0 {
+		return max
+	}
+	if l := CurrentDecodeLimits().MaxMessageSize; l > 0 {
+		return l
+	}
+	return maxMessageSize
+}
+
+// checkRead returns an error wrapping ErrMessageTruncated if a datagram read
+// into a buffer of size+1 bytes filled it, so that it did not fit in size
+// bytes and was truncated by the read.
+func checkRead(n, size int) error {
+	if n > size {
+		return fmt.Errorf("%w: datagram larger than %d bytes", ErrMessageTruncated, size)
+	}
+	return nil
+}
+
+// checkAttr returns an error if the attribute encoded at the start of buff
+// would be the n-th of its message, n counting from 1, or its length exceeds
+// the limits. buff must hold at least the 4-byte attribute header.
+func (l DecodeLimits) checkAttr(buff []byte, n int) error {
+	if l.MaxAttributes > 0 && n > l.MaxAttributes {
+		return fmt.Errorf("%w: max %d", ErrTooManyAttributes, l.MaxAttributes)
+	}
+	length := int(binary.BigEndian.Uint16(buff[2:4]))
+	if l.MaxAttrLength > 0 && length > l.MaxAttrLength {
+		return fmt.Errorf("%w: attribute 0x%04x of %d bytes, max %d",
+			ErrAttrTooLarge, binary.BigEndian.Uint16(buff[0:2]), length, l.MaxAttrLength)
+	}
+	return nil
+}
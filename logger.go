@@ -139,21 +139,21 @@ func (l *Logger) Fatal(msg string, fields ...map[string]interface{}) {
 }
 
 // LogRequest logs STUN request details
-func (l *Logger) LogRequest(remoteAddr string, msgType MessageType, transactionID [12]byte) {
+func (l *Logger) LogRequest(remoteAddr string, msgType MessageType, transactionID TransactionID) {
 	l.Info("STUN request received", map[string]interface{}{
 		"remote_addr":    remoteAddr,
 		"message_type":   msgType.String(),
-		"transaction_id": transactionID,
+		"transaction_id": transactionID.String(),
 		"component":      "stun_server",
 	})
 }
 
 // LogResponse logs STUN response details
-func (l *Logger) LogResponse(remoteAddr string, msgType MessageType, transactionID [12]byte, xorAddr *XorMappedAddr) {
+func (l *Logger) LogResponse(remoteAddr string, msgType MessageType, transactionID TransactionID, xorAddr *XorMappedAddr) {
 	fields := map[string]interface{}{
 		"remote_addr":    remoteAddr,
 		"message_type":   msgType.String(),
-		"transaction_id": transactionID,
+		"transaction_id": transactionID.String(),
 		"component":      "stun_server",
 	}
 
@@ -175,12 +175,55 @@ func (l *Logger) LogError(msg string, err error, fields map[string]interface{})
 	l.Error(msg, fields)
 }
 
+// txLogger decorates a Logger with the fields identifying a transaction, so
+// that every line logged while handling it carries the same keys and can be
+// queried by transaction or peer.
+type txLogger struct {
+	l      *Logger
+	fields map[string]interface{}
+}
+
+// loggerWithTransaction returns a logger adding the transaction ID id and
+// the address of the peer, server or client, to every line as
+// "transaction_id" and "remote_addr".
+func loggerWithTransaction(l *Logger, id TransactionID, remote string) *txLogger {
+	return &txLogger{l: l, fields: map[string]interface{}{
+		"remote_addr":    remote,
+		"transaction_id": id.String(),
+	}}
+}
+
+// with returns the transaction fields merged with fields, which take
+// precedence.
+func (t *txLogger) with(fields []map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(t.fields)+4)
+	for k, v := range t.fields {
+		merged[k] = v
+	}
+	for _, f := range fields {
+		for k, v := range f {
+			merged[k] = v
+		}
+	}
+	return merged
+}
+
+// Debug logs a message of the transaction at debug level.
+func (t *txLogger) Debug(msg string, fields ...map[string]interface{}) {
+	t.l.Debug(msg, t.with(fields))
+}
+
+// LogError logs an error of the transaction with context.
+func (t *txLogger) LogError(msg string, err error, fields ...map[string]interface{}) {
+	t.l.LogError(msg, err, t.with(fields))
+}
+
 // LogClientRequest logs client request details
-func (l *Logger) LogClientRequest(serverAddr string, msgType MessageType, transactionID [12]byte) {
+func (l *Logger) LogClientRequest(serverAddr string, msgType MessageType, transactionID TransactionID) {
 	l.Debug("STUN client request", map[string]interface{}{
-		"server_addr":    serverAddr,
+		"remote_addr":    serverAddr,
 		"message_type":   msgType.String(),
-		"transaction_id": transactionID,
+		"transaction_id": transactionID.String(),
 		"component":      "stun_client",
 	})
 }
@@ -188,7 +231,7 @@ func (l *Logger) LogClientRequest(serverAddr string, msgType MessageType, transa
 // LogClientResponse logs client response details
 func (l *Logger) LogClientResponse(serverAddr string, msgType MessageType, xorAddr *XorMappedAddr) {
 	fields := map[string]interface{}{
-		"server_addr":  serverAddr,
+		"remote_addr":  serverAddr,
 		"message_type": msgType.String(),
 		"component":    "stun_client",
 	}
@@ -0,0 +1,43 @@
+package stun
+
+import "net"
+
+// MappedAddr is the value of a MAPPED-ADDRESS attribute (RFC 5389 §15.1).
+// It carries the same information as XorMappedAddr but the address and port
+// are sent in the clear, as legacy servers and RFC 3489 clients expect.
+type MappedAddr struct {
+	Family IPFamily
+	IP     net.IP
+	Port   uint16
+}
+
+// AddTo appends the address to m as a MAPPED-ADDRESS attribute.
+// The family is derived from IP, so Family may be left unset.
+//
+// Example:
+//
+//	addr := stun.MappedAddr{IP: net.ParseIP("203.0.113.7"), Port: 40000}
+//	if err := addr.AddTo(msg); err != nil {
+//		log.Fatal(err)
+//	}
+func (a MappedAddr) AddTo(m *Message) error {
+	return AddressAttribute{Type: MappedAddress}.Add(m, a)
+}
+
+// GetFrom decodes the first MAPPED-ADDRESS attribute of m into a.
+//
+// Returns ErrAttrNotFound if m has no MAPPED-ADDRESS attribute.
+func (a *MappedAddr) GetFrom(m *Message) error {
+	return getAddr(m, AddressAttribute{Type: MappedAddress}, a)
+}
+
+// getAddr decodes the attribute of the codec c in m into addr, leaving addr
+// untouched on error.
+func getAddr(m *Message, c AddressAttribute, addr *MappedAddr) error {
+	decoded, err := c.Get(m)
+	if err != nil {
+		return err
+	}
+	*addr = decoded
+	return nil
+}
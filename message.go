@@ -1,5 +1,13 @@
 package stun
 
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"iter"
+	"slices"
+)
+
 // Message represents a complete STUN message, including its header and attributes.
 // A STUN message consists of a 20-byte header followed by zero or more attributes.
 //
@@ -7,6 +15,13 @@ package stun
 //   - Header: Contains message type, length, magic cookie, and transaction ID
 //   - Attributes: Variable-length list of STUN attributes
 //
+// The Value of an attribute is not owned by the message. A decoded message
+// (NewMessage, Decode, ReadMessage) shares the memory of the buffer it was
+// decoded from, and Add keeps the slice it is given: the bytes must not be
+// modified, nor the buffer reused, while the message is in use. Clone copies
+// the values into memory the clone owns. Messages can be pooled with
+// sync.Pool and recycled with Reset once no attribute value is referenced.
+//
 // Example:
 //
 //	msg := &stun.Message{
@@ -25,13 +40,15 @@ type Message struct {
 //
 // The function performs the following operations:
 //   - Decodes the 20-byte header
+//   - Checks that the two most significant bits are zero
 //   - Validates the magic cookie
 //   - Parses all attributes based on the message length
 //   - Returns a fully populated Message structure
 //
 // Returns:
 //   - *Message: The parsed STUN message
-//   - error: Any error that occurred during parsing
+//   - error: Any error that occurred during parsing, a *CookieError wrapping
+//     ErrInvalidCookie if the magic cookie is missing
 //
 // Example:
 //
@@ -41,15 +58,122 @@ type Message struct {
 //		log.Fatal(err)
 //	}
 func NewMessage(buff []byte) (*Message, error) {
-	header, err := decodeHeader(buff)
-	if err != nil {
+	return decodeMessage(buff, false)
+}
+
+// IsSTUN reports whether the datagram buf looks like a STUN message, from its
+// header only: the two most significant bits are zero, the magic cookie is
+// present, and the length is a multiple of 4 matching the size of buf. It
+// does not allocate, so that demultiplexers sharing a socket between STUN,
+// DTLS and RTP (RFC 7983) can classify every packet before parsing it with
+// NewMessage. Use CheckFingerprint to also check the FINGERPRINT.
+//
+// Example:
+//
+//	if stun.IsSTUN(buf[:n]) {
+//		msg, err := stun.NewMessage(buf[:n])
+//		// ...
+//	}
+func IsSTUN(buf []byte) bool {
+	if len(buf) < headrLength || buf[0]&0xC0 != 0 {
+		return false
+	}
+	if binary.BigEndian.Uint32(buf[4:8]) != magicCookie {
+		return false
+	}
+	length := int(binary.BigEndian.Uint16(buf[2:4]))
+	return length%4 == 0 && len(buf) == headrLength+length
+}
+
+// decodeMessage parses buff, rejecting messages without the magic cookie
+// unless classic is set.
+func decodeMessage(buff []byte, classic bool) (*Message, error) {
+	msg := new(Message)
+	if err := msg.decode(buff, classic); err != nil {
 		return nil, err
 	}
-	attributes := decodeAttrs(buff[20:], int(header.Length))
-	return &Message{
-		Header:     *header,
-		Attributes: attributes,
-	}, nil
+	return msg, nil
+}
+
+// Decode parses buff into m like NewMessage, reusing the attribute slice of
+// m instead of allocating a new message, so that servers and agents reading
+// at a high rate can decode every packet into the same Message without
+// garbage. As with NewMessage, the attribute values share the memory of
+// buff: use Clone to keep the message once buff is reused. On error, m is
+// reset to an empty message.
+//
+// Example:
+//
+//	var msg stun.Message
+//	buf := make([]byte, 1500)
+//	for {
+//		n, addr, err := conn.ReadFrom(buf)
+//		if err != nil {
+//			return err
+//		}
+//		if err := msg.Decode(buf[:n]); err != nil {
+//			continue
+//		}
+//		handle(&msg, addr)
+//	}
+func (m *Message) Decode(buff []byte) error {
+	return m.decode(buff, false)
+}
+
+// decode is Decode, accepting messages without the magic cookie if classic
+// is set.
+func (m *Message) decode(buff []byte, classic bool) error {
+	err := m.decodeParts(buff, classic)
+	if err != nil {
+		m.Reset()
+	}
+	return err
+}
+
+// Reset empties m for reuse: the header is zeroed and the attributes are
+// truncated in place, keeping the capacity of the slice for the next Decode
+// or Add. The attributes dropped are cleared, so that a pooled message does
+// not keep the buffers their values pointed to alive; values obtained from m
+// before the reset remain valid as long as their own buffer is.
+//
+// Example:
+//
+//	var pool = sync.Pool{New: func() any { return new(stun.Message) }}
+//
+//	msg := pool.Get().(*stun.Message)
+//	if err := msg.Decode(buf[:n]); err == nil {
+//		handle(msg)
+//	}
+//	msg.Reset()
+//	pool.Put(msg)
+func (m *Message) Reset() {
+	m.Header = Header{}
+	clear(m.Attributes)
+	m.Attributes = m.Attributes[:0]
+}
+
+// decodeParts decodes the header and attributes of buff into m.
+func (m *Message) decodeParts(buff []byte, classic bool) error {
+	if err := m.Header.decode(buff); err != nil {
+		return err
+	}
+	if !classic && m.Header.IsClassic() {
+		return &CookieError{Cookie: m.Header.MagicCookie}
+	}
+	limits := CurrentDecodeLimits()
+	size := headrLength + int(m.Header.Length)
+	if err := limits.checkSize(size); err != nil {
+		return err
+	}
+	if len(buff) < size {
+		return ErrShortBuffer
+	}
+	attributes, err := decodeAttrs(m.Attributes[:0], buff[20:size], int(m.Header.Length), limits)
+	if err != nil {
+		return err
+	}
+	m.Attributes = attributes
+	return applyDecodeHooks(m)
 }
 
 // GetAttr searches for a specific attribute type in the message and returns it if found.
@@ -59,35 +183,82 @@ func NewMessage(buff []byte) (*Message, error) {
 //   - t: The StunAttribute type to search for
 //
 // Returns:
-//   - *Attribute: The found attribute, or nil if not found
+//   - *Attribute: The found attribute, or nil if not found. It points into
+//     the attributes of the message rather than to a copy, so that the
+//     lookup does not allocate.
 //   - bool: True if the attribute was found, false otherwise
 //
 // Example:
 //
 //	if attr, found := msg.GetAttr(stun.XORMappedAddress); found {
 //		// Process the XOR-MAPPED-ADDRESS attribute
-//		xorAddr := decodeAddr(attr.Value)
-//		fmt.Printf("XOR Address: %s:%d\n", xorAddr.IP, xorAddr.Port)
+//		fmt.Printf("XOR-MAPPED-ADDRESS value: %x\n", attr.Value)
 //	}
 func (m Message) GetAttr(t StunAttribute) (*Attribute, bool) {
-	for _, attr := range m.Attributes {
-		if attr.Type == t {
-			return &attr, true
+	for i := range m.Attributes {
+		if m.Attributes[i].Type == t {
+			return &m.Attributes[i], true
 		}
 	}
 	return nil, false
 }
 
+// Range returns an iterator over the attributes of m in message order. It
+// yields pointers into Attributes instead of copies and does not allocate,
+// for the hot paths of servers and agents. Attributes must not be added or
+// removed during the iteration.
+//
+// Example:
+//
+//	for attr := range msg.Range() {
+//		if attr.Type == stun.XORPeerAddress {
+//			// Process every XOR-PEER-ADDRESS attribute
+//		}
+//	}
+func (m *Message) Range() iter.Seq[*Attribute] {
+	return func(yield func(*Attribute) bool) {
+		for i := range m.Attributes {
+			if !yield(&m.Attributes[i]) {
+				return
+			}
+		}
+	}
+}
+
+// AttrMap returns the attributes of the message grouped by type, each group
+// in message order, for lookups of repeated attributes or several types at
+// once. The map is built from Attributes on every call, which stays the
+// authoritative, ordered view: it reflects every attribute added, set or
+// removed so far, whatever the way, but not the changes made after it was
+// returned. The values share the memory of the message.
+//
+// Example:
+//
+//	attrs := msg.AttrMap()
+//	for _, attr := range attrs[stun.XORPeerAddress] {
+//		// Process every XOR-PEER-ADDRESS attribute
+//	}
+func (m Message) AttrMap() map[StunAttribute][]Attribute {
+	attrs := make(map[StunAttribute][]Attribute, len(m.Attributes))
+	for _, attr := range m.Attributes {
+		attrs[attr.Type] = append(attrs[attr.Type], attr)
+	}
+	return attrs
+}
+
 // GetXorAddr extracts the XOR-MAPPED-ADDRESS attribute from the message.
-// This method is specifically designed for handling binding responses and
-// provides a convenient way to access the client's public IP address and port.
+// This method is specifically designed for handling responses and provides a
+// convenient way to access the client's public IP address and port.
 //
-// The method checks if the message is a binding response and then looks for
-// the XOR-MAPPED-ADDRESS attribute. If found, it decodes the address information.
+// The method checks that the message is a success response, of any method,
+// and then decodes its XOR-MAPPED-ADDRESS attribute.
 //
 // Returns:
-//   - *XorMappedAddr: The decoded XOR mapped address, or nil if not found
-//   - error: Any error that occurred during decoding
+//   - *XorMappedAddr: The decoded XOR mapped address, non-nil if the error is nil
+//   - error: A *ResponseError wrapping ErrNotSuccessResponse, and the
+//     ERROR-CODE of an error response, if the message is not a success
+//     response; ErrAttrNotFound if it has no XOR-MAPPED-ADDRESS; or the
+//     decoding error of the attribute
 //
 // Example:
 //
@@ -104,13 +275,192 @@ func (m Message) GetAttr(t StunAttribute) (*Attribute, bool) {
 //	}
 //	fmt.Printf("Public IP: %s:%d\n", xorAddr.IP, xorAddr.Port)
 func (m Message) GetXorAddr() (*XorMappedAddr, error) {
-	if m.Header.Type != BindingResponse {
-		return nil, nil
+	if m.Header.Type.Class() != ClassSuccessResponse {
+		return nil, newResponseError(&m)
 	}
-	if attr, ok := m.GetAttr(XORMappedAddress); ok {
-		return decodeAddr(attr.Value), nil
+	var addr XorMappedAddr
+	if err := addr.GetFrom(&m); err != nil {
+		return nil, err
 	}
-	return nil, ErrAttrNotFound
+	return &addr, nil
+}
+
+// GetMappedAddr returns the reflexive transport address reported by the
+// message: its XOR-MAPPED-ADDRESS or, for servers that only send the
+// MAPPED-ADDRESS of RFC 3489, that one. Unlike GetXorAddr, it does not
+// check the message type.
+//
+// Returns ErrAttrNotFound if the message carries neither attribute, and the
+// decoding error of XOR-MAPPED-ADDRESS if it is malformed.
+//
+// Example:
+//
+//	addr, err := resp.GetMappedAddr()
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	fmt.Println("Public address:", addr)
+func (m Message) GetMappedAddr() (*XorMappedAddr, error) {
+	var addr XorMappedAddr
+	err := addr.GetFrom(&m)
+	if err == ErrAttrNotFound {
+		err = (*MappedAddr)(&addr).GetFrom(&m)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &addr, nil
+}
+
+// IsSuccessResponseFor reports whether m is a success response to req: the
+// class must be Success Response, and the method and transaction ID must
+// match those of the request.
+//
+// Example:
+//
+//	resp, err := client.Dial(req)
+//	if err == nil && resp.IsSuccessResponseFor(req) {
+//		// Process the response
+//	}
+func (m Message) IsSuccessResponseFor(req *Message) bool {
+	return m.isResponseFor(req, ClassSuccessResponse)
+}
+
+// IsErrorResponseFor reports whether m is an error response to req: the
+// class must be Error Response, and the method and transaction ID must match
+// those of the request.
+func (m Message) IsErrorResponseFor(req *Message) bool {
+	return m.isResponseFor(req, ClassErrorResponse)
+}
+
+func (m Message) isResponseFor(req *Message, class MessageClass) bool {
+	if req == nil {
+		return false
+	}
+	return m.Header.Type.Class() == class &&
+		m.Header.Type.Method() == req.Header.Type.Method() &&
+		m.Header.TransactionID == req.Header.TransactionID
+}
+
+// Add appends an attribute of type t with the given value and grows
+// Header.Length by the size of the encoded attribute, header and padding
+// included, so that the header stays consistent without manual bookkeeping.
+// The value is not copied.
+//
+// Returns an error matching ErrAttrTooLong, leaving m unchanged, if the
+// attribute does not fit the 16-bit length of the message.
+//
+// Example:
+//
+//	msg := &stun.Message{Header: stun.Header{Type: stun.BindingRequest}}
+//	if err := msg.Add(stun.Software, []byte("example/1.0")); err != nil {
+//		log.Fatal(err)
+//	}
+func (m *Message) Add(t StunAttribute, value []byte) error {
+	if err := checkValueLength(m, t, value, 0); err != nil {
+		return err
+	}
+	attr := newAttr(t, value)
+	m.Attributes = append(m.Attributes, attr)
+	m.Header.Length = uint16(m.attrsSize())
+	return nil
+}
+
+// Set replaces every attribute of type t with a single attribute holding
+// value, appended at the end, keeping Header.Length consistent. It fails
+// like Add, leaving m unchanged.
+func (m *Message) Set(t StunAttribute, value []byte) error {
+	freed := 0
+	for _, attr := range m.Attributes {
+		if attr.Type == t {
+			freed += 4 + attr.PaddedLength
+		}
+	}
+	if err := checkValueLength(m, t, value, freed); err != nil {
+		return err
+	}
+	m.Remove(t)
+	return m.Add(t, value)
+}
+
+// checkValueLength returns an error matching ErrAttrTooLong if an attribute
+// of type t holding value does not fit in m once freed bytes of its
+// attributes are removed: the message length, like the attribute length, is
+// 16 bits.
+func checkValueLength(m *Message, t StunAttribute, value []byte, freed int) error {
+	if size := m.attrsSize() - freed + 4 + paddedLength(len(value)); size > maxAttrsSize {
+		return fmt.Errorf("%w: attribute 0x%04x of %d bytes makes the message %d bytes long, max %d",
+			ErrAttrTooLong, uint16(t), len(value), size, maxAttrsSize)
+	}
+	return nil
+}
+
+// Remove drops every attribute of type t and shrinks Header.Length accordingly.
+func (m *Message) Remove(t StunAttribute) {
+	attrs := m.Attributes[:0]
+	for _, attr := range m.Attributes {
+		if attr.Type == t {
+			continue
+		}
+		attrs = append(attrs, attr)
+	}
+	m.Attributes = attrs
+	if size := m.attrsSize(); size <= maxAttrsSize {
+		m.Header.Length = uint16(size)
+	}
+}
+
+// Clone returns a deep copy of m: the header, and every attribute with its
+// own copy of the value and padding bytes. Messages returned by NewMessage
+// share the memory of the buffer they were decoded from, so a handler or
+// middleware keeping a message, or changing its values, after the buffer is
+// reused must work on a clone.
+//
+// Example:
+//
+//	pending[req.Message.Header.TransactionID] = req.Message.Clone()
+func (m *Message) Clone() *Message {
+	c := &Message{Header: m.Header}
+	if m.Attributes == nil {
+		return c
+	}
+	c.Attributes = make(Attributes, len(m.Attributes))
+	for i, attr := range m.Attributes {
+		attr.Value = append([]byte(nil), attr.Value...)
+		if attr.padding != nil {
+			attr.padding = append([]byte(nil), attr.padding...)
+		}
+		c.Attributes[i] = attr
+	}
+	return c
+}
+
+// Equal reports whether m and other have the same header and the same
+// attributes in the same order, attributes being compared by type and value.
+// The padding bytes, which senders may fill with anything (RFC 5389 §15),
+// are ignored, as is the capacity of the value slices, so a message equals
+// its decoded encoding.
+//
+// Example:
+//
+//	decoded, err := stun.NewMessage(msg.Encode())
+//	if err != nil || !decoded.Equal(msg) {
+//		t.Fatalf("round trip of %v failed", msg.Header.Type)
+//	}
+func (m *Message) Equal(other *Message) bool {
+	if m == nil || other == nil {
+		return m == other
+	}
+	if m.Header != other.Header || len(m.Attributes) != len(other.Attributes) {
+		return false
+	}
+	for i := range m.Attributes {
+		a, b := &m.Attributes[i], &other.Attributes[i]
+		if a.Type != b.Type || a.Length != b.Length || !bytes.Equal(a.rawValue(), b.rawValue()) {
+			return false
+		}
+	}
+	return true
 }
 
 // decodeAttrs decodes multiple STUN attributes from the given byte buffer.
@@ -121,19 +471,38 @@ func (m Message) GetXorAddr() (*XorMappedAddr, error) {
 // Each attribute has a 4-byte header (type + length) followed by the attribute value.
 //
 // Parameters:
+//   - attrs: The slice the attributes are appended to
 //   - buff: The byte buffer containing attribute data
 //   - length: The total length of attribute data to process
+//   - limits: The limits on the number and length of the attributes
 //
 // Returns:
 //   - []Attribute: A slice of decoded STUN attributes
-func decodeAttrs(buff []byte, length int) []Attribute {
+//   - error: ErrShortBuffer if an attribute overruns length, or an error
+//     wrapping ErrTooManyAttributes or ErrAttrTooLarge if the attributes
+//     exceed the limits
+func decodeAttrs(attrs []Attribute, buff []byte, length int, limits DecodeLimits) ([]Attribute, error) {
+	if len(buff) < length {
+		return nil, ErrShortBuffer
+	}
+	buff = buff[:length]
 	offset := 0
-	var attrs []Attribute
 
 	// Loop through the buffer until the entire length is processed
 	for offset < length {
+		// Check the limits before the value is sliced
+		if length-offset < 4 {
+			return nil, ErrShortBuffer
+		}
+		if err := limits.checkAttr(buff[offset:], len(attrs)+1); err != nil {
+			return nil, err
+		}
+
 		// Decode the current STUN attribute starting at the current offset
-		attr := DecodeAttr(buff[offset:])
+		attr, err := DecodeAttr(buff[offset:])
+		if err != nil {
+			return nil, err
+		}
 
 		// Append the decoded attribute to the slice
 		attrs = append(attrs, attr)
@@ -144,13 +513,61 @@ func decodeAttrs(buff []byte, length int) []Attribute {
 	}
 
 	// Return the slice of decoded attributes
-	return attrs
+	return attrs, nil
+}
+
+// EncodedLen returns the size in bytes of the encoded message: the 20-byte
+// header followed by every attribute with its 4-byte header and padding.
+// It matches len(m.Encode()) as long as no encode hook alters the message.
+// It does not allocate, so it can be used to size network buffers or check a
+// path MTU before encoding.
+//
+// Example:
+//
+//	if ind.EncodedLen() > 1280 {
+//		return errors.New("send indication exceeds the IPv6 minimum MTU")
+//	}
+//	buf := make([]byte, 0, ind.EncodedLen())
+func (m *Message) EncodedLen() int {
+	n := headrLength
+	for _, attr := range m.Attributes {
+		n += 4 + attr.PaddedLength
+	}
+	return n
+}
+
+// maxAttrsSize is the largest size of the attributes of a message, bounded by
+// the 16-bit length field of the header.
+const maxAttrsSize = 0xFFFF
+
+// attrsSize returns the size of the attributes of m once encoded.
+func (m *Message) attrsSize() int {
+	return m.EncodedLen() - headrLength
+}
+
+// attrsLength returns the length of the attributes of m once encoded, the
+// value of Header.Length of a consistent message. It must only be called on
+// messages whose attributes fit in maxAttrsSize, as Add ensures.
+func (m *Message) attrsLength() uint16 {
+	return uint16(m.attrsSize())
 }
 
 // Encode converts the Message to its binary representation.
 // This method serializes the complete STUN message including header and all attributes.
 //
+// Encoding is deterministic: attributes are written in slice order and padding
+// bytes are zero, including for attributes decoded with other padding, so
+// the same Message always yields the same bytes (unless an encode hook is
+// registered, see AddEncodeHook). EncodeRaw reproduces the padding received
+// instead.
+//
+// A message whose attributes exceed the 65535 bytes the header length can
+// express, which Add prevents but a hand-built attribute slice or an encode
+// hook may not, encodes to nil rather than to a header that does not match
+// its bytes.
+//
 // The encoding process:
+//   - Sets Header.Length to the length of the encoded attributes
 //   - Encodes the 20-byte header
 //   - Encodes each attribute in sequence
 //   - Returns the complete binary message
@@ -169,14 +586,78 @@ func decodeAttrs(buff []byte, length int) []Attribute {
 //	encoded := msg.Encode()
 //	// Send encoded message over network
 func (m *Message) Encode() []byte {
-	buff := make([]byte, m.Header.Length+20)
-	copy(buff[0:20], m.Header.Encode())
-	offset := 20
-	for _, attr := range m.Attributes {
-		encodedAttr := attr.Encode()
-		attrSize := attr.PaddedLength + 4 // 4 bytes header + padded value length
-		copy(buff[offset:offset+attrSize], encodedAttr)
-		offset += attrSize
+	return m.AppendTo(nil)
+}
+
+// EncodeRaw encodes m like Encode, except that attributes decoded by
+// NewMessage, Decode or ReadMessage keep the padding bytes they were
+// received with, so that a decoded message, unknown attributes included,
+// re-encodes byte for byte. It is meant for proxies and test harnesses
+// forwarding messages unchanged; a message whose MESSAGE-INTEGRITY or
+// FINGERPRINT was computed by this package must be sent with Encode, as
+// those cover zero padding.
+//
+// Example:
+//
+//	msg, err := stun.NewMessage(datagram)
+//	if err != nil {
+//		return err
+//	}
+//	upstream.Write(msg.EncodeRaw()) // same bytes as datagram
+func (m *Message) EncodeRaw() []byte {
+	return m.appendTo(nil, true)
+}
+
+// AppendTo appends the encoded message to buff and returns the extended
+// buffer, like Encode but without allocating when buff has the capacity for
+// it (see EncodedLen), so that busy servers can encode into pooled buffers.
+// Encode hooks still allocate a copy of the message. A message too long to
+// encode (see Encode) leaves buff unchanged.
+//
+// Example:
+//
+//	buf := bufPool.Get().(*[]byte)
+//	*buf = resp.AppendTo((*buf)[:0])
+//	conn.WriteTo(*buf, addr)
+//	bufPool.Put(buf)
+func (m *Message) AppendTo(buff []byte) []byte {
+	return m.appendTo(buff, false)
+}
+
+// appendTo is AppendTo, reproducing the padding of decoded attributes if raw
+// is set.
+func (m *Message) appendTo(buff []byte, raw bool) []byte {
+	if m.attrsSize() > maxAttrsSize {
+		return buff
+	}
+	m.Header.Length = m.attrsLength()
+	if m = applyEncodeHooks(m); m.attrsSize() > maxAttrsSize {
+		return buff
+	}
+	buff = slices.Grow(buff, headrLength+int(m.Header.Length))
+	buff = m.Header.appendTo(buff)
+	for i := range m.Attributes {
+		buff = m.Attributes[i].appendTo(buff, raw)
 	}
 	return buff
 }
+
+// MarshalBinary implements encoding.BinaryMarshaler, returning the
+// encoded message as Encode does.
+//
+// Returns an error matching ErrMessageTooLarge if the attributes of m do not
+// fit the 16-bit length of the header.
+func (m *Message) MarshalBinary() ([]byte, error) {
+	if size := m.attrsSize(); size > maxAttrsSize {
+		return nil, fmt.Errorf("%w: %d bytes of attributes, max %d", ErrMessageTooLarge, size, maxAttrsSize)
+	}
+	return m.Encode(), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler, decoding data
+// into m as Decode does. Unlike Decode, it copies data first, so that the
+// message does not share the memory of the caller, as the interface
+// requires.
+func (m *Message) UnmarshalBinary(data []byte) error {
+	return m.Decode(append([]byte(nil), data...))
+}
@@ -0,0 +1,39 @@
+package stun_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/lai0xn/stun"
+)
+
+// The seed corpus of the fuzz targets, under testdata/fuzz, holds the test
+// vectors of RFC 5769 (see stuntest.Vectors).
+
+func FuzzNewMessage(f *testing.F) {
+	f.Fuzz(func(t *testing.T, data []byte) {
+		m, err := stun.NewMessage(data)
+		if err != nil {
+			return
+		}
+		_ = m.Validate()
+		if _, err := m.MarshalJSON(); err != nil {
+			t.Fatalf("MarshalJSON: %v", err)
+		}
+
+		enc := m.Encode()
+		if len(enc) != m.EncodedLen() {
+			t.Fatalf("encoded %d bytes, EncodedLen is %d", len(enc), m.EncodedLen())
+		}
+		decoded, err := stun.NewMessage(enc)
+		if err != nil {
+			t.Fatalf("decoding the re-encoded message: %v", err)
+		}
+		if !decoded.Equal(m) {
+			t.Fatalf("re-encoded message differs:\n%x\n%x", data, enc)
+		}
+		if !bytes.Equal(decoded.Encode(), enc) {
+			t.Fatal("encoding is not stable")
+		}
+	})
+}
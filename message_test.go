@@ -0,0 +1,258 @@
+package stun
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// garbagePadded is a Binding request carrying an unknown
+// comprehension-optional attribute and a SOFTWARE, both padded with
+// non-zero bytes.
+var garbagePadded = []byte{
+	0x00, 0x01, 0x00, 0x14,
+	0x21, 0x12, 0xa4, 0x42,
+	0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c,
+	0xc0, 0x01, 0x00, 0x05, // unknown attribute
+	0x61, 0x62, 0x63, 0x64, 0x65, 0xde, 0xad, 0xbe,
+	0x80, 0x22, 0x00, 0x03, // SOFTWARE
+	0x61, 0x62, 0x63, 0xff,
+}
+
+func TestEncodeRawRoundTrip(t *testing.T) {
+	m, err := NewMessage(garbagePadded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := m.EncodeRaw(); !bytes.Equal(got, garbagePadded) {
+		t.Fatalf("EncodeRaw:\ngot  %x\nwant %x", got, garbagePadded)
+	}
+	if got := m.Clone().EncodeRaw(); !bytes.Equal(got, garbagePadded) {
+		t.Fatalf("EncodeRaw of a clone:\ngot  %x\nwant %x", got, garbagePadded)
+	}
+
+	// Changing an attribute drops its padding, not that of the others
+	if err := m.Set(Software, []byte("abcd")); err != nil {
+		t.Fatal(err)
+	}
+	want := append(bytes.Clone(garbagePadded[:32]), 0x80, 0x22, 0x00, 0x04, 0x61, 0x62, 0x63, 0x64)
+	if got := m.EncodeRaw(); !bytes.Equal(got, want) {
+		t.Fatalf("EncodeRaw after Set:\ngot  %x\nwant %x", got, want)
+	}
+}
+
+func TestIntegrityCoversReceivedPadding(t *testing.T) {
+	key := NewShortTermIntegrity("secret")
+	m, err := NewMessage(garbagePadded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := key.AddTo(m); err != nil {
+		t.Fatal(err)
+	}
+	if err := (FingerprintAttribute{}).AddTo(m); err != nil {
+		t.Fatal(err)
+	}
+
+	// The values added cover the zero padding Encode emits
+	decoded, err := NewMessage(m.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := key.Check(decoded); err != nil {
+		t.Fatalf("MESSAGE-INTEGRITY of the encoded message: %v", err)
+	}
+	if err := CheckFingerprint(m.Encode()); err != nil {
+		t.Fatalf("FINGERPRINT of the encoded message: %v", err)
+	}
+
+	// A sender padding with garbage covers its own padding bytes
+	raw := bytes.Clone(garbagePadded)
+	sent, err := NewMessage(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	length := sent.attrsLength() + 4 + MessageIntegrityLength
+	mac := key.compute(hmacSHA1, sent, len(sent.Attributes), length, true, nil)
+	raw = append(raw, 0x00, 0x08, 0x00, 0x14)
+	raw = append(raw, mac...)
+	raw[3] = byte(length)
+	received, err := NewMessage(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := key.Check(received); err != nil {
+		t.Fatalf("MESSAGE-INTEGRITY over received padding: %v", err)
+	}
+	if got := received.EncodeRaw(); !bytes.Equal(got, raw) {
+		t.Fatalf("EncodeRaw:\ngot  %x\nwant %x", got, raw)
+	}
+}
+
+// decodeCopy decodes a private copy of garbagePadded, returning the buffer
+// the message was decoded from.
+func decodeCopy(t *testing.T) ([]byte, *Message) {
+	t.Helper()
+	buf := append([]byte(nil), garbagePadded...)
+	m, err := NewMessage(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf, m
+}
+
+// scribble overwrites every attribute byte of buf.
+func scribble(buf []byte) {
+	for i := headrLength; i < len(buf); i++ {
+		buf[i] = 'x'
+	}
+}
+
+func TestNewMessageSharesBuffer(t *testing.T) {
+	buf, m := decodeCopy(t)
+	scribble(buf)
+	if got := string(m.Attributes[0].Value); got != "xxxxx" {
+		t.Fatalf("value is %q after the buffer changed, want it to share the buffer", got)
+	}
+}
+
+func TestCloneDoesNotAlias(t *testing.T) {
+	buf, m := decodeCopy(t)
+	c := m.Clone()
+	scribble(buf)
+	if got := c.EncodeRaw(); !bytes.Equal(got, garbagePadded) {
+		t.Fatalf("clone encodes to %x after the buffer changed, want %x", got, garbagePadded)
+	}
+
+	c2 := c.Clone()
+	c2.Attributes[0].Value[0] = 'z'
+	c2.Attributes = append(c2.Attributes, Attribute{Type: Software, Length: 1, Value: []byte("z")})
+	if got := c.EncodeRaw(); !bytes.Equal(got, garbagePadded) {
+		t.Fatalf("original encodes to %x after its clone changed, want %x", got, garbagePadded)
+	}
+}
+
+func TestUnmarshalBinaryCopies(t *testing.T) {
+	buf := append([]byte(nil), garbagePadded...)
+	var m Message
+	if err := m.UnmarshalBinary(buf); err != nil {
+		t.Fatal(err)
+	}
+	scribble(buf)
+	if got := m.EncodeRaw(); !bytes.Equal(got, garbagePadded) {
+		t.Fatalf("message encodes to %x after the caller's data changed, want %x", got, garbagePadded)
+	}
+}
+
+func TestReset(t *testing.T) {
+	_, m := decodeCopy(t)
+	attrs := m.Attributes
+	m.Reset()
+	if m.Header != (Header{}) {
+		t.Fatalf("header is %+v after Reset, want zero", m.Header)
+	}
+	if len(m.Attributes) != 0 || cap(m.Attributes) != cap(attrs) {
+		t.Fatalf("attributes have len %d, cap %d after Reset, want 0, %d", len(m.Attributes), cap(m.Attributes), cap(attrs))
+	}
+	for i, attr := range attrs {
+		if attr.Value != nil || attr.padding != nil {
+			t.Fatalf("attribute %d still references its buffer after Reset", i)
+		}
+	}
+
+	if err := m.Decode(append([]byte(nil), garbagePadded...)); err != nil {
+		t.Fatal(err)
+	}
+	if &m.Attributes[0] != &attrs[0] {
+		t.Fatal("Decode after Reset did not reuse the attribute slice")
+	}
+}
+
+func TestAddRejectsOversizedValue(t *testing.T) {
+	m := NewBindingRequest()
+	if err := m.Add(Software, []byte("abc")); err != nil {
+		t.Fatal(err)
+	}
+	before := m.Clone()
+
+	big := make([]byte, 0x10000)
+	if err := m.Add(Data, big); !errors.Is(err, ErrAttrTooLong) {
+		t.Fatalf("Add: got %v, want %v", err, ErrAttrTooLong)
+	}
+	if err := m.Set(Software, big); !errors.Is(err, ErrAttrTooLong) {
+		t.Fatalf("Set: got %v, want %v", err, ErrAttrTooLong)
+	}
+	if !m.Equal(before) || m.Header.Length != before.Header.Length {
+		t.Fatalf("message changed by failed calls: %+v, want %+v", m, before)
+	}
+	if _, err := NewMessageBuilder(BindingRequest).Add(Data, big).Build(); !errors.Is(err, ErrAttrTooLong) {
+		t.Fatalf("MessageBuilder.Add: got %v, want %v", err, ErrAttrTooLong)
+	}
+}
+
+func TestAddRejectsOversizedMessage(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		values []int
+	}{
+		// Padded to 0x10000 bytes, it only fits the attribute length
+		{"largest attribute value", []int{0xFFFF}},
+		{"two values", []int{40000, 40000}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			m := NewBindingRequest()
+			var err error
+			for _, n := range tc.values {
+				if err = m.Add(Data, make([]byte, n)); err != nil {
+					break
+				}
+			}
+			if !errors.Is(err, ErrAttrTooLong) {
+				t.Fatalf("got %v, want %v", err, ErrAttrTooLong)
+			}
+			if int(m.Header.Length) != m.EncodedLen()-headrLength {
+				t.Fatalf("header length %d, attributes take %d bytes", m.Header.Length, m.EncodedLen()-headrLength)
+			}
+		})
+	}
+
+	// The longest message that fits: 4 bytes of header and 65528 of value
+	m := NewBindingRequest()
+	if err := m.Add(Data, make([]byte, 0xFFF8)); err != nil {
+		t.Fatalf("Add of the largest value: %v", err)
+	}
+	if err := m.Add(DontFragment, nil); !errors.Is(err, ErrAttrTooLong) {
+		t.Fatalf("Add to a full message: got %v, want %v", err, ErrAttrTooLong)
+	}
+	if enc := m.Encode(); len(enc) != headrLength+0xFFFC {
+		t.Fatalf("encoded %d bytes, want %d", len(enc), headrLength+0xFFFC)
+	}
+
+	// Set may reuse the room of the attributes it replaces
+	if err := m.Set(Data, make([]byte, 0xFFF8)); err != nil {
+		t.Fatalf("Set in place of the largest value: %v", err)
+	}
+}
+
+func TestEncodeRefusesOversizedMessage(t *testing.T) {
+	m := NewBindingRequest()
+	for range 2 {
+		m.Attributes = append(m.Attributes, newAttr(Data, make([]byte, 40000)))
+	}
+	if enc := m.Encode(); enc != nil {
+		t.Fatalf("encoded %d bytes with header length %d, want nil", len(enc), m.Header.Length)
+	}
+	if buf := m.AppendTo([]byte("x")); string(buf) != "x" {
+		t.Fatalf("AppendTo extended the buffer to %d bytes", len(buf))
+	}
+	if _, err := m.MarshalBinary(); !errors.Is(err, ErrMessageTooLarge) {
+		t.Fatalf("MarshalBinary: got %v, want %v", err, ErrMessageTooLarge)
+	}
+
+	// Remove recomputes the length instead of subtracting from a stale one
+	m.Header.Length = 8
+	m.Remove(Data)
+	if m.Header.Length != 0 {
+		t.Fatalf("header length %d after removing every attribute, want 0", m.Header.Length)
+	}
+}
@@ -0,0 +1,87 @@
+package stun
+
+import (
+	"expvar"
+	"fmt"
+	"sync"
+)
+
+// metrics holds the process-wide counters of the package. They are published
+// through expvar under the "stun" key, so importing net/http/pprof or
+// expvar's handler exposes them at /debug/vars.
+var metrics = expvar.NewMap("stun")
+
+// Metric names published under the "stun" expvar map.
+const (
+	MetricSocketDrops     = "kernel_socket_drops"
+	MetricUDPInErrors     = "kernel_udp_in_errors"
+	MetricUDPRcvbufErrors = "kernel_udp_rcvbuf_errors"
+	MetricMessageSizes    = "message_sizes"
+	MetricAttributeTypes  = "attribute_types"
+	MetricAgentQueued     = "agent_queued_transactions"
+	MetricAgentRejected   = "agent_rejected_transactions"
+)
+
+// setGauge sets the metric name to v.
+func setGauge(name string, v int64) {
+	g := new(expvar.Int)
+	g.Set(v)
+	metrics.Set(name, g)
+}
+
+// messageSizeBuckets are the upper bounds, in bytes, of the buckets of the
+// message size histogram. Larger messages are counted in "gt_1024".
+var messageSizeBuckets = [...]int{64, 128, 256, 512, 1024}
+
+// maxAttributeTypeKeys bounds the number of distinct attribute types counted
+// individually, so that peers sending random types cannot grow the map
+// without limit. Types beyond the limit are counted under "other".
+const maxAttributeTypeKeys = 64
+
+// traffic holds the optional message size histogram and attribute usage
+// counters, published on first use (see ServerConfig.TrafficStats).
+var traffic struct {
+	once       sync.Once
+	sizes      expvar.Map
+	attributes expvar.Map
+
+	mu    sync.Mutex
+	types int
+}
+
+// recordTraffic counts a received message of size bytes in the size
+// histogram and its attribute types in the usage counters. Only aggregate
+// counts are kept: no address, credential or attribute value is recorded.
+func recordTraffic(size int, m *Message) {
+	traffic.once.Do(func() {
+		metrics.Set(MetricMessageSizes, &traffic.sizes)
+		metrics.Set(MetricAttributeTypes, &traffic.attributes)
+	})
+
+	bucket := fmt.Sprintf("gt_%d", messageSizeBuckets[len(messageSizeBuckets)-1])
+	for _, le := range messageSizeBuckets {
+		if size <= le {
+			bucket = fmt.Sprintf("le_%d", le)
+			break
+		}
+	}
+	traffic.sizes.Add(bucket, 1)
+
+	for _, attr := range m.Attributes {
+		key := fmt.Sprintf("0x%04x", uint16(attr.Type))
+		if traffic.attributes.Get(key) != nil {
+			traffic.attributes.Add(key, 1)
+			continue
+		}
+		traffic.mu.Lock()
+		if traffic.attributes.Get(key) == nil {
+			if traffic.types >= maxAttributeTypeKeys {
+				key = "other"
+			} else {
+				traffic.types++
+			}
+		}
+		traffic.attributes.Add(key, 1)
+		traffic.mu.Unlock()
+	}
+}
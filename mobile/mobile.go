@@ -0,0 +1,281 @@
+// Package mobile is a facade over the stun package restricted to the types
+// gomobile can bind (strings, integers, booleans, pointers to structs of
+// those and callback interfaces), so that Android and iOS applications can
+// discover their public address, keep their NAT mapping alive and classify
+// their NAT with this stack:
+//
+//	gomobile bind -target android -o stun.aar github.com/lai0xn/stun/mobile
+//	gomobile bind -target ios -o Stun.xcframework github.com/lai0xn/stun/mobile
+//
+// Timeouts and intervals are given in milliseconds, and servers as
+// "host:port" strings, the port defaulting to 3478.
+package mobile
+
+import (
+	"crypto/rand"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/lai0xn/stun"
+)
+
+// Behaviors reported by NATReport (RFC 4787 §4.1 and §5).
+const (
+	EndpointIndependent     = "endpoint-independent"
+	AddressDependent        = "address-dependent"
+	AddressAndPortDependent = "address-and-port-dependent"
+	// Unknown is reported when the server cannot run the tests, as it does
+	// not advertise an OTHER-ADDRESS.
+	Unknown = "unknown"
+)
+
+// errNoMappedAddress is returned when a response carries no mapped address.
+var errNoMappedAddress = errors.New("response has no mapped address")
+
+// Address is a transport address.
+type Address struct {
+	IP   string
+	Port int
+}
+
+// Report is the result of NATReport.
+type Report struct {
+	// Public is the address the server saw the requests coming from.
+	Public *Address
+	// Mapping is the mapping behavior of the NAT, one of the behavior
+	// constants.
+	Mapping string
+	// Filtering is the filtering behavior of the NAT, one of the behavior
+	// constants.
+	Filtering string
+}
+
+// Discover sends a Binding request to server and returns the public address
+// it reports.
+func Discover(server string, timeoutMillis int) (*Address, error) {
+	p, err := newProber(server, timeoutMillis)
+	if err != nil {
+		return nil, err
+	}
+	defer p.conn.Close()
+
+	resp, err := p.transact(stun.NewBindingRequest(), p.server)
+	if err != nil {
+		return nil, err
+	}
+	return mappedAddress(resp)
+}
+
+// NATReport classifies the mapping and filtering behaviors of the NAT in
+// front of the device with the tests of RFC 5780 §4.3 and §4.4, against a
+// server listening on two IP addresses and advertising OTHER-ADDRESS. Each
+// test waits timeoutMillis for a response, a timeout meaning that the NAT
+// filtered it. The outcome is added to the NAT statistics when telemetry is
+// enabled (see EnableTelemetry).
+func NATReport(server string, timeoutMillis int) (*Report, error) {
+	p, err := newProber(server, timeoutMillis)
+	if err != nil {
+		return nil, err
+	}
+	defer p.conn.Close()
+
+	resp, err := p.transact(stun.NewBindingRequest(), p.server)
+	if err != nil {
+		return nil, err
+	}
+	public, err := mappedAddress(resp)
+	if err != nil {
+		return nil, err
+	}
+	report := &Report{Public: public, Mapping: Unknown, Filtering: Unknown}
+	var other stun.OtherAddr
+	if err := other.GetFrom(resp); err != nil {
+		// RFC 3489 servers advertise it as CHANGED-ADDRESS
+		if err := (*stun.ChangedAddr)(&other).GetFrom(resp); err != nil {
+			recordReport(report)
+			return report, nil
+		}
+	}
+
+	// Mapping tests II and III: same local socket, other server addresses
+	otherIP := &net.UDPAddr{IP: other.IP, Port: p.server.Port}
+	otherIPPort := &net.UDPAddr{IP: other.IP, Port: int(other.Port)}
+	if a2, err := p.mappedAddress(otherIP); err == nil {
+		if *a2 == *public {
+			report.Mapping = EndpointIndependent
+		} else if a3, err := p.mappedAddress(otherIPPort); err == nil {
+			report.Mapping = AddressAndPortDependent
+			if *a3 == *a2 {
+				report.Mapping = AddressDependent
+			}
+		}
+	}
+
+	// Filtering tests II and III, from a fresh socket so that the mapping
+	// tests have not opened the NAT to the other server addresses
+	f, err := newProber(server, timeoutMillis)
+	if err != nil {
+		return nil, err
+	}
+	defer f.conn.Close()
+	report.Filtering = AddressAndPortDependent
+	for _, test := range []struct {
+		changeIP bool
+		behavior string
+	}{
+		{true, EndpointIndependent},
+		{false, AddressDependent},
+	} {
+		_, err := f.transact(stun.NewChangeRequest(test.changeIP, true), f.server)
+		if err == nil {
+			report.Filtering = test.behavior
+			break
+		}
+		if netErr, ok := err.(net.Error); !ok || !netErr.Timeout() {
+			return nil, err
+		}
+	}
+	recordReport(report)
+	return report, nil
+}
+
+// KeepaliveListener receives the events of a Keepalive. Its methods are
+// called from the goroutine of the keepalive and must not block.
+type KeepaliveListener interface {
+	// OnMapping is called with the public address after the first Binding
+	// transaction and whenever it changes, e.g. after the NAT rebooted.
+	OnMapping(address *Address)
+	// OnError is called when a Binding transaction fails.
+	OnError(message string)
+}
+
+// Keepalive sends Binding requests to a server at a regular interval from
+// one socket, so that the NAT mapping of the socket does not expire.
+type Keepalive struct {
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// StartKeepalive starts sending a Binding request to server every
+// intervalMillis, reporting the public address to listener. Intervals under
+// the 15 seconds NATs commonly keep UDP mappings for are advisable.
+func StartKeepalive(server string, intervalMillis int, listener KeepaliveListener) (*Keepalive, error) {
+	interval := time.Duration(intervalMillis) * time.Millisecond
+	if interval <= 0 {
+		return nil, errors.New("keepalive interval must be positive")
+	}
+	p, err := newProber(server, intervalMillis)
+	if err != nil {
+		return nil, err
+	}
+	k := &Keepalive{stop: make(chan struct{}), done: make(chan struct{})}
+	go k.run(p, interval, listener)
+	return k, nil
+}
+
+// run sends the Binding requests until Stop.
+func (k *Keepalive) run(p *prober, interval time.Duration, listener KeepaliveListener) {
+	defer close(k.done)
+	defer p.conn.Close()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var last Address
+	for {
+		addr, err := p.mappedAddress(p.server)
+		switch {
+		case err != nil:
+			listener.OnError(err.Error())
+		case *addr != last:
+			last = *addr
+			listener.OnMapping(addr)
+		}
+		select {
+		case <-ticker.C:
+		case <-k.stop:
+			return
+		}
+	}
+}
+
+// Stop stops the keepalive and closes its socket. It returns once no more
+// listener call can happen.
+func (k *Keepalive) Stop() {
+	k.once.Do(func() { close(k.stop) })
+	<-k.done
+}
+
+// prober runs transactions with a server from a single socket.
+type prober struct {
+	conn    *net.UDPConn
+	server  *net.UDPAddr
+	timeout time.Duration
+}
+
+// newProber resolves server and opens the socket of the transactions.
+func newProber(server string, timeoutMillis int) (*prober, error) {
+	addr, err := net.ResolveUDPAddr("udp", stun.HostPortWithDefault(server, false))
+	if err != nil {
+		return nil, err
+	}
+	network := "udp6"
+	if addr.IP.To4() != nil {
+		network = "udp4"
+	}
+	conn, err := net.ListenUDP(network, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &prober{conn: conn, server: addr, timeout: time.Duration(timeoutMillis) * time.Millisecond}, nil
+}
+
+// transact sends req to dst and waits for the response with the same
+// transaction ID. The magic cookie and transaction ID of req are set.
+func (p *prober) transact(req *stun.Message, dst *net.UDPAddr) (*stun.Message, error) {
+	req.Header.MagicCookie = 0x2112A442
+	if _, err := rand.Read(req.Header.TransactionID[:]); err != nil {
+		return nil, err
+	}
+	if _, err := p.conn.WriteToUDP(req.Encode(), dst); err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, 2048)
+	if err := p.conn.SetReadDeadline(time.Now().Add(p.timeout)); err != nil {
+		return nil, err
+	}
+	for {
+		n, _, err := p.conn.ReadFromUDP(buf)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := stun.NewMessage(buf[:n])
+		if err != nil || !resp.IsSuccessResponseFor(req) {
+			continue // Not a response to this request
+		}
+		return resp, nil
+	}
+}
+
+// mappedAddress runs a Binding transaction with dst and returns the public
+// address it reports.
+func (p *prober) mappedAddress(dst *net.UDPAddr) (*Address, error) {
+	resp, err := p.transact(stun.NewBindingRequest(), dst)
+	if err != nil {
+		return nil, err
+	}
+	return mappedAddress(resp)
+}
+
+// mappedAddress returns the XOR-MAPPED-ADDRESS of resp, or its
+// MAPPED-ADDRESS for servers that only send the latter.
+func mappedAddress(resp *stun.Message) (*Address, error) {
+	addr, err := resp.GetMappedAddr()
+	if err != nil {
+		return nil, errNoMappedAddress
+	}
+	return &Address{IP: addr.IP.String(), Port: int(addr.Port)}, nil
+}
@@ -0,0 +1,107 @@
+package mobile
+
+import (
+	"net"
+	"sync"
+)
+
+// NATStats are the aggregated outcomes of the NATReport runs of the process:
+// counts of the behaviors observed, and never addresses, so that they can be
+// collected across a fleet without tracking users.
+type NATStats struct {
+	// Runs is the number of NATReport runs that completed.
+	Runs int64
+	// NoNAT counts the runs whose public address is an address of the
+	// device.
+	NoNAT int64
+	// Symmetric counts the runs whose mapping is not endpoint-independent,
+	// which defeats peer-to-peer connectivity without a relay.
+	Symmetric int64
+
+	MappingEndpointIndependent     int64
+	MappingAddressDependent        int64
+	MappingAddressAndPortDependent int64
+	MappingUnknown                 int64
+
+	FilteringEndpointIndependent     int64
+	FilteringAddressDependent        int64
+	FilteringAddressAndPortDependent int64
+	FilteringUnknown                 int64
+}
+
+// TelemetrySink receives the NAT statistics of the process once telemetry is
+// enabled with EnableTelemetry. OnNATStats is called after every NATReport
+// run with the updated totals, from the goroutine of the run.
+type TelemetrySink interface {
+	OnNATStats(stats *NATStats)
+}
+
+var (
+	telemetryMu   sync.Mutex
+	telemetrySink TelemetrySink
+	telemetry     NATStats
+)
+
+// EnableTelemetry opts in to NAT statistics, delivered to sink. Telemetry is
+// off by default; a nil sink turns it off again. Runs are only counted while
+// telemetry is enabled, and the totals are kept when it is turned off.
+func EnableTelemetry(sink TelemetrySink) {
+	telemetryMu.Lock()
+	defer telemetryMu.Unlock()
+	telemetrySink = sink
+}
+
+// recordReport adds the outcome of a NATReport run to the statistics and
+// hands them to the sink, when telemetry is enabled.
+func recordReport(r *Report) {
+	telemetryMu.Lock()
+	sink := telemetrySink
+	if sink == nil {
+		telemetryMu.Unlock()
+		return
+	}
+	telemetry.Runs++
+	if localAddress(r.Public.IP) {
+		telemetry.NoNAT++
+	}
+	switch r.Mapping {
+	case EndpointIndependent:
+		telemetry.MappingEndpointIndependent++
+	case AddressDependent:
+		telemetry.MappingAddressDependent++
+		telemetry.Symmetric++
+	case AddressAndPortDependent:
+		telemetry.MappingAddressAndPortDependent++
+		telemetry.Symmetric++
+	default:
+		telemetry.MappingUnknown++
+	}
+	switch r.Filtering {
+	case EndpointIndependent:
+		telemetry.FilteringEndpointIndependent++
+	case AddressDependent:
+		telemetry.FilteringAddressDependent++
+	case AddressAndPortDependent:
+		telemetry.FilteringAddressAndPortDependent++
+	default:
+		telemetry.FilteringUnknown++
+	}
+	stats := telemetry
+	telemetryMu.Unlock()
+
+	sink.OnNATStats(&stats)
+}
+
+// localAddress reports whether ip is assigned to an interface of the device.
+func localAddress(ip string) bool {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return false
+	}
+	for _, addr := range addrs {
+		if network, ok := addr.(*net.IPNet); ok && network.IP.String() == ip {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,308 @@
+package stun
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// DefaultNonceLifetime is the validity of the nonces of a NonceManager whose
+// lifetime is left unset (RFC 8489 §9.2 suggests one hour).
+const DefaultNonceLifetime = time.Hour
+
+// StateStore keeps the nonce and replay protection state of a server. Keys
+// are opaque strings that expire at a given time. Implementations must be
+// safe for concurrent use.
+//
+// MemoryStore keeps the state in process; FileStore also persists it so that
+// a restart does not invalidate every outstanding nonce at once, which would
+// have all clients retry after a 438 (Stale Nonce) at the same time. A shared
+// store such as Redis is plugged in by implementing the interface, e.g. Set
+// as SET key 1 PXAT expires, SetNX as SET key 1 NX PXAT expires and Expiry as
+// PEXPIRETIME key.
+type StateStore interface {
+	// Set records key until expires.
+	Set(key string, expires time.Time) error
+	// SetNX records key until expires unless it is already recorded and
+	// unexpired, atomically, and reports whether it did.
+	SetNX(key string, expires time.Time) (bool, error)
+	// Expiry returns when key expires, and false if it is unknown or has
+	// already expired.
+	Expiry(key string) (time.Time, bool, error)
+}
+
+// MemoryStore is a StateStore held in memory. Expired keys are dropped as
+// new ones are set.
+type MemoryStore struct {
+	mu      sync.Mutex
+	keys    map[string]time.Time
+	nextGC  time.Time
+	gcEvery time.Duration
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{keys: make(map[string]time.Time), gcEvery: time.Minute}
+}
+
+// Set implements StateStore.
+func (s *MemoryStore) Set(key string, expires time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.gc(time.Now())
+	s.keys[key] = expires
+	return nil
+}
+
+// SetNX implements StateStore.
+func (s *MemoryStore) SetNX(key string, expires time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	s.gc(now)
+	if exp, ok := s.keys[key]; ok && !now.After(exp) {
+		return false, nil
+	}
+	s.keys[key] = expires
+	return true, nil
+}
+
+// gc drops the expired keys, at most every gcEvery. s.mu must be held.
+func (s *MemoryStore) gc(now time.Time) {
+	if !now.After(s.nextGC) {
+		return
+	}
+	for k, exp := range s.keys {
+		if now.After(exp) {
+			delete(s.keys, k)
+		}
+	}
+	s.nextGC = now.Add(s.gcEvery)
+}
+
+// Expiry implements StateStore.
+func (s *MemoryStore) Expiry(key string) (time.Time, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	exp, ok := s.keys[key]
+	if !ok || time.Now().After(exp) {
+		return time.Time{}, false, nil
+	}
+	return exp, true, nil
+}
+
+// FileStore is a MemoryStore persisted to a JSON file, written atomically
+// by Flush, every flush interval and on Close, and loaded back by
+// NewFileStore.
+type FileStore struct {
+	*MemoryStore
+	path string
+
+	flushMu sync.Mutex
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// NewFileStore returns a FileStore persisted at path, loading the unexpired
+// keys the file already holds. A positive flushInterval flushes the state in
+// the background at that period.
+//
+// Example:
+//
+//	store, err := stun.NewFileStore("/var/lib/stun/nonces.json", 10*time.Second)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	defer store.Close()
+//	nonces := stun.NewNonceManager(store, 0)
+func NewFileStore(path string, flushInterval time.Duration) (*FileStore, error) {
+	s := &FileStore{MemoryStore: NewMemoryStore(), path: path}
+
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		var keys map[string]time.Time
+		if err := json.Unmarshal(data, &keys); err != nil {
+			return nil, err
+		}
+		now := time.Now()
+		for k, exp := range keys {
+			if exp.After(now) {
+				s.keys[k] = exp
+			}
+		}
+	case !os.IsNotExist(err):
+		return nil, err
+	}
+
+	if flushInterval > 0 {
+		s.stop, s.done = make(chan struct{}), make(chan struct{})
+		go s.flushLoop(flushInterval)
+	}
+	return s, nil
+}
+
+// Flush writes the unexpired keys to the file, replacing it atomically.
+func (s *FileStore) Flush() error {
+	s.flushMu.Lock()
+	defer s.flushMu.Unlock()
+
+	s.mu.Lock()
+	now := time.Now()
+	keys := make(map[string]time.Time, len(s.keys))
+	for k, exp := range s.keys {
+		if exp.After(now) {
+			keys[k] = exp
+		}
+	}
+	s.mu.Unlock()
+
+	data, err := json.Marshal(keys)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+// Close stops the background flushing, if any, and flushes the state.
+func (s *FileStore) Close() error {
+	if s.stop != nil {
+		close(s.stop)
+		<-s.done
+		s.stop = nil
+	}
+	return s.Flush()
+}
+
+// flushLoop flushes the state every interval until Close.
+func (s *FileStore) flushLoop(interval time.Duration) {
+	defer close(s.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.Flush()
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// NonceManager issues the nonces of the long-term credential mechanism and
+// validates them, rejecting replayed requests, with its state kept in a
+// StateStore.
+type NonceManager struct {
+	store    StateStore
+	lifetime time.Duration
+}
+
+// NewNonceManager returns a NonceManager keeping its state in store. A zero
+// lifetime selects DefaultNonceLifetime.
+func NewNonceManager(store StateStore, lifetime time.Duration) *NonceManager {
+	if lifetime == 0 {
+		lifetime = DefaultNonceLifetime
+	}
+	return &NonceManager{store: store, lifetime: lifetime}
+}
+
+// Issue returns a new random nonce, valid for the lifetime of the manager,
+// to be sent with a 401 (Unauthorized) or 438 (Stale Nonce) response.
+func (n *NonceManager) Issue() (string, error) {
+	var b [18]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	nonce := base64.RawURLEncoding.EncodeToString(b[:])
+	if err := n.store.Set(nonceKey(nonce), time.Now().Add(n.lifetime)); err != nil {
+		return "", err
+	}
+	return nonce, nil
+}
+
+// nonceKey returns the store key of nonce. Nonces are stored hashed, so
+// that lookups of forged nonces, whose timing may depend on how much of the
+// key matches a stored one, reveal nothing about valid nonces, and so that a
+// leaked store does not hand out usable nonces.
+func nonceKey(nonce string) string {
+	h := sha256.Sum256([]byte(nonce))
+	return "nonce:" + hex.EncodeToString(h[:])
+}
+
+// Verify checks the NONCE of the request m: it must have been issued by the
+// manager and not have expired, the message integrity of m must match key,
+// the long-term key of the user, and the transaction must not have been seen
+// with the nonce before. Only authenticated requests are recorded for replay
+// protection, until the nonce expires, so that forged requests cannot grow
+// the store; the record is atomic (see StateStore.SetNX), so that concurrent
+// replays of a request are all rejected but one.
+//
+// MESSAGE-INTEGRITY-SHA256 is checked if m carries it, MESSAGE-INTEGRITY
+// otherwise.
+//
+// Returns ErrAttrNotFound if m has no NONCE or message integrity attribute,
+// ErrStaleNonce if the nonce is unknown or expired, ErrIntegrityMismatch if
+// the message integrity does not match and ErrReplayedRequest if the
+// transaction was already seen with the nonce.
+//
+// Example:
+//
+//	switch err := nonces.Verify(req.Message, key); err {
+//	case nil:
+//	case stun.ErrStaleNonce:
+//		// Respond 438 (Stale Nonce) with a new nonce from nonces.Issue
+//	case stun.ErrIntegrityMismatch:
+//		// Respond 401 (Unauthorized)
+//	default:
+//		// Respond 400 (Bad Request) or drop
+//	}
+func (n *NonceManager) Verify(m *Message, key Integrity) error {
+	nonce, err := m.GetNonce()
+	if err != nil {
+		return err
+	}
+	expires, ok, err := n.store.Expiry(nonceKey(nonce))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrStaleNonce
+	}
+
+	if _, ok := m.GetAttr(MessageIntegritySHA256); ok {
+		err = NewIntegritySHA256(key).Check(m)
+	} else {
+		err = key.Check(m)
+	}
+	if err != nil {
+		return err
+	}
+
+	replayKey := "replay:" + nonceKey(nonce) + ":" + m.Header.TransactionID.String()
+	first, err := n.store.SetNX(replayKey, expires)
+	if err != nil {
+		return err
+	}
+	if !first {
+		return ErrReplayedRequest
+	}
+	return nil
+}
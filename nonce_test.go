@@ -0,0 +1,146 @@
+package stun
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// authenticatedRequest returns a Binding request carrying nonce and the
+// MESSAGE-INTEGRITY computed with key.
+func authenticatedRequest(t *testing.T, nonce string, key Integrity) *Message {
+	t.Helper()
+	m := NewBindingRequest()
+	if err := Build(m, BindingRequest, NewUsername("alice"), NewRealm("example.org"), NewNonce(nonce), key); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// storeLen returns the number of keys in s.
+func storeLen(s *MemoryStore) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.keys)
+}
+
+func TestNonceManagerVerify(t *testing.T) {
+	key := NewLongTermIntegrity("alice", "example.org", "secret")
+	nonces := NewNonceManager(NewMemoryStore(), 0)
+	nonce, err := nonces.Issue()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := authenticatedRequest(t, nonce, key)
+	if err := nonces.Verify(req, key); err != nil {
+		t.Fatalf("first request: %v", err)
+	}
+	if err := nonces.Verify(req, key); err != ErrReplayedRequest {
+		t.Fatalf("replayed request: got %v, want %v", err, ErrReplayedRequest)
+	}
+	if err := nonces.Verify(authenticatedRequest(t, "forged", key), key); err != ErrStaleNonce {
+		t.Fatalf("unknown nonce: got %v, want %v", err, ErrStaleNonce)
+	}
+}
+
+func TestNonceManagerRecordsAuthenticatedRequestsOnly(t *testing.T) {
+	key := NewLongTermIntegrity("alice", "example.org", "secret")
+	store := NewMemoryStore()
+	nonces := NewNonceManager(store, 0)
+	nonce, err := nonces.Issue()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	before := storeLen(store)
+	forged := NewLongTermIntegrity("alice", "example.org", "guess")
+	for i := 0; i < 100; i++ {
+		if err := nonces.Verify(authenticatedRequest(t, nonce, forged), key); err != ErrIntegrityMismatch {
+			t.Fatalf("forged request: got %v, want %v", err, ErrIntegrityMismatch)
+		}
+	}
+	if n := storeLen(store); n != before {
+		t.Fatalf("forged requests grew the store from %d to %d keys", before, n)
+	}
+}
+
+func TestNonceManagerConcurrentReplays(t *testing.T) {
+	key := NewLongTermIntegrity("alice", "example.org", "secret")
+	nonces := NewNonceManager(NewMemoryStore(), 0)
+	nonce, err := nonces.Issue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := authenticatedRequest(t, nonce, key)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	accepted := 0
+	for i := 0; i < 32; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if nonces.Verify(req, key) == nil {
+				mu.Lock()
+				accepted++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if accepted != 1 {
+		t.Fatalf("%d concurrent copies of a request accepted, want 1", accepted)
+	}
+}
+
+func TestMemoryStoreSetNX(t *testing.T) {
+	s := NewMemoryStore()
+	if ok, err := s.SetNX("k", time.Now().Add(time.Hour)); !ok || err != nil {
+		t.Fatalf("SetNX of a new key = %v, %v", ok, err)
+	}
+	if ok, _ := s.SetNX("k", time.Now().Add(time.Hour)); ok {
+		t.Fatal("SetNX of a recorded key succeeded")
+	}
+	if ok, _ := s.SetNX("expired", time.Now().Add(-time.Second)); !ok {
+		t.Fatal("SetNX of a new key failed")
+	}
+	if ok, _ := s.SetNX("expired", time.Now().Add(time.Hour)); !ok {
+		t.Fatal("SetNX of an expired key failed")
+	}
+}
+
+func TestFileStorePersistsNonces(t *testing.T) {
+	key := NewLongTermIntegrity("alice", "example.org", "secret")
+	path := filepath.Join(t.TempDir(), "nonces.json")
+	store, err := NewFileStore(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce, err := NewNonceManager(store, 0).Issue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := authenticatedRequest(t, nonce, key)
+	if err := NewNonceManager(store, 0).Verify(req, key); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// After a restart, the nonce is still valid and the request still seen
+	restarted, err := NewFileStore(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer restarted.Close()
+	nonces := NewNonceManager(restarted, 0)
+	if err := nonces.Verify(req, key); err != ErrReplayedRequest {
+		t.Fatalf("replay after restart: got %v, want %v", err, ErrReplayedRequest)
+	}
+	if err := nonces.Verify(authenticatedRequest(t, nonce, key), key); err != nil {
+		t.Fatalf("new request with the persisted nonce: %v", err)
+	}
+}
@@ -0,0 +1,72 @@
+package stun
+
+import "fmt"
+
+// CheckAttrOrder verifies the placement rules of the attributes protecting
+// the message (RFC 8489 §14.5, §14.6 and §14.7): MESSAGE-INTEGRITY and
+// MESSAGE-INTEGRITY-SHA256 may only be followed by FINGERPRINT, or by
+// MESSAGE-INTEGRITY-SHA256 for MESSAGE-INTEGRITY, and FINGERPRINT must be
+// last. Each of them may appear at most once. Receivers ignore attributes
+// past these ones, so a misplaced attribute makes the integrity check fail
+// at the other end.
+//
+// Returns an error wrapping ErrAttrOrder describing the first violation.
+func (m *Message) CheckAttrOrder() error {
+	var integrity, integritySHA256, fingerprint bool
+	for _, attr := range m.Attributes {
+		switch {
+		case fingerprint:
+			return fmt.Errorf("%w: attribute 0x%04x after FINGERPRINT", ErrAttrOrder, uint16(attr.Type))
+		case attr.Type == FingerprintAttr:
+			fingerprint = true
+		case attr.Type == MessageIntegritySHA256:
+			if integritySHA256 {
+				return fmt.Errorf("%w: duplicate MESSAGE-INTEGRITY-SHA256", ErrAttrOrder)
+			}
+			integritySHA256 = true
+		case attr.Type == MessageIntegrity:
+			if integrity {
+				return fmt.Errorf("%w: duplicate MESSAGE-INTEGRITY", ErrAttrOrder)
+			}
+			if integritySHA256 {
+				return fmt.Errorf("%w: MESSAGE-INTEGRITY after MESSAGE-INTEGRITY-SHA256", ErrAttrOrder)
+			}
+			integrity = true
+		case integrity || integritySHA256:
+			return fmt.Errorf("%w: attribute 0x%04x after MESSAGE-INTEGRITY", ErrAttrOrder, uint16(attr.Type))
+		}
+	}
+	return nil
+}
+
+// FixAttrOrder moves the MESSAGE-INTEGRITY, MESSAGE-INTEGRITY-SHA256 and
+// FINGERPRINT attributes of m to the end of the message, in that order,
+// keeping the relative order of the other attributes. Duplicates are not
+// removed.
+//
+// The attribute values are kept, so an integrity or fingerprint computed
+// before attributes were moved in front of it must be computed again.
+// FixAttrOrder has the signature of an EncodeHook; Client.FixAttrOrder and
+// ServerConfig.FixAttrOrder apply it to outgoing messages.
+func FixAttrOrder(m *Message) {
+	rank := func(t StunAttribute) int {
+		switch t {
+		case MessageIntegrity:
+			return 1
+		case MessageIntegritySHA256:
+			return 2
+		case FingerprintAttr:
+			return 3
+		}
+		return 0
+	}
+	attrs := make(Attributes, 0, len(m.Attributes))
+	for r := 0; r <= 3; r++ {
+		for _, attr := range m.Attributes {
+			if rank(attr.Type) == r {
+				attrs = append(attrs, attr)
+			}
+		}
+	}
+	m.Attributes = attrs
+}
@@ -0,0 +1,19 @@
+package stun
+
+// OtherAddr is the value of an OTHER-ADDRESS attribute (RFC 5780 §7.4): the
+// alternate IP address and port of a server listening on two addresses.
+// Clients send follow-up requests there, or ask for responses from it with
+// CHANGE-REQUEST, to classify NAT mapping and filtering behavior.
+type OtherAddr MappedAddr
+
+// AddTo appends the address to m as an OTHER-ADDRESS attribute.
+func (a OtherAddr) AddTo(m *Message) error {
+	return AddressAttribute{Type: OtherAddress}.Add(m, MappedAddr(a))
+}
+
+// GetFrom decodes the OTHER-ADDRESS attribute of m into a.
+//
+// Returns ErrAttrNotFound if m has no OTHER-ADDRESS attribute.
+func (a *OtherAddr) GetFrom(m *Message) error {
+	return getAddr(m, AddressAttribute{Type: OtherAddress}, (*MappedAddr)(a))
+}
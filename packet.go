@@ -14,7 +14,12 @@ type Packet struct {
   remotePort uint16
 }
 func NewPacket(con *net.UDPConn, buff []byte,remoteAddr *net.UDPAddr) (*Packet, error) {
-	msg, err := NewMessage(buff) // Assuming NewMessage is defined elsewhere
+	return newPacket(con, buff, remoteAddr, false)
+}
+
+// newPacket is NewPacket, also accepting RFC 3489 messages if classic is set.
+func newPacket(con *net.UDPConn, buff []byte, remoteAddr *net.UDPAddr, classic bool) (*Packet, error) {
+	msg, err := decodeMessage(buff, classic)
 	if err != nil {
 		return nil, err
 	}
@@ -48,18 +53,16 @@ func NewPacket(con *net.UDPConn, buff []byte,remoteAddr *net.UDPAddr) (*Packet,
 }
 
 
+// Write sends the encoded message buff to remoteAddr as is: it was encoded,
+// hooks included, by the caller, so it is neither parsed nor encoded again.
 func (p *Packet) Write(buff []byte,remoteAddr *net.UDPAddr) (int, error) {
-	msg, err := NewMessage(buff)
-	if err != nil {
-		return 0, err
-	}
-	n, err := p.con.WriteTo(msg.Encode(),remoteAddr)
+	n, err := p.con.WriteTo(buff,remoteAddr)
 
 	if err != nil {
 		return 0, err
 	}
 
-	if n < (int(msg.Header.Length) + headrLength) {
+	if n < len(buff) {
 		return n, ErrShortWrite
 	}
 	return n, nil
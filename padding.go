@@ -0,0 +1,43 @@
+package stun
+
+import "fmt"
+
+// PaddingAttribute is the value of a PADDING attribute (RFC 5780 §7.6): Length
+// bytes of zeros whose only purpose is to grow the message, e.g. to force IP
+// fragmentation or probe the path MTU through a NAT.
+type PaddingAttribute struct {
+	Length int
+}
+
+// AddTo appends Length bytes of padding to m as a PADDING attribute.
+func (p PaddingAttribute) AddTo(m *Message) error {
+	if p.Length < 0 || paddedLength(p.Length) > maxAttrValueLength(m) {
+		return fmt.Errorf("invalid padding length: %d", p.Length)
+	}
+	return m.Add(Padding, make([]byte, p.Length))
+}
+
+// PadTo appends a PADDING attribute so that the encoded message is exactly
+// size bytes long. Since attributes are 4-byte aligned, size must be a multiple
+// of 4 and leave room for the 4-byte attribute header.
+//
+// Example:
+//
+//	// Probe a 1400 bytes path MTU
+//	msg := &stun.Message{Header: stun.Header{Type: stun.BindingRequest}}
+//	if err := msg.PadTo(1400 - 28); err != nil { // minus IPv4 and UDP headers
+//		log.Fatal(err)
+//	}
+func (m *Message) PadTo(size int) error {
+	current := m.EncodedLen()
+	if size%4 != 0 || size < current+4 {
+		return fmt.Errorf("cannot pad a %d bytes message to %d bytes", current, size)
+	}
+	return PaddingAttribute{Length: size - current - 4}.AddTo(m)
+}
+
+// maxAttrValueLength returns the largest attribute value that still fits in
+// the 16-bit message length of m.
+func maxAttrValueLength(m *Message) int {
+	return maxAttrsSize - 4 - m.attrsSize()
+}
@@ -0,0 +1,190 @@
+package stun
+
+import (
+	"crypto/sha256"
+	"fmt"
+)
+
+// PasswordAlgorithmID identifies the key derivation of the long-term
+// credential mechanism (RFC 8489 §18.5).
+type PasswordAlgorithmID uint16
+
+// Password algorithms registered by RFC 8489.
+const (
+	PasswordAlgorithmMD5    PasswordAlgorithmID = 0x0001
+	PasswordAlgorithmSHA256 PasswordAlgorithmID = 0x0002
+)
+
+// String returns the registered name of the algorithm.
+func (a PasswordAlgorithmID) String() string {
+	switch a {
+	case PasswordAlgorithmMD5:
+		return "MD5"
+	case PasswordAlgorithmSHA256:
+		return "SHA-256"
+	default:
+		return fmt.Sprintf("0x%04x", uint16(a))
+	}
+}
+
+// PasswordAlgorithmAttribute is the value of a PASSWORD-ALGORITHM attribute
+// (RFC 8489 §14.12), the algorithm selected by the client, and an element of
+// PASSWORD-ALGORITHMS.
+//
+//	 0                   1                   2                   3
+//	 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1
+//	+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+//	|          Algorithm           |  Algorithm Parameters Length   |
+//	+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+//	|                    Algorithm Parameters (variable)
+//	+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+type PasswordAlgorithmAttribute struct {
+	Algorithm  PasswordAlgorithmID
+	Parameters []byte
+}
+
+// AddTo appends the algorithm to m as a PASSWORD-ALGORITHM attribute.
+func (p PasswordAlgorithmAttribute) AddTo(m *Message) error {
+	return m.Add(PasswordAlgorithm, p.appendTo(nil))
+}
+
+// GetFrom decodes the PASSWORD-ALGORITHM attribute of m into p.
+//
+// Returns ErrAttrNotFound if m has no PASSWORD-ALGORITHM attribute.
+func (p *PasswordAlgorithmAttribute) GetFrom(m *Message) error {
+	attr, ok := m.GetAttr(PasswordAlgorithm)
+	if !ok {
+		return ErrAttrNotFound
+	}
+	v, _, err := decodePasswordAlgorithm(attr.rawValue())
+	if err != nil {
+		return err
+	}
+	*p = v
+	return nil
+}
+
+// appendTo appends the wire encoding of p, parameters padded, to buff.
+func (p PasswordAlgorithmAttribute) appendTo(buff []byte) []byte {
+	buff = append(buff,
+		byte(p.Algorithm>>8), byte(p.Algorithm),
+		byte(len(p.Parameters)>>8), byte(len(p.Parameters)),
+	)
+	buff = append(buff, p.Parameters...)
+	return append(buff, make([]byte, paddedLength(len(p.Parameters))-len(p.Parameters))...)
+}
+
+// decodePasswordAlgorithm decodes the algorithm at the start of buff and
+// returns it with the size it takes, parameters padding included.
+func decodePasswordAlgorithm(buff []byte) (PasswordAlgorithmAttribute, int, error) {
+	if len(buff) < 4 {
+		return PasswordAlgorithmAttribute{}, 0, ErrShortBuffer
+	}
+	n := int(buff[2])<<8 | int(buff[3])
+	if len(buff) < 4+n {
+		return PasswordAlgorithmAttribute{}, 0, ErrShortBuffer
+	}
+	p := PasswordAlgorithmAttribute{
+		Algorithm: PasswordAlgorithmID(uint16(buff[0])<<8 | uint16(buff[1])),
+	}
+	if n > 0 {
+		p.Parameters = append([]byte(nil), buff[4:4+n]...)
+	}
+	size := 4 + paddedLength(n)
+	if size > len(buff) {
+		size = len(buff)
+	}
+	return p, size, nil
+}
+
+// PasswordAlgorithmsAttribute is the value of a PASSWORD-ALGORITHMS attribute
+// (RFC 8489 §14.11): the algorithms a server supports, in order of
+// preference.
+type PasswordAlgorithmsAttribute []PasswordAlgorithmAttribute
+
+// AddTo appends the list to m as a PASSWORD-ALGORITHMS attribute.
+func (p PasswordAlgorithmsAttribute) AddTo(m *Message) error {
+	var value []byte
+	for _, alg := range p {
+		value = alg.appendTo(value)
+	}
+	return m.Add(PasswordAlgorithms, value)
+}
+
+// GetFrom decodes the PASSWORD-ALGORITHMS attribute of m into p.
+//
+// Returns ErrAttrNotFound if m has no PASSWORD-ALGORITHMS attribute.
+func (p *PasswordAlgorithmsAttribute) GetFrom(m *Message) error {
+	attr, ok := m.GetAttr(PasswordAlgorithms)
+	if !ok {
+		return ErrAttrNotFound
+	}
+	value := attr.rawValue()
+	var list PasswordAlgorithmsAttribute
+	for len(value) > 0 {
+		alg, n, err := decodePasswordAlgorithm(value)
+		if err != nil {
+			return err
+		}
+		list = append(list, alg)
+		value = value[n:]
+	}
+	*p = list
+	return nil
+}
+
+// NegotiatePasswordAlgorithm selects the algorithm a client puts in
+// PASSWORD-ALGORITHM: the first of the algorithms offered by the server that
+// the client supports (RFC 8489 §9.2.4).
+//
+// Returns ErrNoPasswordAlgorithm if none is supported.
+//
+// Example:
+//
+//	var offered stun.PasswordAlgorithmsAttribute
+//	if err := offered.GetFrom(resp); err != nil {
+//		log.Fatal(err)
+//	}
+//	alg, err := stun.NegotiatePasswordAlgorithm(offered,
+//		stun.PasswordAlgorithmSHA256, stun.PasswordAlgorithmMD5)
+func NegotiatePasswordAlgorithm(offered PasswordAlgorithmsAttribute, supported ...PasswordAlgorithmID) (PasswordAlgorithmAttribute, error) {
+	for _, alg := range offered {
+		for _, id := range supported {
+			if alg.Algorithm == id {
+				return alg, nil
+			}
+		}
+	}
+	return PasswordAlgorithmAttribute{}, ErrNoPasswordAlgorithm
+}
+
+// NewLongTermIntegrityWithAlgorithm returns the integrity key for the
+// long-term credential mechanism derived with the given password algorithm
+// (RFC 8489 §9.2.2): the MD5 or SHA-256 of username ":" OpaqueString(realm)
+// ":" OpaqueString(password). The username is hashed as is, being already
+// prepared with OpaqueString as sent in USERNAME.
+//
+// Returns ErrNoPasswordAlgorithm for an unknown algorithm, and an error
+// wrapping ErrStringPrep if realm or password cannot be prepared.
+func NewLongTermIntegrityWithAlgorithm(alg PasswordAlgorithmID, username, realm, password string) (Integrity, error) {
+	if alg != PasswordAlgorithmMD5 && alg != PasswordAlgorithmSHA256 {
+		return nil, ErrNoPasswordAlgorithm
+	}
+	realm, err := OpaqueString(realm)
+	if err != nil {
+		return nil, err
+	}
+	password, err = OpaqueString(password)
+	if err != nil {
+		return nil, err
+	}
+	switch alg {
+	case PasswordAlgorithmMD5:
+		return NewLongTermIntegrity(username, realm, password), nil
+	case PasswordAlgorithmSHA256:
+		k := sha256.Sum256([]byte(username + ":" + realm + ":" + password))
+		return Integrity(k[:]), nil
+	default:
+		return nil, ErrNoPasswordAlgorithm
+	}
+}
@@ -0,0 +1,53 @@
+package stun
+
+import (
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Well-known ports of STUN and TURN (RFC 5389 §9, RFC 7064 §3.2).
+const (
+	// DefaultPort is the port of STUN and TURN over UDP and TCP.
+	DefaultPort = 3478
+	// DefaultTLSPort is the port of STUN and TURN over TLS and DTLS.
+	DefaultTLSPort = 5349
+)
+
+// SchemeDefaultPort returns the default port of a STUN or TURN URI scheme
+// (RFC 7064 §3.2, RFC 7065 §3.2): DefaultPort for "stun" and "turn",
+// DefaultTLSPort for "stuns" and "turns". Schemes are case-insensitive.
+// It returns false for other schemes.
+func SchemeDefaultPort(scheme string) (int, bool) {
+	switch strings.ToLower(scheme) {
+	case "stun", "turn":
+		return DefaultPort, true
+	case "stuns", "turns":
+		return DefaultTLSPort, true
+	}
+	return 0, false
+}
+
+// HostPortWithDefault returns hostport with the default port appended when
+// it has none: DefaultTLSPort if secure, DefaultPort otherwise. IPv6
+// literals, bracketed or not, are returned bracketed.
+//
+// Example:
+//
+//	stun.HostPortWithDefault("stun.example.org", false) // "stun.example.org:3478"
+//	stun.HostPortWithDefault("[2001:db8::1]", true)     // "[2001:db8::1]:5349"
+//	stun.HostPortWithDefault("192.0.2.1:19302", false)  // unchanged
+func HostPortWithDefault(hostport string, secure bool) string {
+	port := DefaultPort
+	if secure {
+		port = DefaultTLSPort
+	}
+	host, p, err := net.SplitHostPort(hostport)
+	switch {
+	case err == nil && p != "":
+		return hostport
+	case err != nil:
+		host = strings.TrimSuffix(strings.TrimPrefix(hostport, "["), "]")
+	}
+	return net.JoinHostPort(host, strconv.Itoa(port))
+}
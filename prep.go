@@ -0,0 +1,160 @@
+package stun
+
+import (
+	"fmt"
+	"unicode/utf8"
+
+	"golang.org/x/text/secure/precis"
+	"golang.org/x/text/unicode/bidi"
+	"golang.org/x/text/unicode/norm"
+)
+
+// OpaqueString prepares a username, realm or password with the OpaqueString
+// profile of PRECIS (RFC 8265 §4.2), as RFC 8489 §9.2.2 and §14.3 require
+// before credentials are sent or hashed: non-ASCII spaces are mapped to
+// U+0020, the string is normalized to NFC, and control and other disallowed
+// characters are rejected. Printable ASCII is returned unchanged.
+//
+// Returns an error wrapping ErrStringPrep if s is empty, not valid UTF-8 or
+// holds a disallowed character.
+//
+// Example:
+//
+//	password, err := stun.OpaqueString(input)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+func OpaqueString(s string) (string, error) {
+	if isPrintableASCII(s) && s != "" {
+		return s, nil
+	}
+	prepared, err := precis.OpaqueString.String(s)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrStringPrep, err)
+	}
+	return prepared, nil
+}
+
+// SASLprep prepares a password with the SASLprep profile of stringprep
+// (RFC 4013), as RFC 5389 §15.4 requires before the long-term and short-term
+// keys are derived: characters commonly mapped to nothing are removed,
+// non-ASCII spaces are mapped to U+0020, the string is normalized to NFKC,
+// and prohibited characters and bidirectional text violating RFC 3454 §6 are
+// rejected. Unassigned code points are allowed, as for queries. Printable
+// ASCII is returned unchanged.
+//
+// Returns an error wrapping ErrStringPrep if s is not valid UTF-8, holds a
+// prohibited character or mixes directions.
+func SASLprep(s string) (string, error) {
+	if isPrintableASCII(s) {
+		return s, nil
+	}
+	if !utf8.ValidString(s) {
+		return "", fmt.Errorf("%w: %w", ErrStringPrep, ErrInvalidUTF8)
+	}
+
+	mapped := make([]rune, 0, len(s))
+	for _, r := range s {
+		switch {
+		case saslMappedToNothing(r):
+		case saslNonASCIISpace(r):
+			mapped = append(mapped, ' ')
+		default:
+			mapped = append(mapped, r)
+		}
+	}
+	prepared := norm.NFKC.String(string(mapped))
+
+	var randAL, l bool
+	var first, last rune
+	for i, r := range prepared {
+		if saslProhibited(r) {
+			return "", fmt.Errorf("%w: prohibited character U+%04X", ErrStringPrep, r)
+		}
+		props, _ := bidi.LookupRune(r)
+		switch props.Class() {
+		case bidi.R, bidi.AL:
+			randAL = true
+		case bidi.L:
+			l = true
+		}
+		if i == 0 {
+			first = r
+		}
+		last = r
+	}
+	if randAL && (l || !isRandAL(first) || !isRandAL(last)) {
+		return "", fmt.Errorf("%w: invalid bidirectional text", ErrStringPrep)
+	}
+	return prepared, nil
+}
+
+// isPrintableASCII reports whether s only holds the printable ASCII
+// characters, which both profiles leave unchanged.
+func isPrintableASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < 0x20 || s[i] > 0x7E {
+			return false
+		}
+	}
+	return true
+}
+
+// isRandAL reports whether r is a right-to-left character (RFC 3454 §6).
+func isRandAL(r rune) bool {
+	props, _ := bidi.LookupRune(r)
+	return props.Class() == bidi.R || props.Class() == bidi.AL
+}
+
+// saslMappedToNothing reports whether r is in table B.1 of RFC 3454.
+func saslMappedToNothing(r rune) bool {
+	switch {
+	case r == 0x00AD, r == 0x034F, r == 0x1806, r == 0x2060, r == 0xFEFF:
+		return true
+	case r >= 0x180B && r <= 0x180D, r >= 0x200B && r <= 0x200D, r >= 0xFE00 && r <= 0xFE0F:
+		return true
+	}
+	return false
+}
+
+// saslNonASCIISpace reports whether r is in table C.1.2 of RFC 3454.
+func saslNonASCIISpace(r rune) bool {
+	switch {
+	case r == 0x00A0, r == 0x1680, r == 0x202F, r == 0x205F, r == 0x3000:
+		return true
+	case r >= 0x2000 && r <= 0x200B:
+		return true
+	}
+	return false
+}
+
+// saslProhibited reports whether r is prohibited by RFC 4013 §2.3: in tables
+// C.1.2 and C.2.1 to C.9 of RFC 3454.
+func saslProhibited(r rune) bool {
+	switch {
+	case saslNonASCIISpace(r):
+		return true
+	case r < 0x20, r >= 0x7F && r <= 0x9F: // C.2.1, C.2.2
+		return true
+	case r == 0x06DD, r == 0x070F, r == 0x180E, r == 0x200C, r == 0x200D,
+		r == 0x2028, r == 0x2029, r == 0xFEFF,
+		r >= 0x2060 && r <= 0x2063, r >= 0x206A && r <= 0x206F,
+		r >= 0xFFF9 && r <= 0xFFFC, r >= 0x1D173 && r <= 0x1D17A: // C.2.2
+		return true
+	case r >= 0xE000 && r <= 0xF8FF, r >= 0xF0000 && r <= 0xFFFFD,
+		r >= 0x100000 && r <= 0x10FFFD: // C.3
+		return true
+	case r >= 0xFDD0 && r <= 0xFDEF, r&0xFFFE == 0xFFFE: // C.4
+		return true
+	case r >= 0xD800 && r <= 0xDFFF, r == 0xFFFD: // C.5, C.6
+		return true
+	case r >= 0x2FF0 && r <= 0x2FFB: // C.7
+		return true
+	case r == 0x0340, r == 0x0341, r == 0x200E, r == 0x200F,
+		r >= 0x202A && r <= 0x202E: // C.8
+		return true
+	case r == 0xE0001, r >= 0xE0020 && r <= 0xE007F: // C.9
+		return true
+	}
+	return false
+}
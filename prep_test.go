@@ -0,0 +1,100 @@
+package stun
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestSASLprep(t *testing.T) {
+	for _, tt := range []struct {
+		name, in, want string
+		wantErr        bool
+	}{
+		// RFC 4013 §3
+		{name: "soft hyphen mapped to nothing", in: "I\u00adX", want: "IX"},
+		{name: "no transformation", in: "user", want: "user"},
+		{name: "case preserved", in: "USER", want: "USER"},
+		{name: "output is NFKC", in: "\u00aa", want: "a"},
+		{name: "output is NFKC roman numeral", in: "\u2168", want: "IX"},
+		{name: "prohibited character", in: "\u0007", wantErr: true},
+		{name: "bidirectional check", in: "\u06271", wantErr: true},
+
+		{name: "RFC 5769 §2.4 password", in: "The\u00adM\u00aatr\u2168", want: "TheMatrIX"},
+		{name: "non-ASCII space", in: "a\u00a0b", want: "a b"},
+		{name: "zero width space", in: "a\u200bb", want: "ab"},
+		{name: "right-to-left only", in: "\u06271\u0628", want: "\u06271\u0628"},
+		{name: "right-to-left mixed with left-to-right", in: "\u0627a\u0628", wantErr: true},
+		{name: "private use", in: "\ue000", wantErr: true},
+		{name: "surrogate replacement", in: "\ufffd", wantErr: true},
+		{name: "direction override", in: "a\u202eb", wantErr: true},
+		{name: "tag character", in: "\U000e0041", wantErr: true},
+		{name: "invalid UTF-8", in: "\xff", wantErr: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SASLprep(tt.in)
+			if tt.wantErr {
+				if !errors.Is(err, ErrStringPrep) {
+					t.Fatalf("got %q, %v, want an error matching %v", got, err, ErrStringPrep)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Fatalf("got %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpaqueString(t *testing.T) {
+	for _, tt := range []struct {
+		name, in, want string
+		wantErr        bool
+	}{
+		{name: "printable ASCII", in: "pass word", want: "pass word"},
+		{name: "non-ASCII space", in: "a\u00a0b", want: "a b"},
+		{name: "NFC", in: "e\u0301", want: "\u00e9"},
+		{name: "empty", in: "", wantErr: true},
+		{name: "control character", in: "a\u0007", wantErr: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := OpaqueString(tt.in)
+			if tt.wantErr {
+				if !errors.Is(err, ErrStringPrep) {
+					t.Fatalf("got %q, %v, want an error matching %v", got, err, ErrStringPrep)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Fatalf("got %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestPreparedIntegrity(t *testing.T) {
+	key, err := NewShortTermIntegrityPrepared("I\u00adX")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(key, NewShortTermIntegrity("IX")) {
+		t.Fatalf("short-term key %x, want that of %q", key, "IX")
+	}
+	if _, err := NewShortTermIntegrityPrepared("\u0007"); !errors.Is(err, ErrStringPrep) {
+		t.Fatalf("short-term: got %v, want %v", err, ErrStringPrep)
+	}
+
+	key, err = NewLongTermIntegrityPrepared("user", "re\u00adalm", "\u2168")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := NewLongTermIntegrity("user", "realm", "IX"); !bytes.Equal(key, want) {
+		t.Fatalf("long-term key %x, want %x", key, want)
+	}
+	if _, err := NewLongTermIntegrityPrepared("user", "\u0627a", "pass"); !errors.Is(err, ErrStringPrep) {
+		t.Fatalf("long-term realm: got %v, want %v", err, ErrStringPrep)
+	}
+	if _, err := NewLongTermIntegrityPrepared("user", "realm", "\ue000"); !errors.Is(err, ErrStringPrep) {
+		t.Fatalf("long-term password: got %v, want %v", err, ErrStringPrep)
+	}
+}
@@ -0,0 +1,111 @@
+package stun
+
+import (
+	"bytes"
+	"net"
+)
+
+// proxySignature starts every PROXY protocol version 2 header.
+var proxySignature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// PROXY protocol v2 header fields (HAProxy PROXY protocol specification §2.2).
+const (
+	proxyHeaderLength = 16
+	proxyCmdLocal     = 0x20
+	proxyCmdProxy     = 0x21
+	proxyFamilyInet   = 0x10
+	proxyFamilyInet6  = 0x20
+	proxyStream       = 0x01
+	proxyDgram        = 0x02
+)
+
+// AppendProxyHeader appends to buff a PROXY protocol version 2 header
+// announcing a connection from src to dst, both *net.TCPAddr or *net.UDPAddr
+// of the same IP family. A proxy relaying STUN to a server configured with
+// ServerConfig.TrustedProxies puts it in front of every datagram, so that the
+// server answers with the address of the actual client.
+//
+// Example:
+//
+//	pkt := stun.AppendProxyHeader(nil, tlsConn.RemoteAddr(), tlsConn.LocalAddr())
+//	pkt = append(pkt, msg...)
+//	backend.Write(pkt)
+func AppendProxyHeader(buff []byte, src, dst net.Addr) ([]byte, error) {
+	srcPort, srcIP, err := GetPortAndIPFromAddr(src)
+	if err != nil {
+		return nil, err
+	}
+	dstPort, dstIP, err := GetPortAndIPFromAddr(dst)
+	if err != nil {
+		return nil, err
+	}
+
+	proto := byte(proxyStream)
+	if _, ok := src.(*net.UDPAddr); ok {
+		proto = proxyDgram
+	}
+	family, size := byte(proxyFamilyInet), net.IPv4len
+	if srcIP.To4() == nil || dstIP.To4() == nil {
+		family, size = proxyFamilyInet6, net.IPv6len
+		srcIP, dstIP = srcIP.To16(), dstIP.To16()
+	} else {
+		srcIP, dstIP = srcIP.To4(), dstIP.To4()
+	}
+	if srcIP == nil || dstIP == nil {
+		return nil, ErrInvalidProxyHeader
+	}
+
+	length := 2*size + 4
+	buff = append(buff, proxySignature...)
+	buff = append(buff, proxyCmdProxy, family|proto, byte(length>>8), byte(length))
+	buff = append(buff, srcIP...)
+	buff = append(buff, dstIP...)
+	return append(buff, byte(srcPort>>8), byte(srcPort), byte(dstPort>>8), byte(dstPort)), nil
+}
+
+// parseProxyHeader parses the PROXY protocol version 2 header at the start of
+// buff and returns the source address it announces and the size of the
+// header. The source is nil for LOCAL headers and unsupported families,
+// meaning the address of the sender applies.
+//
+// Returns ErrInvalidProxyHeader if buff does not start with a valid header.
+func parseProxyHeader(buff []byte) (*net.UDPAddr, int, error) {
+	if len(buff) < proxyHeaderLength || !bytes.Equal(buff[:len(proxySignature)], proxySignature) {
+		return nil, 0, ErrInvalidProxyHeader
+	}
+	cmd, family := buff[12], buff[13]&0xF0
+	n := proxyHeaderLength + (int(buff[14])<<8 | int(buff[15]))
+	if len(buff) < n || (cmd != proxyCmdLocal && cmd != proxyCmdProxy) {
+		return nil, 0, ErrInvalidProxyHeader
+	}
+	if cmd == proxyCmdLocal {
+		return nil, n, nil
+	}
+
+	addrs := buff[proxyHeaderLength:n]
+	var size int
+	switch family {
+	case proxyFamilyInet:
+		size = net.IPv4len
+	case proxyFamilyInet6:
+		size = net.IPv6len
+	default:
+		return nil, n, nil
+	}
+	if len(addrs) < 2*size+4 {
+		return nil, 0, ErrInvalidProxyHeader
+	}
+	port := int(addrs[2*size])<<8 | int(addrs[2*size+1])
+	ip := append(net.IP(nil), addrs[:size]...)
+	return &net.UDPAddr{IP: ip, Port: port}, n, nil
+}
+
+// trustedProxy reports whether addr is one of the trusted proxies of the server.
+func (s *Server) trustedProxy(addr *net.UDPAddr) bool {
+	for _, network := range s.trustedProxies {
+		if network.Contains(addr.IP) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,134 @@
+package stun
+
+import "strings"
+
+// REALM and NONCE limits (RFC 5389 §15.7 and §15.8): both values must be
+// fewer than 128 characters, which can be as long as 763 bytes once UTF-8 encoded.
+const (
+	MaxRealmLength = 763
+	MaxNonceLength = 763
+	maxQuotedChars = 127
+)
+
+// SetRealm sets the REALM attribute of the message, replacing any existing one.
+//
+// The realm may be given either bare or as a quoted-string (RFC 3261 §25.1),
+// as found in SIP or HTTP digest challenges; the enclosing quotes are stripped
+// and quoted-pairs resolved before the value is put on the wire.
+//
+// Returns a *TextLengthError, matching ErrAttrTooLong, if the realm has 128
+// characters or more or exceeds MaxRealmLength bytes, an error wrapping
+// ErrInvalidUTF8 if it is not valid UTF-8, and ErrInvalidQuotedString if the
+// quoting is malformed.
+//
+// Example:
+//
+//	if err := msg.SetRealm(`"example.org"`); err != nil {
+//		log.Fatal(err)
+//	}
+func (m *Message) SetRealm(realm string) error {
+	v, err := unquote(realm)
+	if err != nil {
+		return err
+	}
+	return realmText.Set(m, v)
+}
+
+// GetRealm returns the value of the REALM attribute, without padding.
+//
+// Returns ErrAttrNotFound if the message has no REALM attribute, and the
+// errors of SetRealm if the received value is invalid.
+func (m Message) GetRealm() (string, error) {
+	return realmText.Get(&m)
+}
+
+// SetNonce sets the NONCE attribute of the message, replacing any existing one.
+// Quoting rules and limits are the same as for SetRealm.
+func (m *Message) SetNonce(nonce string) error {
+	v, err := unquote(nonce)
+	if err != nil {
+		return err
+	}
+	return nonceText.Set(m, v)
+}
+
+// GetNonce returns the value of the NONCE attribute, without padding.
+//
+// Returns ErrAttrNotFound if the message has no NONCE attribute, and the
+// errors of SetNonce if the received value is invalid.
+func (m Message) GetNonce() (string, error) {
+	return nonceText.Get(&m)
+}
+
+// RealmAttribute is the value of a REALM attribute, for message composition
+// with Build.
+type RealmAttribute string
+
+// NewRealm returns the REALM attribute with the given value, bare or quoted.
+func NewRealm(realm string) RealmAttribute {
+	return RealmAttribute(realm)
+}
+
+// AddTo sets the REALM attribute of m, as SetRealm does.
+func (r RealmAttribute) AddTo(m *Message) error {
+	return m.SetRealm(string(r))
+}
+
+// GetFrom decodes the REALM attribute of m into r, as GetRealm does.
+func (r *RealmAttribute) GetFrom(m *Message) error {
+	v, err := m.GetRealm()
+	if err != nil {
+		return err
+	}
+	*r = RealmAttribute(v)
+	return nil
+}
+
+// NonceAttribute is the value of a NONCE attribute, for message composition
+// with Build.
+type NonceAttribute string
+
+// NewNonce returns the NONCE attribute with the given value, bare or quoted.
+func NewNonce(nonce string) NonceAttribute {
+	return NonceAttribute(nonce)
+}
+
+// AddTo sets the NONCE attribute of m, as SetNonce does.
+func (n NonceAttribute) AddTo(m *Message) error {
+	return m.SetNonce(string(n))
+}
+
+// GetFrom decodes the NONCE attribute of m into n, as GetNonce does.
+func (n *NonceAttribute) GetFrom(m *Message) error {
+	v, err := m.GetNonce()
+	if err != nil {
+		return err
+	}
+	*n = NonceAttribute(v)
+	return nil
+}
+
+// unquote strips the enclosing DQUOTEs of a quoted-string and resolves its
+// quoted-pairs. Values that are not quoted are returned unchanged.
+func unquote(s string) (string, error) {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return s, nil
+	}
+	inner := s[1 : len(s)-1]
+	var b strings.Builder
+	for i := 0; i < len(inner); i++ {
+		switch c := inner[i]; c {
+		case '\\':
+			i++
+			if i == len(inner) {
+				return "", ErrInvalidQuotedString
+			}
+			b.WriteByte(inner[i])
+		case '"':
+			return "", ErrInvalidQuotedString
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,148 @@
+package stun
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// AttrCodec converts between the value of a custom attribute and a typed Go
+// value. Marshal receives the values given to Message.AddValue, so it
+// should reject values of unexpected types with an error.
+type AttrCodec struct {
+	// Name is the name of the attribute, used in error messages.
+	Name string
+	// Marshal returns the attribute value of v, without padding.
+	Marshal func(v any) ([]byte, error)
+	// Unmarshal decodes the attribute value, without padding.
+	Unmarshal func(value []byte) (any, error)
+}
+
+var (
+	attrCodecsMu sync.Mutex
+	attrCodecs   atomic.Pointer[map[StunAttribute]AttrCodec]
+)
+
+// RegisterAttr registers the codec of the attribute type t, typically a
+// vendor-specific attribute in the 0xC000-0xFFFF range, so that applications
+// read and write it as a typed value with Message.Value and
+// Message.AddValue rather than parsing raw bytes.
+//
+// Returns ErrAttrRegistered if t already has a codec.
+//
+// Example:
+//
+//	type NetworkInfo struct{ ID, Cost uint16 }
+//
+//	const GoogNetworkInfo stun.StunAttribute = 0xC057
+//
+//	stun.RegisterAttr(GoogNetworkInfo, stun.AttrCodec{
+//		Name: "GOOG-NETWORK-INFO",
+//		Marshal: func(v any) ([]byte, error) {
+//			info, ok := v.(NetworkInfo)
+//			if !ok {
+//				return nil, fmt.Errorf("unexpected %T", v)
+//			}
+//			return binary.BigEndian.AppendUint16(binary.BigEndian.AppendUint16(nil, info.ID), info.Cost), nil
+//		},
+//		Unmarshal: func(b []byte) (any, error) {
+//			if len(b) < 4 {
+//				return nil, stun.ErrShortBuffer
+//			}
+//			return NetworkInfo{binary.BigEndian.Uint16(b), binary.BigEndian.Uint16(b[2:])}, nil
+//		},
+//	})
+func RegisterAttr(t StunAttribute, c AttrCodec) error {
+	attrCodecsMu.Lock()
+	defer attrCodecsMu.Unlock()
+
+	current := registeredAttrs()
+	if _, ok := current[t]; ok {
+		return ErrAttrRegistered
+	}
+	codecs := make(map[StunAttribute]AttrCodec, len(current)+1)
+	for k, v := range current {
+		codecs[k] = v
+	}
+	codecs[t] = c
+	attrCodecs.Store(&codecs)
+	return nil
+}
+
+// UnregisterAttr removes the codec of the attribute type t, if any.
+func UnregisterAttr(t StunAttribute) {
+	attrCodecsMu.Lock()
+	defer attrCodecsMu.Unlock()
+
+	current := registeredAttrs()
+	if _, ok := current[t]; !ok {
+		return
+	}
+	codecs := make(map[StunAttribute]AttrCodec, len(current))
+	for k, v := range current {
+		if k != t {
+			codecs[k] = v
+		}
+	}
+	attrCodecs.Store(&codecs)
+}
+
+// registeredAttrs returns the registered codecs. The map must not be modified.
+func registeredAttrs() map[StunAttribute]AttrCodec {
+	if codecs := attrCodecs.Load(); codecs != nil {
+		return *codecs
+	}
+	return nil
+}
+
+// LookupAttr returns the codec registered for the attribute type t.
+func LookupAttr(t StunAttribute) (AttrCodec, bool) {
+	c, ok := registeredAttrs()[t]
+	return c, ok
+}
+
+// AddValue appends v to m as an attribute of type t, marshaled by the codec
+// registered for t.
+//
+// Returns ErrAttrNotRegistered if t has no codec, and the error of the codec
+// if v cannot be marshaled.
+func (m *Message) AddValue(t StunAttribute, v any) error {
+	c, ok := LookupAttr(t)
+	if !ok {
+		return ErrAttrNotRegistered
+	}
+	value, err := c.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("%s: %w", c.Name, err)
+	}
+	return m.Add(t, value)
+}
+
+// Value returns the first attribute of type t in m, unmarshaled by the codec
+// registered for t.
+//
+// Returns ErrAttrNotRegistered if t has no codec, ErrAttrNotFound if m has
+// no such attribute, and the error of the codec if the value is malformed.
+//
+// Example:
+//
+//	v, err := msg.Value(GoogNetworkInfo)
+//	if err == nil {
+//		info := v.(NetworkInfo)
+//		log.Printf("network %d, cost %d", info.ID, info.Cost)
+//	}
+func (m Message) Value(t StunAttribute) (any, error) {
+	c, ok := LookupAttr(t)
+	if !ok {
+		return nil, ErrAttrNotRegistered
+	}
+	attr, ok := m.GetAttr(t)
+	if !ok {
+		return nil, ErrAttrNotFound
+	}
+	v, err := c.Unmarshal(attr.rawValue())
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", c.Name, err)
+	}
+	return v, nil
+}
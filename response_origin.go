@@ -0,0 +1,18 @@
+package stun
+
+// ResponseOriginAddr is the value of a RESPONSE-ORIGIN attribute (RFC 5780 §7.3):
+// the transport address the server sent the response from. Comparing it with
+// the address the request was sent to reveals ALGs rewriting addresses in transit.
+type ResponseOriginAddr MappedAddr
+
+// AddTo appends the address to m as a RESPONSE-ORIGIN attribute.
+func (a ResponseOriginAddr) AddTo(m *Message) error {
+	return AddressAttribute{Type: ResponseOrigin}.Add(m, MappedAddr(a))
+}
+
+// GetFrom decodes the RESPONSE-ORIGIN attribute of m into a.
+//
+// Returns ErrAttrNotFound if m has no RESPONSE-ORIGIN attribute.
+func (a *ResponseOriginAddr) GetFrom(m *Message) error {
+	return getAddr(m, AddressAttribute{Type: ResponseOrigin}, (*MappedAddr)(a))
+}
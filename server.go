@@ -1,7 +1,10 @@
 package stun
 
 import (
+	"errors"
 	"net"
+	"strconv"
+	"sync"
 	"time"
 )
 
@@ -28,20 +31,134 @@ import (
 type Server struct {
 	addr    string
 	port    string
+	altAddr string
+	altPort string
+	network string
 	timeout time.Duration
 	logger  *Logger
+	handler Handler
+
+	responseOrigin    bool
+	otherAddr         *net.UDPAddr
+	capabilities      *Capabilities
+	features          FeatureFlags
+	dropStatsInterval time.Duration
+	trafficStats      bool
+	trustedProxies    []*net.IPNet
+	software          string
+	fixAttrOrder      bool
+	classic           bool
+	maxMessageSize    int
+	slo               *sloMonitor
+
+	// readBuffers holds the buffers HandleUDPConn reads datagrams into,
+	// which are copied out so that requests do not pin them.
+	readBuffers sync.Pool
+
+	// conns holds the listening sockets indexed by [alternate IP][alternate port],
+	// conns[0][0] being the primary address.
+	connsMu sync.RWMutex
+	conns   [2][2]*net.UDPConn
 }
 
 // ServerConfig holds configuration options for creating a STUN server.
 type ServerConfig struct {
 	// Addr is the IP address to bind to (e.g., "127.0.0.1", "0.0.0.0")
 	Addr string
-	// Port is the port number to listen on (e.g., "3478")
+	// Port is the port number to listen on. Empty selects DefaultPort; "0"
+	// picks an ephemeral port.
 	Port string
+	// AltAddr is the alternate IP address of the server, used to honor the
+	// change IP flag of CHANGE-REQUEST (RFC 5780). Empty disables it.
+	AltAddr string
+	// AltPort is the alternate port of the server, used to honor the change
+	// port flag of CHANGE-REQUEST (RFC 5780). Empty disables it.
+	AltPort string
+	// Network is the network the server listens on: "udp4", "udp6" or, when
+	// empty, "udp", which serves both IPv4 and IPv6 clients on a wildcard or
+	// empty Addr and follows the family of a specific one. Responses carry
+	// the address of each client in its own family.
+	Network string
 	// Timeout is the connection timeout duration
 	Timeout time.Duration
 	// Logger is the logger instance to use for logging
 	Logger *Logger
+	// Middleware wraps the request handler, the first entry being the outermost
+	// (e.g. SigningPolicy)
+	Middleware []Middleware
+	// ResponseOrigin adds a RESPONSE-ORIGIN attribute to every Binding response.
+	// It requires Addr to be a specific IP, since a wildcard address cannot be advertised.
+	ResponseOrigin bool
+	// OtherAddress is the alternate transport address of the server, differing
+	// in both IP and port, advertised in Binding responses as OTHER-ADDRESS
+	// for NAT behavior discovery. When nil and both AltAddr and AltPort are
+	// set, AltAddr:AltPort is advertised.
+	OtherAddress *net.UDPAddr
+	// Capabilities, when set, is advertised in every Binding response as the
+	// vendor-specific CAPABILITIES attribute. Nil (the default) sends nothing.
+	Capabilities *Capabilities
+	// Features enables named experimental behaviors on this server
+	// (e.g. FeatureCapabilities). Handlers and middleware read them through
+	// Request.Feature.
+	Features FeatureFlags
+	// DropStatsInterval is how often the kernel drop counters of the listening
+	// socket are sampled and published as metrics (see ReadDropStats).
+	// Zero disables sampling.
+	DropStatsInterval time.Duration
+	// TrafficStats publishes a histogram of received message sizes and counts
+	// of the attribute types seen, as the MetricMessageSizes and
+	// MetricAttributeTypes metrics, to guide buffer sizing and capacity
+	// planning. Only aggregate counts are kept, no address, credential or
+	// attribute value, but the attribute mix can still hint at the clients
+	// in use. Off by default.
+	TrafficStats bool
+	// TransportRules is the transport policy applied to requests before the
+	// handler, inside any Middleware (see TransportPolicy). Nil selects
+	// DefaultTransportRules.
+	TransportRules []TransportRule
+	// TrustedProxies lists the networks of proxies relaying STUN to the
+	// server, e.g. a TLS terminating proxy for stuns: clients. Datagrams from
+	// them must start with a PROXY protocol version 2 header (see
+	// AppendProxyHeader): the client address it carries is the one reflected
+	// in responses, which are sent back to the proxy.
+	TrustedProxies []*net.IPNet
+	// Software is the SOFTWARE attribute added to Binding responses. Empty
+	// selects DefaultSoftware, which names the release of this package.
+	Software string
+	// OmitSoftware sends no SOFTWARE attribute, for deployments that do not
+	// want to disclose their version.
+	OmitSoftware bool
+	// FixAttrOrder makes the server move misplaced MESSAGE-INTEGRITY,
+	// MESSAGE-INTEGRITY-SHA256 and FINGERPRINT attributes to the end of the
+	// responses of handlers (see FixAttrOrder). Otherwise such responses are
+	// not sent and the error is logged.
+	FixAttrOrder bool
+	// KnownAttributes lists the comprehension-required attributes handled by
+	// the application, in addition to those of the package and the codecs
+	// registered with RegisterAttr. Requests carrying other ones are answered
+	// with a 420 (Unknown Attribute) error (see UnknownAttributesPolicy).
+	KnownAttributes []StunAttribute
+	// SLO, when set, makes the server track the latency from reading each
+	// request to writing its response, and report the windows breaching the
+	// objective (see SLOConfig), for early warning of overload before
+	// clients time out. Windows are evaluated while Listen runs.
+	SLO *SLOConfig
+	// ClassicSTUN makes the server also answer the Binding requests of
+	// RFC 3489 clients, which carry no magic cookie: their responses echo the
+	// 128-bit transaction ID and report the MAPPED-ADDRESS, SOURCE-ADDRESS
+	// and CHANGED-ADDRESS those clients understand instead of the
+	// XOR-MAPPED-ADDRESS, RESPONSE-ORIGIN and OTHER-ADDRESS. Such requests
+	// are dropped otherwise.
+	ClassicSTUN bool
+	// StrictValidation makes the server check requests with Message.Validate
+	// before any middleware, answering invalid ones with a 400 (Bad Request)
+	// error (see ValidationPolicy).
+	StrictValidation bool
+	// MaxMessageSize is the size of the largest datagram the server reads,
+	// PROXY protocol header included. Larger ones are dropped with an
+	// error wrapping ErrMessageTruncated. Zero selects the MaxMessageSize of
+	// the decode limits (see SetDecodeLimits).
+	MaxMessageSize int
 }
 
 // NewServer creates a new STUN server with the specified configuration.
@@ -61,12 +178,70 @@ func NewServer(cfg ServerConfig) *Server {
 		logger = NewDefaultLogger()
 	}
 
-	return &Server{
+	network := cfg.Network
+	if network == "" {
+		network = "udp"
+	}
+	port := cfg.Port
+	if port == "" {
+		port = strconv.Itoa(DefaultPort)
+	}
+
+	otherAddr := cfg.OtherAddress
+	if otherAddr == nil && cfg.AltAddr != "" && cfg.AltPort != "" {
+		if port, err := strconv.Atoi(cfg.AltPort); err == nil {
+			otherAddr = &net.UDPAddr{IP: net.ParseIP(cfg.AltAddr), Port: port}
+		}
+	}
+
+	s := &Server{
 		addr:    cfg.Addr,
-		port:    cfg.Port,
+		port:    port,
+		altAddr: cfg.AltAddr,
+		altPort: cfg.AltPort,
+		network: network,
 		timeout: cfg.Timeout,
 		logger:  logger,
+
+		responseOrigin:    cfg.ResponseOrigin,
+		otherAddr:         otherAddr,
+		capabilities:      cfg.Capabilities,
+		features:          cfg.Features.clone(),
+		dropStatsInterval: cfg.DropStatsInterval,
+		trafficStats:      cfg.TrafficStats,
+		trustedProxies:    cfg.TrustedProxies,
+		fixAttrOrder:      cfg.FixAttrOrder,
+		classic:           cfg.ClassicSTUN,
+		maxMessageSize:    cfg.MaxMessageSize,
+	}
+	if cfg.SLO != nil {
+		s.slo = newSLOMonitor(*cfg.SLO, logger)
+	}
+	switch {
+	case cfg.OmitSoftware:
+	case cfg.Software == "":
+		s.software = DefaultSoftware()
+	case SoftwareAttribute(cfg.Software).AddTo(&Message{}) != nil:
+		logger.Warn("SOFTWARE description too long, not sending it", map[string]interface{}{
+			"software": cfg.Software,
+		})
+	default:
+		s.software = cfg.Software
 	}
+	rules := cfg.TransportRules
+	if rules == nil {
+		rules = DefaultTransportRules
+	}
+	var middleware []Middleware
+	if cfg.StrictValidation {
+		middleware = append(middleware, ValidationPolicy())
+	}
+	middleware = append(append(middleware, cfg.Middleware...),
+		UnknownAttributesPolicy(cfg.KnownAttributes...),
+		TransportPolicy(rules...),
+	)
+	s.handler = chain(s.handleBinding, middleware...)
+	return s
 }
 
 // Listen starts the STUN server and begins listening for incoming connections.
@@ -93,7 +268,7 @@ func NewServer(cfg ServerConfig) *Server {
 //	}
 func (s *Server) Listen() error {
 	addr := net.JoinHostPort(s.addr, s.port)
-	udpAddr, err := net.ResolveUDPAddr("udp", addr)
+	udpAddr, err := net.ResolveUDPAddr(s.network, addr)
 
 	if err != nil {
 		s.logger.LogError("Failed to resolve UDP address", err, map[string]interface{}{
@@ -102,12 +277,15 @@ func (s *Server) Listen() error {
 		return err
 	}
 
+	build := ReadBuildInfo()
 	s.logger.Info("STUN server starting", map[string]interface{}{
-		"address": addr,
-		"timeout": s.timeout.String(),
+		"address":    addr,
+		"timeout":    s.timeout.String(),
+		"version":    build.String(),
+		"go_version": build.GoVersion,
 	})
 
-	conn, err := net.ListenUDP("udp4", udpAddr)
+	conn, err := net.ListenUDP(s.network, udpAddr)
 	if err != nil {
 		s.logger.LogError("Failed to listen on UDP address", err, map[string]interface{}{
 			"address": addr,
@@ -116,14 +294,72 @@ func (s *Server) Listen() error {
 	}
 
 	defer conn.Close()
+	s.setConn(0, 0, conn)
 
 	s.logger.LogConnection(conn.LocalAddr().String(), "", "stun_server")
 
+	if err := s.listenAlternates(); err != nil {
+		return err
+	}
+
+	if s.dropStatsInterval > 0 {
+		done := make(chan struct{})
+		defer close(done)
+		go s.watchDrops(conn, s.dropStatsInterval, done)
+	}
+	if s.slo != nil {
+		done := make(chan struct{})
+		defer close(done)
+		go s.slo.run(done)
+	}
+
 	for {
-		s.HandleUDPConn(conn)
+		if err := s.HandleUDPConn(conn); errors.Is(err, net.ErrClosed) {
+			return nil
+		}
 	}
 }
 
+// listenAlternates opens the sockets on the alternate IP and/or port used to
+// answer CHANGE-REQUEST, and serves requests received on them as well.
+func (s *Server) listenAlternates() error {
+	for i, host := range []string{s.addr, s.altAddr} {
+		for j, port := range []string{s.port, s.altPort} {
+			if (i == 0 && j == 0) || host == "" || port == "" {
+				continue
+			}
+
+			addr := net.JoinHostPort(host, port)
+			udpAddr, err := net.ResolveUDPAddr(s.network, addr)
+			if err != nil {
+				s.logger.LogError("Failed to resolve alternate UDP address", err, map[string]interface{}{
+					"address": addr,
+				})
+				return err
+			}
+			conn, err := net.ListenUDP(s.network, udpAddr)
+			if err != nil {
+				s.logger.LogError("Failed to listen on alternate UDP address", err, map[string]interface{}{
+					"address": addr,
+				})
+				return err
+			}
+			s.setConn(i, j, conn)
+
+			s.logger.LogConnection(conn.LocalAddr().String(), "", "stun_server")
+
+			go func() {
+				for {
+					if err := s.HandleUDPConn(conn); errors.Is(err, net.ErrClosed) {
+						return
+					}
+				}
+			}()
+		}
+	}
+	return nil
+}
+
 // HandleUDPConn processes a single UDP connection and handles STUN requests.
 // This method is called for each incoming UDP packet and performs:
 //   - Reading the UDP packet
@@ -134,14 +370,30 @@ func (s *Server) Listen() error {
 //
 // The method includes comprehensive error handling and logging for debugging
 // and monitoring purposes.
-func (s *Server) HandleUDPConn(con *net.UDPConn) {
-	buff := make([]byte, 1024)
-	n, remoteAddr, err := con.ReadFromUDP(buff)
+//
+// Returns the error of reading from con, which wraps net.ErrClosed once con
+// is closed so that serving loops can stop. Errors processing the packet
+// are logged, not returned.
+func (s *Server) HandleUDPConn(con *net.UDPConn) error {
+	maxSize := readSize(s.maxMessageSize)
+	buff := s.readBuffer(maxSize + 1)
+	n, remoteAddr, err := con.ReadFromUDP(*buff)
+	data := append([]byte(nil), (*buff)[:n]...)
+	s.readBuffers.Put(buff)
 	if err != nil {
-		s.logger.LogError("Failed to read from UDP connection", err, map[string]interface{}{
+		if !errors.Is(err, net.ErrClosed) {
+			s.logger.LogError("Failed to read from UDP connection", err, map[string]interface{}{
+				"remote_addr": remoteAddr.String(),
+			})
+		}
+		return err
+	}
+	received := time.Now()
+	if err := checkRead(n, maxSize); err != nil {
+		s.logger.LogError("Dropping oversized datagram", err, map[string]interface{}{
 			"remote_addr": remoteAddr.String(),
 		})
-		return
+		return nil
 	}
 
 	s.logger.Debug("Received UDP packet", map[string]interface{}{
@@ -150,84 +402,348 @@ func (s *Server) HandleUDPConn(con *net.UDPConn) {
 		"local_addr":  con.LocalAddr().String(),
 	})
 
-	packet, err := NewPacket(con, buff[:n], remoteAddr)
+	// Responses go back to the sender, which is not the client when the
+	// datagram is relayed by a trusted proxy
+	replyAddr := remoteAddr
+	if s.trustedProxy(remoteAddr) {
+		src, size, err := parseProxyHeader(data)
+		if err != nil {
+			s.logger.LogError("Failed to parse PROXY protocol header", err, map[string]interface{}{
+				"remote_addr": remoteAddr.String(),
+			})
+			return nil
+		}
+		if src != nil {
+			remoteAddr = src
+		}
+		data = data[size:]
+	}
+
+	packet, err := newPacket(con, data, remoteAddr, s.classic)
+	if err == ErrNotSTUN || errors.Is(err, ErrInvalidCookie) {
+		// Other protocols, or RFC 3489 clients while ClassicSTUN is off
+		s.logger.Debug("Ignoring non-STUN packet", map[string]interface{}{
+			"remote_addr": remoteAddr.String(),
+			"bytes_read":  n,
+			"error":       err.Error(),
+		})
+		return nil
+	}
 	if err != nil {
 		s.logger.LogError("Failed to create packet from UDP data", err, map[string]interface{}{
 			"remote_addr": remoteAddr.String(),
 			"bytes_read":  n,
 		})
-		return
+		return nil
+	}
+
+	if s.trafficStats {
+		recordTraffic(n, packet.message)
 	}
 
 	// Log the incoming request
 	s.logger.LogRequest(remoteAddr.String(), packet.message.Header.Type, packet.message.Header.TransactionID)
 
 	trID := packet.message.Header.TransactionID
+	tlog := loggerWithTransaction(s.logger, trID, remoteAddr.String())
+
+	req := &Request{
+		Message:    packet.message,
+		RemoteAddr: remoteAddr,
+		LocalAddr:  con.LocalAddr(),
+		conn:       con,
+		features:   s.features,
+	}
+	msg, err := s.handler(req)
+	if err != nil {
+		tlog.LogError("Failed to handle request", err)
+		return nil
+	}
+	if msg == nil {
+		return nil
+	}
+	if s.slo != nil {
+		defer func() { s.slo.record(time.Since(received)) }()
+	}
+	if s.fixAttrOrder {
+		FixAttrOrder(msg)
+	}
+	if err := msg.CheckAttrOrder(); err != nil {
+		tlog.LogError("Invalid response from handler", err)
+		return nil
+	}
+	content := msg.Encode()
+
+	// Log the response being sent
+	xorMappedAddr, _ := msg.GetXorAddr()
+	s.logger.LogResponse(remoteAddr.String(), msg.Header.Type, trID, xorMappedAddr)
 
-	xorAddr, err := serializeAddr(XorMappedAddr{
-		Family: IPV4,
-		IP:     packet.remoteIP,
-		Port:   packet.remotePort,
-	}, trID)
+	// CHANGE-REQUEST may have selected another socket to respond from
+	packet.con = req.conn
+	n, err = packet.Write(content, replyAddr)
 	if err != nil {
-		s.logger.LogError("Failed to serialize XOR mapped address", err, map[string]interface{}{
-			"remote_addr":    remoteAddr.String(),
-			"transaction_id": trID,
+		tlog.LogError("Failed to write response", err, map[string]interface{}{
+			"bytes_written": n,
 		})
-		return
+		return nil
 	}
 
-	xorAttr := Attribute{
-		Length:       XORMappedAddressLength,
-		Type:         XORMappedAddress,
-		PaddedLength: XORMappedAddressLength,
-		Value:        xorAddr,
+	tlog.Debug("Response sent successfully", map[string]interface{}{
+		"bytes_written": n,
+	})
+	return nil
+}
+
+// readBuffer returns a pooled buffer of size bytes.
+func (s *Server) readBuffer(size int) *[]byte {
+	if buff, ok := s.readBuffers.Get().(*[]byte); ok && cap(*buff) >= size {
+		*buff = (*buff)[:size]
+		return buff
 	}
+	buff := make([]byte, size)
+	return &buff
+}
 
-	msg := Message{
-		Header: Header{
-			Type:          BindingResponse,
-			Length:        XORMappedAddressLength + 4,
-			TransactionID: trID,
-			MagicCookie:   magicCookie,
-		},
-		Attributes: []Attribute{xorAttr},
+// handleBinding is the default handler: it answers a Binding request with a
+// Binding success response carrying the XOR-MAPPED-ADDRESS of the client.
+// Binding indications, used as keepalives, are accepted without reply.
+func (s *Server) handleBinding(req *Request) (*Message, error) {
+	trID := req.Message.Header.TransactionID
+	if req.Message.Header.Type.Class() == ClassIndication {
+		loggerWithTransaction(s.logger, trID, req.RemoteAddr.String()).
+			Debug("Indication received, not responding")
+		return nil, nil
 	}
-	content := msg.Encode()
 
-	// Create XOR mapped address for logging
-	xorMappedAddr := &XorMappedAddr{
-		Family: IPV4,
-		IP:     packet.remoteIP,
-		Port:   packet.remotePort,
+	var change ChangeRequestAttribute
+	if err := change.GetFrom(req.Message); err == nil && (change.ChangeIP || change.ChangePort) {
+		conn := s.changedConn(req.conn, change)
+		if conn == nil {
+			// RFC 5780 §6.1: a server unable to honor CHANGE-REQUEST treats it
+			// as an unknown comprehension-required attribute
+			resp, err := NewErrorResponse(req.Message, CodeUnknownAttribute)
+			if err != nil {
+				return nil, err
+			}
+			if err := (UnknownAttributes{ChangeRequest}).AddTo(resp); err != nil {
+				return nil, err
+			}
+			return resp, nil
+		}
+		req.conn = conn
 	}
 
-	// Log the response being sent
-	s.logger.LogResponse(remoteAddr.String(), msg.Header.Type, trID, xorMappedAddr)
+	msg := NewBindingSuccess(trID)
+	msg.Header.MagicCookie = responseCookie(req.Message)
+	if req.Message.Header.IsClassic() {
+		if err := s.addClassicAddrs(msg, req); err != nil {
+			return nil, err
+		}
+	} else if err := s.addAddrs(msg, req); err != nil {
+		return nil, err
+	}
+	if caps := s.advertisedCapabilities(req); caps != nil {
+		if err := caps.AddTo(msg); err != nil {
+			return nil, err
+		}
+	}
+	if s.software != "" {
+		if err := SoftwareAttribute(s.software).AddTo(msg); err != nil {
+			return nil, err
+		}
+	}
+
+	return msg, nil
+}
 
-	n, err = packet.Write(content, remoteAddr)
+// addAddrs adds the XOR-MAPPED-ADDRESS of the client to the response msg,
+// along with the RESPONSE-ORIGIN and OTHER-ADDRESS when configured.
+func (s *Server) addAddrs(msg *Message, req *Request) error {
+	if err := FromUDPAddr(req.RemoteAddr).AddTo(msg); err != nil {
+		return err
+	}
+	if s.responseOrigin {
+		if err := s.addResponseOrigin(msg, req.conn.LocalAddr()); err != nil {
+			return err
+		}
+	}
+	if s.otherAddr != nil {
+		other := OtherAddr{IP: s.otherAddr.IP, Port: uint16(s.otherAddr.Port)}
+		if err := other.AddTo(msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addClassicAddrs adds the attributes of the responses to RFC 3489 clients
+// (RFC 3489 §8.2) to msg: the MAPPED-ADDRESS of the client, the
+// SOURCE-ADDRESS of the responding socket unless it listens on a wildcard
+// address, and the CHANGED-ADDRESS when the server has an alternate address.
+func (s *Server) addClassicAddrs(msg *Message, req *Request) error {
+	mapped := MappedAddr{IP: req.RemoteAddr.IP, Port: uint16(req.RemoteAddr.Port)}
+	if err := mapped.AddTo(msg); err != nil {
+		return err
+	}
+	port, ip, err := GetPortAndIPFromAddr(req.conn.LocalAddr())
 	if err != nil {
-		s.logger.LogError("Failed to write response", err, map[string]interface{}{
-			"remote_addr":    remoteAddr.String(),
-			"transaction_id": trID,
-			"bytes_written":  n,
-		})
-		return
+		return err
 	}
+	if ip != nil && !ip.IsUnspecified() {
+		if err := (SourceAddr{IP: ip, Port: uint16(port)}).AddTo(msg); err != nil {
+			return err
+		}
+	}
+	if s.otherAddr != nil {
+		changed := ChangedAddr{IP: s.otherAddr.IP, Port: uint16(s.otherAddr.Port)}
+		if err := changed.AddTo(msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setConn records the listening socket for the [alternate IP][alternate port] slot.
+func (s *Server) setConn(ip, port int, conn *net.UDPConn) {
+	s.connsMu.Lock()
+	s.conns[ip][port] = conn
+	s.connsMu.Unlock()
+}
+
+// conn returns the listening socket of the [alternate IP][alternate port] slot.
+func (s *Server) conn(ip, port int) *net.UDPConn {
+	s.connsMu.RLock()
+	defer s.connsMu.RUnlock()
+	return s.conns[ip][port]
+}
 
-	s.logger.Debug("Response sent successfully", map[string]interface{}{
-		"remote_addr":   remoteAddr.String(),
+// WriteTo sends m to addr from the primary listener of the server, for
+// server-initiated messages such as Binding indications to known peers. The
+// message is sent as a single datagram, as STUN over UDP requires. A zero
+// magic cookie is filled in.
+//
+// Returns ErrServerNotListening if Listen has not opened the listener yet.
+//
+// Example:
+//
+//	ind := &stun.Message{Header: stun.Header{
+//		Type:          stun.BindingIndication,
+//		TransactionID: txID,
+//	}}
+//	if err := server.WriteTo(peerAddr, ind); err != nil {
+//		log.Println(err)
+//	}
+func (s *Server) WriteTo(addr net.Addr, m *Message) error {
+	conn := s.conn(0, 0)
+	if conn == nil {
+		return ErrServerNotListening
+	}
+	if m.Header.MagicCookie == 0 {
+		m.Header.MagicCookie = magicCookie
+	}
+
+	content := m.Encode()
+	n, err := conn.WriteTo(content, addr)
+	if err != nil {
+		loggerWithTransaction(s.logger, m.Header.TransactionID, addr.String()).
+			LogError("Failed to write unsolicited message", err)
+		return err
+	}
+	if n < len(content) {
+		return ErrShortWrite
+	}
+
+	s.logger.Debug("Unsolicited message sent", map[string]interface{}{
+		"remote_addr":   addr.String(),
+		"message_type":  m.Header.Type.String(),
 		"bytes_written": n,
 	})
+	return nil
+}
+
+// advertisedCapabilities returns the CAPABILITIES to include in responses:
+// the configured ones, or ones derived from the listeners when only the
+// FeatureCapabilities flag is enabled. Nil means none are advertised.
+func (s *Server) advertisedCapabilities(req *Request) *Capabilities {
+	if s.capabilities != nil {
+		return s.capabilities
+	}
+	if !req.Feature(FeatureCapabilities) {
+		return nil
+	}
+	return &Capabilities{
+		NATBehaviorDiscovery: s.conn(1, 1) != nil,
+	}
+}
+
+// FeatureEnabled reports whether the named feature flag is enabled on the server.
+func (s *Server) FeatureEnabled(name string) bool {
+	return s.features.Enabled(name)
+}
+
+// changedConn returns the socket to respond from when a request received on
+// recv carries the given CHANGE-REQUEST, or nil if the server has no such
+// alternate address.
+func (s *Server) changedConn(recv *net.UDPConn, change ChangeRequestAttribute) *net.UDPConn {
+	s.connsMu.RLock()
+	defer s.connsMu.RUnlock()
+
+	for i := range s.conns {
+		for j := range s.conns[i] {
+			if s.conns[i][j] != recv {
+				continue
+			}
+			if change.ChangeIP {
+				i ^= 1
+			}
+			if change.ChangePort {
+				j ^= 1
+			}
+			return s.conns[i][j]
+		}
+	}
+	return nil
+}
+
+// addResponseOrigin adds the local address the response is sent from as a
+// RESPONSE-ORIGIN attribute. Wildcard listen addresses are skipped since they
+// do not identify the actual source address.
+func (s *Server) addResponseOrigin(msg *Message, local net.Addr) error {
+	port, ip, err := GetPortAndIPFromAddr(local)
+	if err != nil {
+		return err
+	}
+	if ip == nil || ip.IsUnspecified() {
+		s.logger.Debug("Skipping RESPONSE-ORIGIN for wildcard listen address", map[string]interface{}{
+			"local_addr": local.String(),
+		})
+		return nil
+	}
+	return ResponseOriginAddr{IP: ip, Port: uint16(port)}.AddTo(msg)
 }
 
 // Shutdown gracefully shuts down the STUN server.
-// This method logs the shutdown event and can be extended to perform
-// cleanup operations if needed.
+// This method logs the shutdown event and closes the sockets opened by
+// Listen, which then returns nil once the monitoring goroutines it started
+// have been told to stop.
 //
 // Returns:
 //   - error: Any error that occurred during shutdown
 func (s *Server) Shutdown() error {
 	s.logger.LogShutdown("stun_server", 0)
-	return nil
+
+	s.connsMu.RLock()
+	defer s.connsMu.RUnlock()
+	var firstErr error
+	for _, row := range s.conns {
+		for _, conn := range row {
+			if conn == nil {
+				continue
+			}
+			if err := conn.Close(); err != nil && !errors.Is(err, net.ErrClosed) && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
 }
@@ -0,0 +1,60 @@
+package stun
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// goroutinesRunning reports whether a goroutine is running any of funcs,
+// given as they appear in stack traces.
+func goroutinesRunning(funcs ...string) bool {
+	buf := make([]byte, 1<<20)
+	stacks := string(buf[:runtime.Stack(buf, true)])
+	for _, f := range funcs {
+		if strings.Contains(stacks, f) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestShutdownStopsListen(t *testing.T) {
+	s := NewServer(ServerConfig{
+		Addr:              "127.0.0.1",
+		Port:              "0",
+		Logger:            quietLogger(),
+		DropStatsInterval: time.Hour,
+		SLO:               &SLOConfig{Threshold: time.Second, Window: time.Hour},
+	})
+	done := make(chan error, 1)
+	go func() { done <- s.Listen() }()
+
+	monitors := []string{"stun.(*Server).watchDrops", "stun.(*sloMonitor).run"}
+	deadline := time.Now().Add(2 * time.Second)
+	for s.conn(0, 0) == nil || !goroutinesRunning(monitors[0]) || !goroutinesRunning(monitors[1]) {
+		if time.Now().After(deadline) {
+			t.Fatal("server did not start listening and monitoring")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if err := s.Shutdown(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Listen returned %v after Shutdown, want nil", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Listen did not return once its socket was closed")
+	}
+	for goroutinesRunning(monitors...) {
+		if time.Now().After(deadline) {
+			t.Fatal("monitoring goroutines still running after Listen returned")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,60 @@
+package stun
+
+import "net"
+
+// SigningRule selects the MESSAGE-INTEGRITY key used for responses sent to
+// clients within Network. A nil Key leaves those responses unsigned.
+type SigningRule struct {
+	Network *net.IPNet
+	Key     Integrity
+}
+
+// NewSigningRule parses cidr (e.g. "10.0.0.0/8") and returns a rule signing
+// responses to that network with key.
+func NewSigningRule(cidr string, key Integrity) (SigningRule, error) {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return SigningRule{}, err
+	}
+	return SigningRule{Network: network, Key: key}, nil
+}
+
+// SigningPolicy returns middleware that adds MESSAGE-INTEGRITY to responses
+// depending on the source network of the request, so that signing cost is only
+// paid where it is needed (e.g. untrusted internet ranges but not internal ones).
+//
+// Rules are evaluated in order and the first one whose network contains the
+// remote address applies. Responses to clients matching no rule are left unsigned.
+//
+// Example:
+//
+//	internal, _ := stun.NewSigningRule("10.0.0.0/8", nil)
+//	internet, _ := stun.NewSigningRule("0.0.0.0/0", stun.NewShortTermIntegrity("secret"))
+//	server := stun.NewServer(stun.ServerConfig{
+//		Addr:       "0.0.0.0",
+//		Port:       "3478",
+//		Middleware: []stun.Middleware{stun.SigningPolicy(internal, internet)},
+//	})
+func SigningPolicy(rules ...SigningRule) Middleware {
+	return func(next Handler) Handler {
+		return func(req *Request) (*Message, error) {
+			resp, err := next(req)
+			if err != nil || resp == nil {
+				return resp, err
+			}
+			for _, rule := range rules {
+				if rule.Network == nil || !rule.Network.Contains(req.RemoteAddr.IP) {
+					continue
+				}
+				if rule.Key == nil {
+					break
+				}
+				if err := rule.Key.AddTo(resp); err != nil {
+					return nil, err
+				}
+				break
+			}
+			return resp, nil
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package stun
+
+import (
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"time"
+)
+
+// DefaultSLOWindow is the evaluation window of an SLOConfig leaving Window
+// unset.
+const DefaultSLOWindow = 10 * time.Second
+
+// maxSLOSamples bounds the latencies kept per window. Past it, a uniform
+// sample of the window is kept (reservoir sampling), so that memory does not
+// grow with the load the monitor is meant to warn about.
+const maxSLOSamples = 4096
+
+// SLOConfig configures the latency objective monitored by a server (see
+// ServerConfig.SLO): the time from reading a request to writing its response
+// must stay under Threshold for the Percentile of the requests of every
+// Window.
+type SLOConfig struct {
+	// Threshold is the latency objective.
+	Threshold time.Duration
+	// Percentile is the fraction of requests that must meet Threshold,
+	// between 0 and 1. Zero selects 0.99.
+	Percentile float64
+	// Window is the period over which percentiles are computed. Zero selects
+	// DefaultSLOWindow.
+	Window time.Duration
+	// OnBreach is called with the report of every window breaching the
+	// objective, from the goroutine of the monitor. Nil logs a warning.
+	OnBreach func(r SLOReport)
+}
+
+// SLOReport holds the latency percentiles of a window.
+type SLOReport struct {
+	// Start is the beginning of the window.
+	Start time.Time
+	// Window is the length of the window.
+	Window time.Duration
+	// Requests is the number of requests answered in the window.
+	Requests int
+	// P50, P90 and P99 are latency percentiles, Max the slowest request.
+	P50, P90, P99, Max time.Duration
+	// Observed is the latency at the percentile of the objective, above its
+	// Threshold when the objective is breached.
+	Observed time.Duration
+}
+
+// sloMonitor collects the request latencies of a server and evaluates them
+// against the objective at the end of every window.
+type sloMonitor struct {
+	cfg    SLOConfig
+	logger *Logger
+
+	mu      sync.Mutex
+	start   time.Time
+	seen    int
+	samples []time.Duration
+}
+
+// newSLOMonitor returns a monitor of cfg, defaults applied.
+func newSLOMonitor(cfg SLOConfig, logger *Logger) *sloMonitor {
+	if cfg.Percentile <= 0 || cfg.Percentile > 1 {
+		cfg.Percentile = 0.99
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = DefaultSLOWindow
+	}
+	return &sloMonitor{cfg: cfg, logger: logger, start: time.Now()}
+}
+
+// record adds the latency d of a request to the current window.
+func (m *sloMonitor) record(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.seen++
+	if len(m.samples) < maxSLOSamples {
+		m.samples = append(m.samples, d)
+	} else if i := rand.IntN(m.seen); i < maxSLOSamples {
+		m.samples[i] = d
+	}
+}
+
+// run evaluates every window until done is closed, the first one starting
+// now.
+func (m *sloMonitor) run(done <-chan struct{}) {
+	m.mu.Lock()
+	m.start = time.Now()
+	m.mu.Unlock()
+
+	ticker := time.NewTicker(m.cfg.Window)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case now := <-ticker.C:
+			m.evaluate(now)
+		}
+	}
+}
+
+// evaluate closes the current window at now and reports it when it breaches
+// the objective. Windows without requests are not evaluated.
+func (m *sloMonitor) evaluate(now time.Time) {
+	m.mu.Lock()
+	samples, seen, start := m.samples, m.seen, m.start
+	m.samples, m.seen, m.start = nil, 0, now
+	m.mu.Unlock()
+	if len(samples) == 0 {
+		return
+	}
+
+	slices.Sort(samples)
+	percentile := func(p float64) time.Duration {
+		return samples[int(p*float64(len(samples)-1))]
+	}
+	r := SLOReport{
+		Start:    start,
+		Window:   now.Sub(start),
+		Requests: seen,
+		P50:      percentile(0.5),
+		P90:      percentile(0.9),
+		P99:      percentile(0.99),
+		Max:      samples[len(samples)-1],
+		Observed: percentile(m.cfg.Percentile),
+	}
+	if r.Observed <= m.cfg.Threshold {
+		return
+	}
+	if m.cfg.OnBreach != nil {
+		m.cfg.OnBreach(r)
+		return
+	}
+	m.logger.Warn("Latency objective breached", map[string]interface{}{
+		"requests":   r.Requests,
+		"window":     r.Window.String(),
+		"percentile": m.cfg.Percentile,
+		"observed":   r.Observed.String(),
+		"threshold":  m.cfg.Threshold.String(),
+		"p50":        r.P50.String(),
+		"p99":        r.P99.String(),
+		"component":  "stun_server",
+	})
+}
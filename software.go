@@ -0,0 +1,123 @@
+package stun
+
+import (
+	"runtime/debug"
+	"sync"
+)
+
+// MaxSoftwareLength is the maximum size in bytes of a SOFTWARE value
+// (RFC 5389 §15.10): fewer than 128 characters, up to 763 bytes once UTF-8
+// encoded.
+const MaxSoftwareLength = 763
+
+// modulePath is the import path of this package, looked up in the build
+// information of the running binary.
+const modulePath = "github.com/lai0xn/stun"
+
+// BuildInfo describes the release of this package linked into the running
+// binary, as recorded by the Go toolchain.
+type BuildInfo struct {
+	// Version is the module version (e.g. "v1.2.0"), "(devel)" when the
+	// binary is built from a checkout of this repository.
+	Version string
+	// Commit is the VCS revision of the checkout, when the binary is built
+	// from this repository. It is empty when the package is a dependency.
+	Commit string
+	// Modified reports uncommitted changes in the checkout.
+	Modified bool
+	// GoVersion is the Go toolchain the binary was built with.
+	GoVersion string
+}
+
+var (
+	buildInfoOnce sync.Once
+	buildInfo     BuildInfo
+)
+
+// ReadBuildInfo returns the build information of this package in the
+// running binary. Fields the toolchain did not record are left empty.
+func ReadBuildInfo() BuildInfo {
+	buildInfoOnce.Do(func() {
+		info, ok := debug.ReadBuildInfo()
+		if !ok {
+			return
+		}
+		buildInfo.GoVersion = info.GoVersion
+		if info.Main.Path == modulePath {
+			buildInfo.Version = info.Main.Version
+			for _, s := range info.Settings {
+				switch s.Key {
+				case "vcs.revision":
+					buildInfo.Commit = s.Value
+				case "vcs.modified":
+					buildInfo.Modified = s.Value == "true"
+				}
+			}
+			return
+		}
+		for _, dep := range info.Deps {
+			if dep.Path == modulePath {
+				if dep.Replace != nil {
+					dep = dep.Replace
+				}
+				buildInfo.Version = dep.Version
+				break
+			}
+		}
+	})
+	return buildInfo
+}
+
+// String returns the version followed by the short commit, if known, e.g.
+// "v1.2.0" or "(devel) 3f2a9c1-dirty".
+func (b BuildInfo) String() string {
+	v := b.Version
+	if v == "" {
+		v = "unknown"
+	}
+	if b.Commit != "" {
+		commit := b.Commit
+		if len(commit) > 7 {
+			commit = commit[:7]
+		}
+		if b.Modified {
+			commit += "-dirty"
+		}
+		v += " " + commit
+	}
+	return v
+}
+
+// DefaultSoftware returns the SOFTWARE value servers of this package send
+// by default, naming the package and its release, e.g.
+// "lai0xn/stun v1.2.0", so that operators and interop partners can tell
+// which version of the stack they are talking to.
+func DefaultSoftware() string {
+	return "lai0xn/stun " + ReadBuildInfo().String()
+}
+
+// SoftwareAttribute is the value of a SOFTWARE attribute (RFC 5389 §15.10):
+// a textual description of the software of the sender, for diagnostics.
+type SoftwareAttribute string
+
+// AddTo appends the description to m as a SOFTWARE attribute.
+//
+// Returns a *TextLengthError, matching ErrAttrTooLong, if it has 128
+// characters or more or exceeds MaxSoftwareLength bytes, and an error
+// wrapping ErrInvalidUTF8 if it is not valid UTF-8.
+func (s SoftwareAttribute) AddTo(m *Message) error {
+	return softwareText.Add(m, string(s))
+}
+
+// GetFrom decodes the SOFTWARE attribute of m into s.
+//
+// Returns ErrAttrNotFound if m has no SOFTWARE attribute, and the errors of
+// AddTo if the received value is invalid.
+func (s *SoftwareAttribute) GetFrom(m *Message) error {
+	v, err := softwareText.Get(m)
+	if err != nil {
+		return err
+	}
+	*s = SoftwareAttribute(v)
+	return nil
+}
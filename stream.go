@@ -0,0 +1,59 @@
+package stun
+
+import "io"
+
+// WriteTo writes the encoded message to w, implementing io.WriterTo. STUN
+// messages carry their own length, so they are written as is on stream
+// transports such as TCP and TLS, without extra framing (RFC 5389 §7.2.2).
+//
+// Example:
+//
+//	conn, err := net.Dial("tcp", "stun.example.org:3478")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	if _, err := stun.NewBindingRequest().WriteTo(conn); err != nil {
+//		log.Fatal(err)
+//	}
+//	resp, err := stun.ReadMessage(conn)
+func (m *Message) WriteTo(w io.Writer) (int64, error) {
+	n, err := w.Write(m.Encode())
+	return int64(n), err
+}
+
+// ReadMessage reads one message from a stream transport: the 20-byte
+// header, then the number of bytes its length announces. Messages follow
+// each other on the stream, so the next call reads the next one.
+//
+// It returns io.EOF if the stream ends before the first byte of the message
+// and io.ErrUnexpectedEOF if it ends within it. The header is checked before
+// the rest is read: ErrNotSTUN, a *CookieError or an error wrapping
+// ErrMessageTooLarge (see SetDecodeLimits) mean that the stream carries no
+// STUN, or is out of sync, and should be closed, while the other decoding
+// errors leave it positioned at the next message.
+func ReadMessage(r io.Reader) (*Message, error) {
+	header := make([]byte, headrLength)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	h, err := decodeHeader(header)
+	if err != nil {
+		return nil, err
+	}
+	if h.IsClassic() {
+		return nil, &CookieError{Cookie: h.MagicCookie}
+	}
+	size := headrLength + int(h.Length)
+	if err := CurrentDecodeLimits().checkSize(size); err != nil {
+		return nil, err
+	}
+	buff := make([]byte, size)
+	copy(buff, header)
+	if _, err := io.ReadFull(r, buff[headrLength:]); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return NewMessage(buff)
+}
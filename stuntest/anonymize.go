@@ -0,0 +1,205 @@
+package stuntest
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"net"
+	"strings"
+
+	"github.com/lai0xn/stun"
+)
+
+// errNotSTUN is returned by Anonymizer.Bytes for buffers that do not hold a
+// well-formed STUN message.
+var errNotSTUN = errors.New("not a STUN message")
+
+// anonymizedAddrs are the codecs of the address attributes whose IP address
+// is pseudonymized.
+var anonymizedAddrs = map[stun.StunAttribute]stun.AddressAttribute{
+	stun.MappedAddress:     {Type: stun.MappedAddress},
+	stun.AlternateServer:   {Type: stun.AlternateServer},
+	stun.ResponseOrigin:    {Type: stun.ResponseOrigin},
+	stun.OtherAddress:      {Type: stun.OtherAddress},
+	stun.XORMappedAddress:  {Type: stun.XORMappedAddress, XOR: true},
+	stun.XORPeerAddress:    {Type: stun.XORPeerAddress, XOR: true},
+	stun.XORRelayedAddress: {Type: stun.XORRelayedAddress, XOR: true},
+}
+
+// anonymizedSecrets are the attributes carrying credentials or tokens, whose
+// value is replaced by pseudorandom bytes of the same length.
+var anonymizedSecrets = map[stun.StunAttribute]bool{
+	stun.MessageIntegrity:        true,
+	stun.MessageIntegritySHA256:  true,
+	stun.UserHash:                true,
+	stun.AccessTokenAttr:         true,
+	stun.MobilityTicket:          true,
+	stun.ReservationToken:        true,
+	stun.ThirdPartyAuthorization: true,
+}
+
+// Anonymizer rewrites STUN messages so that captured traffic can be attached
+// to bug reports without disclosing the network or the credentials it was
+// captured with:
+//
+//   - IP addresses of address attributes (MAPPED-ADDRESS, XOR-MAPPED-ADDRESS,
+//     XOR-PEER-ADDRESS, ...) are mapped into 198.18.0.0/15 for IPv4 and
+//     2001:db8::/32 for IPv6; loopback and unspecified addresses are kept.
+//     Ports are kept.
+//   - USERNAME, REALM, NONCE and ALTERNATE-DOMAIN are replaced by pseudonyms.
+//     The colon separated parts of ICE usernames are replaced one by one.
+//   - MESSAGE-INTEGRITY, MESSAGE-INTEGRITY-SHA256, USERHASH, ACCESS-TOKEN and
+//     other tokens are replaced by pseudorandom bytes of the same length.
+//   - FINGERPRINT is recomputed. Other attributes are kept as is.
+//
+// Replacements are derived from the value they replace with HMAC-SHA256
+// under the key of the Anonymizer, so an address or username gets the same
+// pseudonym wherever it appears in a corpus, and the relationships a bug
+// depends on, such as a response mapping to the address of the request, are
+// preserved. The key must be kept secret: with it, addresses could be
+// recovered by trying the whole address space. Message integrity does not
+// survive anonymization.
+//
+// Example:
+//
+//	anon := stuntest.NewAnonymizer(key)
+//	shared, err := anon.Bytes(captured)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+type Anonymizer struct {
+	key []byte
+}
+
+// NewAnonymizer returns an Anonymizer deriving its pseudonyms under key.
+func NewAnonymizer(key []byte) *Anonymizer {
+	return &Anonymizer{key: append([]byte(nil), key...)}
+}
+
+// derive returns n pseudorandom bytes determined by label and data.
+func (a *Anonymizer) derive(label string, data []byte, n int) []byte {
+	out := make([]byte, 0, n+sha256.Size)
+	for counter := uint32(0); len(out) < n; counter++ {
+		mac := hmac.New(sha256.New, a.key)
+		mac.Write([]byte(label))
+		mac.Write([]byte{0})
+		mac.Write(data)
+		binary.Write(mac, binary.BigEndian, counter)
+		out = mac.Sum(out)
+	}
+	return out[:n]
+}
+
+// IP returns the pseudonym of ip, of the same family.
+func (a *Anonymizer) IP(ip net.IP) net.IP {
+	if ip.IsLoopback() || ip.IsUnspecified() {
+		return ip
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		h := a.derive("ipv4", ip4, 3)
+		return net.IPv4(198, 18|h[0]&1, h[1], h[2]).To4()
+	}
+	h := a.derive("ipv6", ip.To16(), 12)
+	return append(net.IP{0x20, 0x01, 0x0d, 0xb8}, h...)
+}
+
+// name returns the pseudonym of the text value v, made of prefix and a hash.
+func (a *Anonymizer) name(prefix, v string) string {
+	return prefix + hex.EncodeToString(a.derive(prefix, []byte(v), 4))
+}
+
+// Message returns an anonymized copy of m. The transaction ID is kept, so
+// responses still match their requests.
+func (a *Anonymizer) Message(m *stun.Message) *stun.Message {
+	out := &stun.Message{Header: m.Header}
+	out.Header.Length = 0
+	fingerprint := false
+
+	for _, attr := range m.Attributes {
+		value := attr.Value
+		if codec, ok := anonymizedAddrs[attr.Type]; ok {
+			addr, err := codec.Decode(value, m.Header.TransactionID)
+			if err == nil {
+				addr.IP = a.IP(addr.IP)
+				if err := codec.Add(out, addr); err == nil {
+					continue
+				}
+			}
+			value = a.derive("attr", value, len(value))
+		} else {
+			switch {
+			case attr.Type == stun.Username:
+				parts := strings.Split(string(value), ":")
+				for i, part := range parts {
+					parts[i] = a.name("user", part)
+				}
+				value = []byte(strings.Join(parts, ":"))
+			case attr.Type == stun.Realm:
+				value = []byte(a.name("realm", string(value)))
+			case attr.Type == stun.Nonce:
+				value = []byte(a.name("nonce", string(value)))
+			case attr.Type == stun.AlternateDomain:
+				value = []byte(a.name("domain", string(value)) + ".example")
+			case anonymizedSecrets[attr.Type]:
+				value = a.derive("attr", value, len(value))
+			case attr.Type == stun.FingerprintAttr:
+				fingerprint = true
+				continue
+			default:
+				value = append([]byte(nil), value...)
+			}
+		}
+		// Values are decoded ones or short pseudonyms, so Add cannot fail
+		_ = out.Add(attr.Type, value)
+	}
+
+	if fingerprint {
+		// The CRC covers the message up to the attribute, with the header
+		// length already accounting for it (RFC 5389 §15.5).
+		_ = out.Add(stun.FingerprintAttr, make([]byte, 4))
+		buf := out.Encode()
+		binary.BigEndian.PutUint32(out.Attributes[len(out.Attributes)-1].Value, stun.Fingerprint(buf[:len(buf)-8]))
+	}
+	return out
+}
+
+// Bytes anonymizes the encoded STUN message buf, as Message does, and
+// returns the encoded result. Bytes past the end of the message are dropped.
+//
+// Returns an error if buf does not hold a well-formed STUN message.
+func (a *Anonymizer) Bytes(buf []byte) ([]byte, error) {
+	if !wellFormed(buf) {
+		return nil, errNotSTUN
+	}
+	m, err := stun.NewMessage(buf)
+	if err != nil {
+		return nil, err
+	}
+	return a.Message(m).Encode(), nil
+}
+
+// wellFormed reports whether buf starts with a STUN header carrying the magic
+// cookie, followed by attributes that exactly fill the length it announces.
+func wellFormed(buf []byte) bool {
+	if len(buf) < 20 || buf[0]&0xC0 != 0 || binary.BigEndian.Uint32(buf[4:8]) != 0x2112A442 {
+		return false
+	}
+	length := int(binary.BigEndian.Uint16(buf[2:4]))
+	if length%4 != 0 || len(buf) < 20+length {
+		return false
+	}
+	attrs := buf[20 : 20+length]
+	for len(attrs) > 0 {
+		if len(attrs) < 4 {
+			return false
+		}
+		size := 4 + (int(binary.BigEndian.Uint16(attrs[2:4]))+3)&^3
+		if size > len(attrs) {
+			return false
+		}
+		attrs = attrs[size:]
+	}
+	return true
+}
@@ -0,0 +1,42 @@
+// Package stuntest provides utilities for testing code built on the stun
+// package without real network equipment.
+//
+// NAT simulates the mapping and filtering behaviors of RFC 4787 in process,
+// handing out net.PacketConn endpoints that sit "behind" it. Traffic leaving
+// an endpoint is sent from a loopback socket representing the NAT mapping,
+// so a STUN server on loopback observes the mapped address, and inbound
+// traffic is filtered according to the simulated behavior:
+//
+//	nat := stuntest.NewNAT(stuntest.PortRestrictedCone)
+//	defer nat.Close()
+//
+//	conn, err := nat.NewConn()
+//	if err != nil {
+//		t.Fatal(err)
+//	}
+//	// Use conn wherever a net.PacketConn is accepted
+//
+// Clock, NewRand and Network make an Agent fully deterministic for
+// table-driven tests of interleavings, such as a retransmission racing a late
+// response or both agents of a pair sending requests at once: the clock only
+// moves with Clock.Advance, transaction IDs come from a seeded source, and
+// datagrams are only delivered, reordered or dropped on the test's command.
+// No test sleeps:
+//
+//	network := stuntest.NewNetwork()
+//	conn, _ := network.NewConn("10.0.0.1:3478")
+//	clock := stuntest.NewClock(time.Unix(0, 0))
+//	agent := stun.NewAgent(stun.AgentConfig{
+//		Conn:  conn,
+//		Clock: clock,
+//		Rand:  stuntest.NewRand(1),
+//	})
+//
+// Vectors are the test vectors of RFC 5769, with the credentials they were
+// built with, for AssertVector to check an implementation against known-good
+// bytes:
+//
+//	for _, v := range stuntest.Vectors {
+//		t.Run(v.Name, func(t *testing.T) { stuntest.AssertVector(t, v) })
+//	}
+package stuntest
@@ -0,0 +1,228 @@
+package stuntest
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lai0xn/stun"
+)
+
+// DefaultTimeout bounds every transaction issued through an Env client, so a
+// response filtered by the NAT fails the test instead of hanging it.
+const DefaultTimeout = 2 * time.Second
+
+// filteringTimeout is how long AssertAddressAndPortDependentFiltering waits
+// for a datagram the NAT is expected to drop.
+const filteringTimeout = 200 * time.Millisecond
+
+// Env composes a STUN server listening on two loopback addresses and a
+// simulated NAT, so that end-to-end behaviors (mapped addresses seen through
+// the NAT, mapping and filtering classification) can be asserted in ordinary
+// Go tests.
+//
+// Example:
+//
+//	env, err := stuntest.NewEnv(stuntest.Symmetric, stun.ServerConfig{})
+//	if err != nil {
+//		t.Fatal(err)
+//	}
+//	defer env.Close()
+//
+//	client, err := env.NewClient(env.ServerAddr)
+//	if err != nil {
+//		t.Fatal(err)
+//	}
+//	env.AssertMapped(t, client)
+type Env struct {
+	// NAT is the simulated NAT between the clients and the server.
+	NAT *NAT
+	// Server is the STUN server under test.
+	Server *stun.Server
+	// ServerAddr is the primary address of the server.
+	ServerAddr *net.UDPAddr
+	// AltServerAddr is a second address served by the same server, used to
+	// observe address-dependent mapping.
+	AltServerAddr *net.UDPAddr
+
+	conns []*net.UDPConn
+	done  chan struct{}
+	wg    sync.WaitGroup
+}
+
+// NewEnv starts a server configured with cfg on two loopback sockets behind
+// a NAT simulating behavior. A nil cfg.Logger is replaced with a logger that
+// only reports fatal errors, to keep test output readable.
+func NewEnv(behavior Behavior, cfg stun.ServerConfig) (*Env, error) {
+	if cfg.Logger == nil {
+		cfg.Logger = stun.NewLogger(stun.LoggerConfig{Level: stun.FatalLevel})
+	}
+	env := &Env{
+		NAT:    NewNAT(behavior),
+		Server: stun.NewServer(cfg),
+		done:   make(chan struct{}),
+	}
+
+	for i := 0; i < 2; i++ {
+		conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+		if err != nil {
+			env.Close()
+			return nil, err
+		}
+		env.conns = append(env.conns, conn)
+		env.wg.Add(1)
+		go env.serve(conn)
+	}
+	env.ServerAddr = env.conns[0].LocalAddr().(*net.UDPAddr)
+	env.AltServerAddr = env.conns[1].LocalAddr().(*net.UDPAddr)
+	return env, nil
+}
+
+// serve runs the server on conn until the environment is closed.
+func (e *Env) serve(conn *net.UDPConn) {
+	defer e.wg.Done()
+	for {
+		select {
+		case <-e.done:
+			return
+		default:
+		}
+		if err := e.Server.HandleUDPConn(conn); errors.Is(err, net.ErrClosed) {
+			return
+		}
+	}
+}
+
+// NewClient returns a client talking to server from a new endpoint behind
+// the NAT. Each call to Dial is bounded by DefaultTimeout.
+func (e *Env) NewClient(server *net.UDPAddr) (*stun.Client, error) {
+	conn, err := e.NAT.NewConn()
+	if err != nil {
+		return nil, err
+	}
+	return e.NewClientWithConn(server, conn), nil
+}
+
+// NewClientWithConn returns a client talking to server over conn, which is
+// usually an endpoint obtained from e.NAT, so several clients can share it.
+func (e *Env) NewClientWithConn(server *net.UDPAddr, conn net.PacketConn) *stun.Client {
+	return stun.NewClientWithConn(server.String(), &timeoutConn{PacketConn: conn}, stun.NewLogger(stun.LoggerConfig{Level: stun.FatalLevel}))
+}
+
+// MappedAddr runs a Binding transaction with client and returns the
+// reflexive address reported by the server.
+func (e *Env) MappedAddr(client *stun.Client) (*stun.XorMappedAddr, error) {
+	resp, err := client.Dial(stun.NewBindingRequest())
+	if err != nil {
+		return nil, err
+	}
+	return resp.GetXorAddr()
+}
+
+// AssertMapped checks that the address the server reports for client is one
+// of the external mappings of the NAT, i.e. that the request went through
+// the NAT and the response made it back.
+func (e *Env) AssertMapped(t testing.TB, client *stun.Client) *stun.XorMappedAddr {
+	t.Helper()
+
+	addr, err := e.MappedAddr(client)
+	if err != nil {
+		t.Fatalf("binding through %s NAT failed: %v", e.NAT.Behavior(), err)
+	}
+	for _, m := range e.NAT.Mappings() {
+		if m.(*net.UDPAddr).IP.Equal(addr.IP) && m.(*net.UDPAddr).Port == int(addr.Port) {
+			return addr
+		}
+	}
+	t.Fatalf("mapped address %s:%d is not a mapping of the NAT (%v)", addr.IP, addr.Port, e.NAT.Mappings())
+	return nil
+}
+
+// AssertEndpointIndependentMapping checks whether the NAT reuses the same
+// mapping for both server addresses, which cone NATs do and symmetric NATs
+// do not (RFC 5780 §4.3).
+func (e *Env) AssertEndpointIndependentMapping(t testing.TB, want bool) {
+	t.Helper()
+
+	conn, err := e.NAT.NewConn()
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := e.AssertMapped(t, e.NewClientWithConn(e.ServerAddr, conn))
+	second := e.AssertMapped(t, e.NewClientWithConn(e.AltServerAddr, conn))
+
+	got := first.IP.Equal(second.IP) && first.Port == second.Port
+	if got != want {
+		t.Fatalf("%s NAT: endpoint-independent mapping = %v, want %v (%s:%d vs %s:%d)",
+			e.NAT.Behavior(), got, want, first.IP, first.Port, second.IP, second.Port)
+	}
+}
+
+// AssertAddressAndPortDependentFiltering checks whether the NAT drops a
+// datagram sent to the mapping of a client by the alternate server address,
+// which only differs from the address the client talked to by its port:
+// port-restricted cone and symmetric NATs drop it, full and restricted cone
+// NATs let it through (RFC 5780 §4.4).
+func (e *Env) AssertAddressAndPortDependentFiltering(t testing.TB, want bool) {
+	t.Helper()
+
+	conn, err := e.NAT.NewConn()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mapped := e.AssertMapped(t, e.NewClientWithConn(e.ServerAddr, conn))
+	probe := []byte("filtering probe")
+	if _, err := e.conns[1].WriteToUDP(probe, &net.UDPAddr{IP: mapped.IP, Port: int(mapped.Port)}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := conn.SetReadDeadline(time.Now().Add(filteringTimeout)); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 1500)
+	got := true
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			break
+		}
+		if string(buf[:n]) == string(probe) {
+			got = false
+			break
+		}
+	}
+	if got != want {
+		t.Fatalf("%s NAT: address-and-port-dependent filtering = %v, want %v", e.NAT.Behavior(), got, want)
+	}
+}
+
+// Close stops the server and releases the NAT.
+func (e *Env) Close() error {
+	select {
+	case <-e.done:
+		return nil
+	default:
+	}
+	close(e.done)
+	for _, conn := range e.conns {
+		conn.Close()
+	}
+	e.wg.Wait()
+	return e.NAT.Close()
+}
+
+// timeoutConn arms a read deadline before every read so that a filtered
+// response surfaces as a timeout error.
+type timeoutConn struct {
+	net.PacketConn
+}
+
+// ReadFrom implements net.PacketConn.
+func (c *timeoutConn) ReadFrom(p []byte) (int, net.Addr, error) {
+	if err := c.PacketConn.SetReadDeadline(time.Now().Add(DefaultTimeout)); err != nil {
+		return 0, nil, err
+	}
+	return c.PacketConn.ReadFrom(p)
+}
@@ -0,0 +1,54 @@
+package stuntest
+
+import (
+	"testing"
+
+	"github.com/lai0xn/stun"
+)
+
+func TestEnvBehaviors(t *testing.T) {
+	for _, tt := range []struct {
+		behavior           Behavior
+		independentMapping bool
+		dependentFiltering bool
+	}{
+		{FullCone, true, false},
+		{RestrictedCone, true, false},
+		{PortRestrictedCone, true, true},
+		{Symmetric, false, true},
+	} {
+		t.Run(tt.behavior.String(), func(t *testing.T) {
+			env, err := NewEnv(tt.behavior, stun.ServerConfig{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer env.Close()
+
+			client, err := env.NewClient(env.ServerAddr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			env.AssertMapped(t, client)
+			env.AssertEndpointIndependentMapping(t, tt.independentMapping)
+			env.AssertAddressAndPortDependentFiltering(t, tt.dependentFiltering)
+		})
+	}
+}
+
+func TestEnvMappedAddrIsStable(t *testing.T) {
+	env, err := NewEnv(PortRestrictedCone, stun.ServerConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer env.Close()
+
+	client, err := env.NewClient(env.ServerAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := env.AssertMapped(t, client)
+	second := env.AssertMapped(t, client)
+	if !first.IP.Equal(second.IP) || first.Port != second.Port {
+		t.Fatalf("mapping changed between transactions: %s:%d then %s:%d", first.IP, first.Port, second.IP, second.Port)
+	}
+}
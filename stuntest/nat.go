@@ -0,0 +1,298 @@
+package stuntest
+
+import (
+	"errors"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// Behavior selects the mapping and filtering behavior of a simulated NAT.
+type Behavior int
+
+const (
+	// FullCone uses endpoint-independent mapping and filtering: once a mapping
+	// exists, any external host may send through it.
+	FullCone Behavior = iota
+	// RestrictedCone uses endpoint-independent mapping and address-dependent
+	// filtering: only IPs the endpoint has sent to may reach it.
+	RestrictedCone
+	// PortRestrictedCone uses endpoint-independent mapping and
+	// address-and-port-dependent filtering: only IP:port pairs the endpoint
+	// has sent to may reach it.
+	PortRestrictedCone
+	// Symmetric uses address-and-port-dependent mapping and filtering: each
+	// destination gets its own mapping, which only that destination may use.
+	Symmetric
+)
+
+// String returns the string representation of the Behavior
+func (b Behavior) String() string {
+	switch b {
+	case FullCone:
+		return "FullCone"
+	case RestrictedCone:
+		return "RestrictedCone"
+	case PortRestrictedCone:
+		return "PortRestrictedCone"
+	case Symmetric:
+		return "Symmetric"
+	default:
+		return "Unknown"
+	}
+}
+
+// ErrNATClosed is returned by the endpoints of a NAT once it has been closed.
+var ErrNATClosed = errors.New("stuntest: NAT closed")
+
+// NAT is an in-process NAT simulator. Its zero value is not usable, create
+// one with NewNAT.
+type NAT struct {
+	behavior Behavior
+
+	mu       sync.Mutex
+	mappings map[mappingKey]*mapping
+	conns    []*natConn
+	nextHost byte
+	closed   bool
+}
+
+// mappingKey identifies a mapping: the internal endpoint, plus the
+// destination for address-and-port-dependent mapping.
+type mappingKey struct {
+	conn *natConn
+	dst  string
+}
+
+// mapping is an external transport address allocated by the NAT, backed by
+// a loopback socket.
+type mapping struct {
+	sock      *net.UDPConn
+	conn      *natConn
+	permitted map[string]bool
+}
+
+// packet is a datagram delivered to an internal endpoint.
+type packet struct {
+	data []byte
+	from net.Addr
+}
+
+// NewNAT returns a NAT simulating the given behavior.
+func NewNAT(behavior Behavior) *NAT {
+	return &NAT{
+		behavior: behavior,
+		mappings: make(map[mappingKey]*mapping),
+	}
+}
+
+// Behavior returns the behavior the NAT simulates.
+func (n *NAT) Behavior() Behavior {
+	return n.behavior
+}
+
+// NewConn returns a new endpoint behind the NAT. Its local address is a
+// private address that is never seen outside of the NAT.
+func (n *NAT) NewConn() (net.PacketConn, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.closed {
+		return nil, ErrNATClosed
+	}
+	n.nextHost++
+	c := &natConn{
+		nat:   n,
+		local: &net.UDPAddr{IP: net.IPv4(10, 0, 0, n.nextHost), Port: 50000},
+		in:    make(chan packet, 64),
+		done:  make(chan struct{}),
+	}
+	n.conns = append(n.conns, c)
+	return c, nil
+}
+
+// Mappings returns the external addresses currently allocated by the NAT.
+func (n *NAT) Mappings() []net.Addr {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	addrs := make([]net.Addr, 0, len(n.mappings))
+	for _, m := range n.mappings {
+		addrs = append(addrs, m.sock.LocalAddr())
+	}
+	return addrs
+}
+
+// Close releases every mapping and closes all endpoints.
+func (n *NAT) Close() error {
+	n.mu.Lock()
+	if n.closed {
+		n.mu.Unlock()
+		return nil
+	}
+	n.closed = true
+	mappings := n.mappings
+	n.mappings = nil
+	conns := n.conns
+	n.mu.Unlock()
+
+	for _, m := range mappings {
+		m.sock.Close()
+	}
+	for _, c := range conns {
+		c.Close()
+	}
+	return nil
+}
+
+// outbound sends p from c to dst through the appropriate mapping, creating
+// it and opening the filter for dst as needed.
+func (n *NAT) outbound(c *natConn, p []byte, dst *net.UDPAddr) (int, error) {
+	key := mappingKey{conn: c}
+	if n.behavior == Symmetric {
+		key.dst = dst.String()
+	}
+
+	n.mu.Lock()
+	if n.closed {
+		n.mu.Unlock()
+		return 0, ErrNATClosed
+	}
+	m, ok := n.mappings[key]
+	if !ok {
+		sock, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+		if err != nil {
+			n.mu.Unlock()
+			return 0, err
+		}
+		m = &mapping{sock: sock, conn: c, permitted: make(map[string]bool)}
+		n.mappings[key] = m
+		go n.inbound(m)
+	}
+	m.permitted[n.filterKey(dst)] = true
+	n.mu.Unlock()
+
+	return m.sock.WriteToUDP(p, dst)
+}
+
+// inbound reads datagrams arriving on a mapping and delivers those allowed
+// by the filtering behavior to the internal endpoint.
+func (n *NAT) inbound(m *mapping) {
+	buf := make([]byte, 65535)
+	for {
+		size, from, err := m.sock.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+
+		n.mu.Lock()
+		allowed := n.behavior == FullCone || m.permitted[n.filterKey(from)]
+		n.mu.Unlock()
+		if !allowed {
+			continue
+		}
+
+		data := append([]byte(nil), buf[:size]...)
+		select {
+		case m.conn.in <- packet{data: data, from: from}:
+		default:
+			// Receive queue full, drop like a real socket would
+		}
+	}
+}
+
+// filterKey returns the key under which the filter remembers dst.
+func (n *NAT) filterKey(dst *net.UDPAddr) string {
+	if n.behavior == RestrictedCone {
+		return dst.IP.String()
+	}
+	return dst.String()
+}
+
+// natConn is an endpoint behind a NAT.
+type natConn struct {
+	nat   *NAT
+	local *net.UDPAddr
+	in    chan packet
+
+	mu           sync.Mutex
+	readDeadline time.Time
+	done         chan struct{}
+	closeOnce    sync.Once
+}
+
+// ReadFrom implements net.PacketConn.
+func (c *natConn) ReadFrom(p []byte) (int, net.Addr, error) {
+	c.mu.Lock()
+	deadline := c.readDeadline
+	c.mu.Unlock()
+
+	var timeout <-chan time.Time
+	if !deadline.IsZero() {
+		d := time.Until(deadline)
+		if d <= 0 {
+			return 0, nil, os.ErrDeadlineExceeded
+		}
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case pkt := <-c.in:
+		return copy(p, pkt.data), pkt.from, nil
+	case <-timeout:
+		return 0, nil, os.ErrDeadlineExceeded
+	case <-c.done:
+		return 0, nil, net.ErrClosed
+	}
+}
+
+// WriteTo implements net.PacketConn.
+func (c *natConn) WriteTo(p []byte, addr net.Addr) (int, error) {
+	select {
+	case <-c.done:
+		return 0, net.ErrClosed
+	default:
+	}
+	dst, ok := addr.(*net.UDPAddr)
+	if !ok {
+		var err error
+		if dst, err = net.ResolveUDPAddr("udp4", addr.String()); err != nil {
+			return 0, err
+		}
+	}
+	return c.nat.outbound(c, p, dst)
+}
+
+// Close implements net.PacketConn.
+func (c *natConn) Close() error {
+	c.closeOnce.Do(func() { close(c.done) })
+	return nil
+}
+
+// LocalAddr implements net.PacketConn.
+func (c *natConn) LocalAddr() net.Addr {
+	return c.local
+}
+
+// SetDeadline implements net.PacketConn. Write deadlines are ignored since
+// writes never block.
+func (c *natConn) SetDeadline(t time.Time) error {
+	return c.SetReadDeadline(t)
+}
+
+// SetReadDeadline implements net.PacketConn. The deadline applies to reads
+// started after the call.
+func (c *natConn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	c.readDeadline = t
+	c.mu.Unlock()
+	return nil
+}
+
+// SetWriteDeadline implements net.PacketConn.
+func (c *natConn) SetWriteDeadline(t time.Time) error {
+	return nil
+}
@@ -0,0 +1,336 @@
+package stuntest
+
+import (
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrSimTimeout is returned by the wait methods of Clock and Network when the
+// simulation does not reach the awaited state within DefaultTimeout of real
+// time, which usually means the code under test is stuck.
+var ErrSimTimeout = errors.New("stuntest: simulation did not reach the awaited state")
+
+// ErrNoPacket is returned by Network.Deliver and Network.Drop for an index
+// outside the pending packets.
+var ErrNoPacket = errors.New("stuntest: no such pending packet")
+
+// errSimClosed is returned by the connections of a Network once closed.
+var errSimClosed = errors.New("stuntest: simulated connection closed")
+
+// waitFor calls cond under mu until it holds, waking up whenever *changed is
+// closed, for at most DefaultTimeout.
+func waitFor(mu *sync.Mutex, changed *chan struct{}, cond func() bool) error {
+	deadline := time.NewTimer(DefaultTimeout)
+	defer deadline.Stop()
+	for {
+		mu.Lock()
+		ok, ch := cond(), *changed
+		mu.Unlock()
+		if ok {
+			return nil
+		}
+		select {
+		case <-ch:
+		case <-deadline.C:
+			return ErrSimTimeout
+		}
+	}
+}
+
+// notify wakes up the waiters of changed. The caller holds the lock guarding
+// it.
+func notify(changed *chan struct{}) {
+	close(*changed)
+	*changed = make(chan struct{})
+}
+
+// Clock is a fake stun.Clock whose time only moves when Advance is called, so
+// that timeouts fire exactly when a test decides. Its zero value is not
+// usable, create one with NewClock.
+//
+// Example:
+//
+//	clock := stuntest.NewClock(time.Unix(0, 0))
+//	agent := stun.NewAgent(stun.AgentConfig{Conn: conn, Clock: clock})
+//	go agent.Do(req, peer)
+//	clock.WaitTimers(1)
+//	clock.Advance(stun.DefaultAgentTimeout) // Do returns ErrTransactionTimeout
+type Clock struct {
+	mu      sync.Mutex
+	now     time.Time
+	timers  []simTimer
+	changed chan struct{}
+}
+
+// simTimer is a channel returned by Clock.After, fired at when.
+type simTimer struct {
+	when time.Time
+	ch   chan time.Time
+}
+
+// NewClock returns a Clock set to start.
+func NewClock(start time.Time) *Clock {
+	return &Clock{now: start, changed: make(chan struct{})}
+}
+
+// Now returns the simulated time.
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// After returns a channel receiving the simulated time once the clock has
+// been advanced by d.
+func (c *Clock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+	c.timers = append(c.timers, simTimer{when: c.now.Add(d), ch: ch})
+	notify(&c.changed)
+	return ch
+}
+
+// Advance moves the clock forward by d, firing the timers that expire on the
+// way in order.
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	sort.SliceStable(c.timers, func(i, j int) bool {
+		return c.timers[i].when.Before(c.timers[j].when)
+	})
+	n := 0
+	for n < len(c.timers) && !c.timers[n].when.After(c.now) {
+		c.timers[n].ch <- c.timers[n].when
+		n++
+	}
+	c.timers = c.timers[n:]
+	notify(&c.changed)
+}
+
+// Timers returns the number of timers waiting for the clock to advance.
+func (c *Clock) Timers() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.timers)
+}
+
+// WaitTimers blocks until at least n timers are waiting, i.e. until the code
+// under test has reached the point where it waits on the clock. Timers whose
+// channel is no longer received from are still counted until they fire.
+//
+// Returns ErrSimTimeout if that does not happen within DefaultTimeout.
+func (c *Clock) WaitTimers(n int) error {
+	return waitFor(&c.mu, &c.changed, func() bool { return len(c.timers) >= n })
+}
+
+// NewRand returns a deterministic source of random bytes seeded with seed,
+// e.g. to make the transaction IDs of an Agent reproducible through
+// stun.AgentConfig.Rand.
+func NewRand(seed uint64) io.Reader {
+	var s [32]byte
+	for i := range 4 {
+		for j := range 8 {
+			s[i*8+j] = byte(seed >> (8 * j))
+		}
+		seed = seed*6364136223846793005 + 1442695040888963407
+	}
+	return rand.NewChaCha8(s)
+}
+
+// Packet is a datagram sent on a Network.
+type Packet struct {
+	From net.Addr
+	To   net.Addr
+	Data []byte
+}
+
+// Network is a simulated packet network whose datagrams are only delivered
+// when a test says so: every datagram written to one of its connections is
+// held as pending, and the test delivers, reorders or drops it. Together with
+// Clock, it makes interleavings such as a response arriving after the
+// transaction timed out reproducible. Its zero value is not usable, create
+// one with NewNetwork.
+//
+// Example:
+//
+//	network := stuntest.NewNetwork()
+//	a, _ := network.NewConn("10.0.0.1:3478")
+//	b, _ := network.NewConn("10.0.0.2:3478")
+//	a.WriteTo(msg, b.LocalAddr())
+//	network.WaitPending(1)
+//	network.Deliver(0) // b reads msg
+type Network struct {
+	mu      sync.Mutex
+	conns   map[string]*simConn
+	pending []Packet
+	changed chan struct{}
+}
+
+// NewNetwork returns an empty Network.
+func NewNetwork() *Network {
+	return &Network{conns: make(map[string]*simConn), changed: make(chan struct{})}
+}
+
+// NewConn returns a connection of the network bound to addr, an "ip:port"
+// UDP address.
+//
+// Returns an error if addr is malformed or already in use.
+func (n *Network) NewConn(addr string) (net.PacketConn, error) {
+	udpAddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.conns[udpAddr.String()] != nil {
+		return nil, errors.New("stuntest: address already in use: " + addr)
+	}
+	c := &simConn{
+		network: n,
+		addr:    udpAddr,
+		inbox:   make(chan Packet, 64),
+		closed:  make(chan struct{}),
+	}
+	n.conns[udpAddr.String()] = c
+	return c, nil
+}
+
+// Pending returns a copy of the datagrams sent and not yet delivered or
+// dropped, in the order they were sent.
+func (n *Network) Pending() []Packet {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]Packet(nil), n.pending...)
+}
+
+// WaitPending blocks until at least count datagrams are pending.
+//
+// Returns ErrSimTimeout if that does not happen within DefaultTimeout.
+func (n *Network) WaitPending(count int) error {
+	return waitFor(&n.mu, &n.changed, func() bool { return len(n.pending) >= count })
+}
+
+// Deliver hands the pending datagram at index i to its destination and
+// removes it from the pending ones, shifting the following ones down.
+// Datagrams to an address without a connection are lost, as are those to a
+// connection with 64 unread datagrams.
+func (n *Network) Deliver(i int) error {
+	p, err := n.take(i)
+	if err != nil {
+		return err
+	}
+	n.Inject(p.From, p.To, p.Data)
+	return nil
+}
+
+// Drop removes the pending datagram at index i without delivering it.
+func (n *Network) Drop(i int) error {
+	_, err := n.take(i)
+	return err
+}
+
+// take removes the pending datagram at index i.
+func (n *Network) take(i int) (Packet, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if i < 0 || i >= len(n.pending) {
+		return Packet{}, ErrNoPacket
+	}
+	p := n.pending[i]
+	n.pending = append(n.pending[:i], n.pending[i+1:]...)
+	notify(&n.changed)
+	return p, nil
+}
+
+// Inject delivers data to the connection bound to to right away, as if sent
+// from from, e.g. to script a duplicated or forged response.
+func (n *Network) Inject(from, to net.Addr, data []byte) {
+	n.mu.Lock()
+	c := n.conns[to.String()]
+	n.mu.Unlock()
+	if c == nil {
+		return
+	}
+	select {
+	case c.inbox <- Packet{From: from, To: to, Data: append([]byte(nil), data...)}:
+	default:
+	}
+}
+
+// simConn is a connection of a Network. Reads block until a datagram is
+// delivered or the connection is closed; time only exists for the Clock.
+type simConn struct {
+	network *Network
+	addr    *net.UDPAddr
+	inbox   chan Packet
+	closed  chan struct{}
+	once    sync.Once
+}
+
+// ReadFrom implements net.PacketConn.
+func (c *simConn) ReadFrom(p []byte) (int, net.Addr, error) {
+	select {
+	case pkt := <-c.inbox:
+		return copy(p, pkt.Data), pkt.From, nil
+	case <-c.closed:
+		return 0, nil, errSimClosed
+	}
+}
+
+// WriteTo implements net.PacketConn. The datagram is held as pending by the
+// network.
+func (c *simConn) WriteTo(p []byte, addr net.Addr) (int, error) {
+	select {
+	case <-c.closed:
+		return 0, errSimClosed
+	default:
+	}
+	n := c.network
+	n.mu.Lock()
+	n.pending = append(n.pending, Packet{From: c.addr, To: addr, Data: append([]byte(nil), p...)})
+	notify(&n.changed)
+	n.mu.Unlock()
+	return len(p), nil
+}
+
+// Close implements net.PacketConn.
+func (c *simConn) Close() error {
+	c.once.Do(func() {
+		close(c.closed)
+		c.network.mu.Lock()
+		delete(c.network.conns, c.addr.String())
+		c.network.mu.Unlock()
+	})
+	return nil
+}
+
+// LocalAddr implements net.PacketConn.
+func (c *simConn) LocalAddr() net.Addr {
+	return c.addr
+}
+
+// SetDeadline implements net.PacketConn. Deadlines are ignored.
+func (c *simConn) SetDeadline(t time.Time) error {
+	return nil
+}
+
+// SetReadDeadline implements net.PacketConn. Deadlines are ignored.
+func (c *simConn) SetReadDeadline(t time.Time) error {
+	return nil
+}
+
+// SetWriteDeadline implements net.PacketConn. Deadlines are ignored.
+func (c *simConn) SetWriteDeadline(t time.Time) error {
+	return nil
+}
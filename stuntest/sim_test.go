@@ -0,0 +1,228 @@
+package stuntest
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/lai0xn/stun"
+)
+
+// sim is an Agent on a simulated network, facing a peer scripted by the
+// test.
+type sim struct {
+	network *Network
+	clock   *Clock
+	agent   *stun.Agent
+	addr    net.Addr
+	peer    net.PacketConn
+}
+
+// result is what a call to Agent.Do returned.
+type result struct {
+	resp *stun.Message
+	err  error
+}
+
+// newSim returns a simulation whose transaction IDs derive from seed.
+func newSim(t *testing.T, seed uint64) *sim {
+	t.Helper()
+	s := &sim{network: NewNetwork(), clock: NewClock(time.Unix(0, 0))}
+	conn, err := s.network.NewConn("10.0.0.1:3478")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.peer, err = s.network.NewConn("10.0.0.2:3478"); err != nil {
+		t.Fatal(err)
+	}
+	s.addr = conn.LocalAddr()
+	s.agent = stun.NewAgent(stun.AgentConfig{
+		Conn:   conn,
+		Logger: stun.NewLogger(stun.LoggerConfig{Level: stun.FatalLevel}),
+		Clock:  s.clock,
+		Rand:   NewRand(seed),
+	})
+	t.Cleanup(func() {
+		s.agent.Close()
+		s.peer.Close()
+	})
+	return s
+}
+
+// do starts a Binding transaction towards the peer and waits until it has
+// sent its request and armed its timeout. It returns the request and the
+// channel receiving the outcome.
+func (s *sim) do(t *testing.T) (*stun.Message, <-chan result) {
+	t.Helper()
+	timers, sent := s.clock.Timers(), len(s.network.Pending())
+	ch := make(chan result, 1)
+	go func() {
+		resp, err := s.agent.Do(&stun.Message{Header: stun.Header{Type: stun.BindingRequest}}, s.peer.LocalAddr())
+		ch <- result{resp, err}
+	}()
+	if err := s.network.WaitPending(sent + 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.clock.WaitTimers(timers + 1); err != nil {
+		t.Fatal(err)
+	}
+	pending := s.network.Pending()
+	req, err := stun.NewMessage(pending[len(pending)-1].Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return req, ch
+}
+
+// respond has the peer send the response to req, left pending, and returns
+// its index.
+func (s *sim) respond(t *testing.T, req *stun.Message) int {
+	t.Helper()
+	resp := stun.NewBindingSuccess(req.Header.TransactionID)
+	if _, err := s.peer.WriteTo(resp.Encode(), s.addr); err != nil {
+		t.Fatal(err)
+	}
+	return len(s.network.Pending()) - 1
+}
+
+// timeout advances the clock past the transaction timeout of the agent.
+func (s *sim) timeout() {
+	s.clock.Advance(stun.DefaultAgentTimeout)
+}
+
+// wait returns the outcome of a transaction.
+func wait(t *testing.T, ch <-chan result) result {
+	t.Helper()
+	select {
+	case r := <-ch:
+		return r
+	case <-time.After(DefaultTimeout):
+		t.Fatal("transaction did not complete")
+		return result{}
+	}
+}
+
+func TestSimAgentInterleavings(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		// script drives the network and clock, returning the outcome of
+		// the transaction checked and the request it must answer
+		script  func(t *testing.T, s *sim) (result, *stun.Message)
+		wantErr error
+	}{
+		{
+			name: "response in time",
+			script: func(t *testing.T, s *sim) (result, *stun.Message) {
+				req, ch := s.do(t)
+				s.network.Drop(0)
+				s.network.Deliver(s.respond(t, req))
+				return wait(t, ch), req
+			},
+		},
+		{
+			name: "request dropped",
+			script: func(t *testing.T, s *sim) (result, *stun.Message) {
+				_, ch := s.do(t)
+				s.network.Drop(0)
+				s.timeout()
+				return wait(t, ch), nil
+			},
+			wantErr: stun.ErrTransactionTimeout,
+		},
+		{
+			name: "response after the timeout",
+			script: func(t *testing.T, s *sim) (result, *stun.Message) {
+				req, ch := s.do(t)
+				s.network.Drop(0)
+				late := s.respond(t, req)
+				s.timeout()
+				r := wait(t, ch)
+				// Delivered to a transaction that no longer exists
+				s.network.Deliver(late)
+				return r, nil
+			},
+			wantErr: stun.ErrTransactionTimeout,
+		},
+		{
+			name: "duplicated response",
+			script: func(t *testing.T, s *sim) (result, *stun.Message) {
+				req, ch := s.do(t)
+				s.network.Drop(0)
+				i := s.respond(t, req)
+				p := s.network.Pending()[i]
+				s.network.Deliver(i)
+				s.network.Inject(p.From, p.To, p.Data)
+				return wait(t, ch), req
+			},
+		},
+		{
+			name: "retransmit racing its response",
+			script: func(t *testing.T, s *sim) (result, *stun.Message) {
+				first, ch := s.do(t)
+				s.timeout()
+				if r := wait(t, ch); r.err != stun.ErrTransactionTimeout {
+					t.Fatalf("first attempt: got %v, want %v", r.err, stun.ErrTransactionTimeout)
+				}
+				// The application retransmits while the response to the
+				// first request is still in flight
+				late := s.respond(t, first)
+				retransmit, ch := s.do(t)
+				s.network.Deliver(late)
+				s.network.Deliver(s.respond(t, retransmit))
+				return wait(t, ch), retransmit
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newSim(t, 1)
+			r, req := tt.script(t, s)
+			if r.err != tt.wantErr {
+				t.Fatalf("got %v, want %v", r.err, tt.wantErr)
+			}
+			if req != nil && r.resp.Header.TransactionID != req.Header.TransactionID {
+				t.Fatalf("completed with the response to %s, want %s", r.resp.Header.TransactionID, req.Header.TransactionID)
+			}
+			if stats := s.agent.Stats(); stats.Pending != 0 {
+				t.Fatalf("%d transactions pending", stats.Pending)
+			}
+		})
+	}
+}
+
+// trace runs two transactions with seed, the first timing out, and returns
+// the datagrams the agent sent.
+func trace(t *testing.T, seed uint64) [][]byte {
+	s := newSim(t, seed)
+	var sent [][]byte
+	for range 2 {
+		_, ch := s.do(t)
+		sent = append(sent, s.network.Pending()[0].Data)
+		s.network.Drop(0)
+		s.timeout()
+		wait(t, ch)
+	}
+	return sent
+}
+
+func TestSimSameSeedSameTrace(t *testing.T) {
+	a, b := trace(t, 42), trace(t, 42)
+	for i := range a {
+		if !bytes.Equal(a[i], b[i]) {
+			t.Fatalf("datagram %d differs between runs with the same seed:\n%x\n%x", i, a[i], b[i])
+		}
+	}
+	if c := trace(t, 43); bytes.Equal(a[0], c[0]) {
+		t.Fatal("different seeds produced the same transaction ID")
+	}
+}
+
+func TestNetworkDeliverOutOfRange(t *testing.T) {
+	n := NewNetwork()
+	if err := n.Deliver(0); err != ErrNoPacket {
+		t.Fatalf("Deliver: got %v, want %v", err, ErrNoPacket)
+	}
+	if err := n.Drop(-1); err != ErrNoPacket {
+		t.Fatalf("Drop: got %v, want %v", err, ErrNoPacket)
+	}
+}
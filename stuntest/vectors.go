@@ -0,0 +1,196 @@
+package stuntest
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/lai0xn/stun"
+)
+
+// Credentials of the test vectors of RFC 5769.
+const (
+	// VectorShortTermPassword is the short-term password of the sample
+	// request and responses (RFC 5769 §2.1 to §2.3).
+	VectorShortTermPassword = "VOkJxbRl1RmTxUk/WvJxBt"
+	// VectorLongTermUsername, VectorLongTermPassword and VectorRealm are the
+	// long-term credentials of the sample request with long-term
+	// authentication (RFC 5769 §2.4). The password is given before
+	// SASLprep, which maps it to "TheMatrIX".
+	VectorLongTermUsername = "\u30de\u30c8\u30ea\u30c3\u30af\u30b9"
+	VectorLongTermPassword = "The\u00adM\u00aatr\u2168"
+	VectorRealm            = "example.org"
+)
+
+// Vector is a test vector of RFC 5769: the bytes of a message and what a
+// conforming implementation decodes from them.
+type Vector struct {
+	// Name is the section of RFC 5769 defining the vector.
+	Name string
+	// Raw is the encoded message. It must not be modified.
+	Raw []byte
+	// Key is the key of its MESSAGE-INTEGRITY.
+	Key stun.Integrity
+	// Username is its USERNAME, if any.
+	Username string
+	// Software is its SOFTWARE, if any.
+	Software string
+	// Mapped is its XOR-MAPPED-ADDRESS, if any.
+	Mapped *net.UDPAddr
+	// Fingerprint reports whether it ends with a FINGERPRINT.
+	Fingerprint bool
+}
+
+// SampleRequest is the sample request of RFC 5769 §2.1, with short-term
+// credentials, ICE attributes and a USERNAME padded with spaces.
+var SampleRequest = Vector{
+	Name: "RFC 5769 §2.1 Sample Request",
+	Raw: []byte{
+		0x00, 0x01, 0x00, 0x58,
+		0x21, 0x12, 0xa4, 0x42,
+		0xb7, 0xe7, 0xa7, 0x01, 0xbc, 0x34, 0xd6, 0x86, 0xfa, 0x87, 0xdf, 0xae,
+		0x80, 0x22, 0x00, 0x10, // SOFTWARE
+		0x53, 0x54, 0x55, 0x4e, 0x20, 0x74, 0x65, 0x73, 0x74, 0x20, 0x63, 0x6c, 0x69, 0x65, 0x6e, 0x74,
+		0x00, 0x24, 0x00, 0x04, // PRIORITY
+		0x6e, 0x00, 0x01, 0xff,
+		0x80, 0x29, 0x00, 0x08, // ICE-CONTROLLED
+		0x93, 0x2f, 0xf9, 0xb1, 0x51, 0x26, 0x3b, 0x36,
+		0x00, 0x06, 0x00, 0x09, // USERNAME
+		0x65, 0x76, 0x74, 0x6a, 0x3a, 0x68, 0x36, 0x76, 0x59, 0x20, 0x20, 0x20,
+		0x00, 0x08, 0x00, 0x14, // MESSAGE-INTEGRITY
+		0x9a, 0xea, 0xa7, 0x0c, 0xbf, 0xd8, 0xcb, 0x56, 0x78, 0x1e,
+		0xf2, 0xb5, 0xb2, 0xd3, 0xf2, 0x49, 0xc1, 0xb5, 0x71, 0xa2,
+		0x80, 0x28, 0x00, 0x04, // FINGERPRINT
+		0xe5, 0x7a, 0x3b, 0xcf,
+	},
+	Key:         stun.NewShortTermIntegrity(VectorShortTermPassword),
+	Username:    "evtj:h6vY",
+	Software:    "STUN test client",
+	Fingerprint: true,
+}
+
+// SampleIPv4Response is the sample IPv4 response of RFC 5769 §2.2.
+var SampleIPv4Response = Vector{
+	Name: "RFC 5769 §2.2 Sample IPv4 Response",
+	Raw: []byte{
+		0x01, 0x01, 0x00, 0x3c,
+		0x21, 0x12, 0xa4, 0x42,
+		0xb7, 0xe7, 0xa7, 0x01, 0xbc, 0x34, 0xd6, 0x86, 0xfa, 0x87, 0xdf, 0xae,
+		0x80, 0x22, 0x00, 0x0b, // SOFTWARE
+		0x74, 0x65, 0x73, 0x74, 0x20, 0x76, 0x65, 0x63, 0x74, 0x6f, 0x72, 0x20,
+		0x00, 0x20, 0x00, 0x08, // XOR-MAPPED-ADDRESS
+		0x00, 0x01, 0xa1, 0x47, 0xe1, 0x12, 0xa6, 0x43,
+		0x00, 0x08, 0x00, 0x14, // MESSAGE-INTEGRITY
+		0x2b, 0x91, 0xf5, 0x99, 0xfd, 0x9e, 0x90, 0xc3, 0x8c, 0x74,
+		0x89, 0xf9, 0x2a, 0xf9, 0xba, 0x53, 0xf0, 0x6b, 0xe7, 0xd7,
+		0x80, 0x28, 0x00, 0x04, // FINGERPRINT
+		0xc0, 0x7d, 0x4c, 0x96,
+	},
+	Key:         stun.NewShortTermIntegrity(VectorShortTermPassword),
+	Software:    "test vector",
+	Mapped:      &net.UDPAddr{IP: net.IPv4(192, 0, 2, 1).To4(), Port: 32853},
+	Fingerprint: true,
+}
+
+// SampleIPv6Response is the sample IPv6 response of RFC 5769 §2.3.
+var SampleIPv6Response = Vector{
+	Name: "RFC 5769 §2.3 Sample IPv6 Response",
+	Raw: []byte{
+		0x01, 0x01, 0x00, 0x48,
+		0x21, 0x12, 0xa4, 0x42,
+		0xb7, 0xe7, 0xa7, 0x01, 0xbc, 0x34, 0xd6, 0x86, 0xfa, 0x87, 0xdf, 0xae,
+		0x80, 0x22, 0x00, 0x0b, // SOFTWARE
+		0x74, 0x65, 0x73, 0x74, 0x20, 0x76, 0x65, 0x63, 0x74, 0x6f, 0x72, 0x20,
+		0x00, 0x20, 0x00, 0x14, // XOR-MAPPED-ADDRESS
+		0x00, 0x02, 0xa1, 0x47,
+		0x01, 0x13, 0xa9, 0xfa, 0xa5, 0xd3, 0xf1, 0x79,
+		0xbc, 0x25, 0xf4, 0xb5, 0xbe, 0xd2, 0xb9, 0xd9,
+		0x00, 0x08, 0x00, 0x14, // MESSAGE-INTEGRITY
+		0xa3, 0x82, 0x95, 0x4e, 0x4b, 0xe6, 0x7b, 0xf1, 0x17, 0x84,
+		0xc9, 0x7c, 0x82, 0x92, 0xc2, 0x75, 0xbf, 0xe3, 0xed, 0x41,
+		0x80, 0x28, 0x00, 0x04, // FINGERPRINT
+		0xc8, 0xfb, 0x0b, 0x4c,
+	},
+	Key:         stun.NewShortTermIntegrity(VectorShortTermPassword),
+	Software:    "test vector",
+	Mapped:      &net.UDPAddr{IP: net.ParseIP("2001:db8:1234:5678:11:2233:4455:6677"), Port: 32853},
+	Fingerprint: true,
+}
+
+// SampleLongTermRequest is the sample request with long-term
+// authentication of RFC 5769 §2.4, whose username and password need
+// SASLprep.
+var SampleLongTermRequest = Vector{
+	Name: "RFC 5769 §2.4 Sample Request with Long-Term Authentication",
+	Raw: []byte{
+		0x00, 0x01, 0x00, 0x60,
+		0x21, 0x12, 0xa4, 0x42,
+		0x78, 0xad, 0x34, 0x33, 0xc6, 0xad, 0x72, 0xc0, 0x29, 0xda, 0x41, 0x2e,
+		0x00, 0x06, 0x00, 0x12, // USERNAME
+		0xe3, 0x83, 0x9e, 0xe3, 0x83, 0x88, 0xe3, 0x83, 0xaa, 0xe3,
+		0x83, 0x83, 0xe3, 0x82, 0xaf, 0xe3, 0x82, 0xb9, 0x00, 0x00,
+		0x00, 0x15, 0x00, 0x1c, // NONCE
+		0x66, 0x2f, 0x2f, 0x34, 0x39, 0x39, 0x6b, 0x39, 0x35, 0x34, 0x64, 0x36, 0x4f, 0x4c,
+		0x33, 0x34, 0x6f, 0x4c, 0x39, 0x46, 0x53, 0x54, 0x76, 0x79, 0x36, 0x34, 0x73, 0x41,
+		0x00, 0x14, 0x00, 0x0b, // REALM
+		0x65, 0x78, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x2e, 0x6f, 0x72, 0x67, 0x00,
+		0x00, 0x08, 0x00, 0x14, // MESSAGE-INTEGRITY
+		0xf6, 0x70, 0x24, 0x65, 0x6d, 0xd6, 0x4a, 0x3e, 0x02, 0xb8,
+		0xe0, 0x71, 0x2e, 0x85, 0xc9, 0xa2, 0x8c, 0xa8, 0x96, 0x66,
+	},
+	// SASLprep(VectorLongTermPassword)
+	Key:      stun.NewLongTermIntegrity(VectorLongTermUsername, VectorRealm, "TheMatrIX"),
+	Username: VectorLongTermUsername,
+}
+
+// Vectors are the test vectors of RFC 5769, in the order of the RFC.
+var Vectors = []Vector{SampleRequest, SampleIPv4Response, SampleIPv6Response, SampleLongTermRequest}
+
+// AssertVector fails the test unless the stun package, and the codecs
+// registered with it, decode v as RFC 5769 specifies: the message is valid
+// (see stun.Message.Validate), its MESSAGE-INTEGRITY matches v.Key, its
+// FINGERPRINT, USERNAME, SOFTWARE and XOR-MAPPED-ADDRESS match the vector,
+// and it re-encodes to the same bytes with Message.EncodeRaw. Applications
+// can run it with the credentials plumbing they use in production, for
+// instance after registering attribute codecs:
+//
+//	for _, v := range stuntest.Vectors {
+//		t.Run(v.Name, func(t *testing.T) { stuntest.AssertVector(t, v) })
+//	}
+func AssertVector(t testing.TB, v Vector) {
+	t.Helper()
+	m, err := stun.NewMessage(v.Raw)
+	if err != nil {
+		t.Fatalf("%s: decoding: %v", v.Name, err)
+	}
+	if err := m.Validate(); err != nil {
+		t.Errorf("%s: validation: %v", v.Name, err)
+	}
+	if err := v.Key.Check(m); err != nil {
+		t.Errorf("%s: MESSAGE-INTEGRITY: %v", v.Name, err)
+	}
+	if err := stun.CheckFingerprint(v.Raw); v.Fingerprint && err != nil {
+		t.Errorf("%s: FINGERPRINT: %v", v.Name, err)
+	}
+	if v.Username != "" {
+		var username stun.UsernameAttribute
+		if err := username.GetFrom(m); err != nil || string(username) != v.Username {
+			t.Errorf("%s: USERNAME is %q (%v), want %q", v.Name, username, err, v.Username)
+		}
+	}
+	if v.Software != "" {
+		var software stun.SoftwareAttribute
+		if err := software.GetFrom(m); err != nil || string(software) != v.Software {
+			t.Errorf("%s: SOFTWARE is %q (%v), want %q", v.Name, software, err, v.Software)
+		}
+	}
+	if v.Mapped != nil {
+		var addr stun.XorMappedAddr
+		if err := addr.GetFrom(m); err != nil || !addr.IP.Equal(v.Mapped.IP) || int(addr.Port) != v.Mapped.Port {
+			t.Errorf("%s: XOR-MAPPED-ADDRESS is %v (%v), want %v", v.Name, addr, err, v.Mapped)
+		}
+	}
+	if enc := m.EncodeRaw(); !bytes.Equal(enc, v.Raw) {
+		t.Errorf("%s: re-encoded to %x, want %x", v.Name, enc, v.Raw)
+	}
+}
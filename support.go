@@ -0,0 +1,146 @@
+package stun
+
+import (
+	"runtime"
+	"sort"
+)
+
+// attrNames are the names of the attributes defined by the package, as
+// written in their RFCs.
+var attrNames = map[StunAttribute]string{
+	MappedAddress:           "MAPPED-ADDRESS",
+	ChangeRequest:           "CHANGE-REQUEST",
+	SourceAddress:           "SOURCE-ADDRESS",
+	ChangedAddress:          "CHANGED-ADDRESS",
+	Username:                "USERNAME",
+	MessageIntegrity:        "MESSAGE-INTEGRITY",
+	ErrorCode:               "ERROR-CODE",
+	UnknownStunAttributes:   "UNKNOWN-ATTRIBUTES",
+	ChannelNumber:           "CHANNEL-NUMBER",
+	Lifetime:                "LIFETIME",
+	XORPeerAddress:          "XOR-PEER-ADDRESS",
+	Data:                    "DATA",
+	Realm:                   "REALM",
+	Nonce:                   "NONCE",
+	XORRelayedAddress:       "XOR-RELAYED-ADDRESS",
+	EvenPort:                "EVEN-PORT",
+	RequestedTransport:      "REQUESTED-TRANSPORT",
+	DontFragment:            "DONT-FRAGMENT",
+	AccessTokenAttr:         "ACCESS-TOKEN",
+	MessageIntegritySHA256:  "MESSAGE-INTEGRITY-SHA256",
+	PasswordAlgorithm:       "PASSWORD-ALGORITHM",
+	UserHash:                "USERHASH",
+	XORMappedAddress:        "XOR-MAPPED-ADDRESS",
+	ReservationToken:        "RESERVATION-TOKEN",
+	Priority:                "PRIORITY",
+	UseCandidate:            "USE-CANDIDATE",
+	Padding:                 "PADDING",
+	PasswordAlgorithms:      "PASSWORD-ALGORITHMS",
+	AlternateDomain:         "ALTERNATE-DOMAIN",
+	Software:                "SOFTWARE",
+	AlternateServer:         "ALTERNATE-SERVER",
+	FingerprintAttr:         "FINGERPRINT",
+	ICEControlled:           "ICE-CONTROLLED",
+	ICEControlling:          "ICE-CONTROLLING",
+	ResponseOrigin:          "RESPONSE-ORIGIN",
+	OtherAddress:            "OTHER-ADDRESS",
+	ThirdPartyAuthorization: "THIRD-PARTY-AUTHORIZATION",
+	MobilityTicket:          "MOBILITY-TICKET",
+	CapabilitiesAttr:        "CAPABILITIES",
+}
+
+// Name returns the name of the attribute type: the one of its RFC for the
+// attributes of the package, the AttrCodec name for registered ones, and an
+// empty string for unknown types.
+func (t StunAttribute) Name() string {
+	if name, ok := attrNames[t]; ok {
+		return name
+	}
+	if c, ok := LookupAttr(t); ok {
+		return c.Name
+	}
+	return ""
+}
+
+// RFCSupport is the support of a specification by the package.
+type RFCSupport struct {
+	// RFC is the number of the specification.
+	RFC int
+	// Title is its short title.
+	Title string
+	// Notes tells what is covered when support is partial.
+	Notes string
+}
+
+// AttrSupport is an attribute the package understands.
+type AttrSupport struct {
+	Type StunAttribute
+	Name string
+	// Registered reports an attribute provided by a codec registered with
+	// RegisterAttr rather than by the package.
+	Registered bool
+}
+
+// SupportMatrix describes what the running build of the package supports.
+type SupportMatrix struct {
+	// Version is the release of the package (see ReadBuildInfo).
+	Version string
+	// RFCs are the specifications implemented, by number.
+	RFCs []RFCSupport
+	// Attributes are the attributes understood, by type, including those of
+	// registered codecs.
+	Attributes []AttrSupport
+	// Transports are the transports a Client can use on this platform.
+	Transports []string
+}
+
+// supportedRFCs are the specifications implemented by the package.
+var supportedRFCs = []RFCSupport{
+	{3489, "Classic STUN", "compatibility with ClassicSTUN"},
+	{4013, "SASLprep", ""},
+	{5389, "STUN", ""},
+	{5769, "STUN test vectors", "stuntest.Vectors"},
+	{5766, "TURN", "attributes only, no allocations"},
+	{5780, "NAT behavior discovery", ""},
+	{7064, "STUN URIs", ""},
+	{7065, "TURN URIs", ""},
+	{7635, "Third-party authorization", "ACCESS-TOKEN attributes"},
+	{7983, "Multiplexing", "CheckFingerprint"},
+	{8016, "TURN mobility", "MOBILITY-TICKET attribute"},
+	{8265, "PRECIS OpaqueString", ""},
+	{8445, "ICE", "connectivity check attributes and Agent"},
+	{8489, "STUN (revised)", ""},
+}
+
+// Features returns the support matrix of the running build, so that
+// applications and diagnostics tools can adapt to it. Attributes registered
+// with RegisterAttr are included.
+//
+// Example:
+//
+//	for _, rfc := range stun.Features().RFCs {
+//		fmt.Printf("RFC %d %s\n", rfc.RFC, rfc.Title)
+//	}
+func Features() SupportMatrix {
+	m := SupportMatrix{
+		Version: ReadBuildInfo().String(),
+		RFCs:    append([]RFCSupport(nil), supportedRFCs...),
+	}
+	for t, name := range attrNames {
+		m.Attributes = append(m.Attributes, AttrSupport{Type: t, Name: name})
+	}
+	for t, c := range registeredAttrs() {
+		if _, ok := attrNames[t]; !ok {
+			m.Attributes = append(m.Attributes, AttrSupport{Type: t, Name: c.Name, Registered: true})
+		}
+	}
+	sort.Slice(m.Attributes, func(i, j int) bool {
+		return m.Attributes[i].Type < m.Attributes[j].Type
+	})
+	if runtime.GOOS == "js" {
+		m.Transports = []string{"http", "websocket"}
+	} else {
+		m.Transports = []string{"udp", "http"}
+	}
+	return m
+}
@@ -0,0 +1,112 @@
+package stun
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// TextAttribute is the codec of the attributes carrying UTF-8 text, such as
+// USERNAME, REALM, NONCE and SOFTWARE: values must be valid UTF-8 and within
+// the limits of the attribute, in bytes and, when MaxChars is set, in
+// characters.
+//
+// Example:
+//
+//	description := stun.TextAttribute{Type: 0xC001, MaxBytes: 256}
+//	if err := description.Set(msg, "edge node 12"); err != nil {
+//		log.Fatal(err)
+//	}
+type TextAttribute struct {
+	Type     StunAttribute
+	MaxBytes int
+	MaxChars int
+}
+
+// Text codecs of the attributes defined by RFC 5389 §15.
+var (
+	usernameText = TextAttribute{Type: Username, MaxBytes: MaxUsernameLength}
+	realmText    = TextAttribute{Type: Realm, MaxBytes: MaxRealmLength, MaxChars: maxQuotedChars}
+	nonceText    = TextAttribute{Type: Nonce, MaxBytes: MaxNonceLength, MaxChars: maxQuotedChars}
+	softwareText = TextAttribute{Type: Software, MaxBytes: MaxSoftwareLength, MaxChars: maxQuotedChars}
+	reasonText   = TextAttribute{Type: ErrorCode, MaxBytes: MaxReasonLength, MaxChars: maxQuotedChars}
+)
+
+// TextLengthError reports a text attribute value exceeding the limits of its
+// attribute. It matches ErrAttrTooLong with errors.Is.
+type TextLengthError struct {
+	Attr     StunAttribute
+	Bytes    int
+	Chars    int
+	MaxBytes int
+	MaxChars int
+}
+
+// Error implements the error interface.
+func (e *TextLengthError) Error() string {
+	if e.MaxChars > 0 && e.Chars > e.MaxChars {
+		return fmt.Sprintf("attribute 0x%04x too long: %d characters, max %d", uint16(e.Attr), e.Chars, e.MaxChars)
+	}
+	return fmt.Sprintf("attribute 0x%04x too long: %d bytes, max %d", uint16(e.Attr), e.Bytes, e.MaxBytes)
+}
+
+// Is makes the error match ErrAttrTooLong.
+func (e *TextLengthError) Is(target error) bool {
+	return target == ErrAttrTooLong
+}
+
+// Check validates v against the encoding and limits of the attribute.
+//
+// Returns an error wrapping ErrInvalidUTF8 if v is not valid UTF-8, and a
+// *TextLengthError if it is too long.
+func (t TextAttribute) Check(v string) error {
+	if !utf8.ValidString(v) {
+		return fmt.Errorf("attribute 0x%04x: %w", uint16(t.Type), ErrInvalidUTF8)
+	}
+	chars := 0
+	if t.MaxChars > 0 {
+		chars = utf8.RuneCountInString(v)
+	}
+	if len(v) > t.MaxBytes || chars > t.MaxChars {
+		return &TextLengthError{
+			Attr:     t.Type,
+			Bytes:    len(v),
+			Chars:    chars,
+			MaxBytes: t.MaxBytes,
+			MaxChars: t.MaxChars,
+		}
+	}
+	return nil
+}
+
+// Add appends v to m as an attribute of the codec type, once checked.
+func (t TextAttribute) Add(m *Message, v string) error {
+	if err := t.Check(v); err != nil {
+		return err
+	}
+	return m.Add(t.Type, []byte(v))
+}
+
+// Set is like Add but replaces any attribute of the codec type in m.
+func (t TextAttribute) Set(m *Message, v string) error {
+	if err := t.Check(v); err != nil {
+		return err
+	}
+	return m.Set(t.Type, []byte(v))
+}
+
+// Get returns the value of the first attribute of the codec type in m,
+// without padding, once checked.
+//
+// Returns ErrAttrNotFound if m has no such attribute, and the errors of
+// Check if the received value is invalid.
+func (t TextAttribute) Get(m *Message) (string, error) {
+	attr, ok := m.GetAttr(t.Type)
+	if !ok {
+		return "", ErrAttrNotFound
+	}
+	v := string(attr.rawValue())
+	if err := t.Check(v); err != nil {
+		return "", err
+	}
+	return v, nil
+}
@@ -0,0 +1,54 @@
+package stun
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// TransactionID is the 96-bit identifier of a STUN transaction (RFC 5389 §6),
+// chosen by the client and echoed in the response to match it with the
+// request.
+type TransactionID [12]byte
+
+// NewTransactionID returns a transaction ID read from crypto/rand, as RFC
+// 5389 §6 requires it to be uniformly and randomly chosen. It panics if the
+// random source fails, as crypto/rand itself does from Go 1.24, rather than
+// hand out a predictable ID shared by every transaction.
+//
+// Example:
+//
+//	req := &stun.Message{Header: stun.Header{
+//		Type:          stun.BindingRequest,
+//		TransactionID: stun.NewTransactionID(),
+//	}}
+func NewTransactionID() TransactionID {
+	var id TransactionID
+	if _, err := rand.Read(id[:]); err != nil {
+		panic("stun: crypto/rand failed: " + err.Error())
+	}
+	return id
+}
+
+// String returns the transaction ID as 24 lowercase hexadecimal digits, the
+// form used in logs.
+func (id TransactionID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// Equal reports whether id and other are the same transaction ID.
+func (id TransactionID) Equal(other TransactionID) bool {
+	return id == other
+}
+
+// IsZero reports whether id is the zero transaction ID, that of a message
+// whose ID was never set.
+func (id TransactionID) IsZero() bool {
+	return id == TransactionID{}
+}
+
+// AddTo sets the transaction ID of m, making transaction IDs setters, e.g.
+// for a response built with Build to echo the ID of its request.
+func (id TransactionID) AddTo(m *Message) error {
+	m.Header.TransactionID = id
+	return nil
+}
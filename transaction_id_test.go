@@ -0,0 +1,17 @@
+package stun
+
+import "testing"
+
+func TestNewTransactionIDUnique(t *testing.T) {
+	seen := make(map[TransactionID]bool)
+	for range 1000 {
+		id := NewTransactionID()
+		if id.IsZero() {
+			t.Fatal("zero transaction ID")
+		}
+		if seen[id] {
+			t.Fatalf("transaction ID %s handed out twice", id)
+		}
+		seen[id] = true
+	}
+}
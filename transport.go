@@ -0,0 +1,71 @@
+package stun
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// maxRelayedMessageSize bounds the responses read from a Transport relay.
+const maxRelayedMessageSize = 64 * 1024
+
+// Transport carries the messages of a Client when UDP is not available,
+// typically to a relay forwarding them to a STUN server. RoundTrip sends the
+// encoded request and returns the encoded response.
+//
+// A relay should put a PROXY protocol header carrying the address of the
+// client in front of the messages it forwards to a server configured with
+// ServerConfig.TrustedProxies, so that the mapped address reflects the
+// client rather than the relay (see AppendProxyHeader).
+type Transport interface {
+	RoundTrip(req []byte) ([]byte, error)
+}
+
+// ContextTransport is a Transport whose round trips can be canceled.
+// Client.DialContext passes its context to the transports implementing it.
+type ContextTransport interface {
+	Transport
+	RoundTripContext(ctx context.Context, req []byte) ([]byte, error)
+}
+
+// HTTPTransport is a Transport POSTing every request to a relay as an
+// application/octet-stream body, the response body being the STUN response.
+// Under GOOS=js, net/http is backed by the Fetch API, so HTTPTransport works
+// from browsers.
+type HTTPTransport struct {
+	// URL is the endpoint of the relay.
+	URL string
+	// Client is the HTTP client used for requests. Nil selects
+	// http.DefaultClient.
+	Client *http.Client
+}
+
+// RoundTrip implements Transport.
+func (t *HTTPTransport) RoundTrip(req []byte) ([]byte, error) {
+	return t.RoundTripContext(context.Background(), req)
+}
+
+// RoundTripContext implements ContextTransport, ctx bounding the whole HTTP
+// exchange.
+func (t *HTTPTransport) RoundTripContext(ctx context.Context, req []byte) ([]byte, error) {
+	c := t.Client
+	if c == nil {
+		c = http.DefaultClient
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, t.URL, bytes.NewReader(req))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/octet-stream")
+	resp, err := c.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("relay responded %s", resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxRelayedMessageSize))
+}
@@ -0,0 +1,154 @@
+//go:build js && wasm
+
+package stun
+
+import (
+	"context"
+	"sync"
+	"syscall/js"
+	"time"
+)
+
+// DefaultWebSocketTimeout bounds the wait for a response of a
+// WebSocketTransport whose Timeout is left unset.
+const DefaultWebSocketTimeout = 5 * time.Second
+
+// WebSocketTransport is a Transport exchanging messages with a relay over a
+// WebSocket, one STUN message per binary frame. It uses the WebSocket API of
+// the JavaScript host and is only available under GOOS=js.
+//
+// Example:
+//
+//	t, err := stun.DialWebSocket("wss://relay.example.com/stun")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	defer t.Close()
+//	client := stun.NewClientWithTransport("relay", t, nil)
+type WebSocketTransport struct {
+	// Timeout bounds the wait for a response. Zero selects
+	// DefaultWebSocketTimeout.
+	Timeout time.Duration
+
+	mu        sync.Mutex
+	ws        js.Value
+	listeners []wsListener
+	msgs      chan []byte
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// DialWebSocket opens a WebSocket to url and waits until it is established.
+//
+// Returns ErrTransportClosed if the connection cannot be established.
+func DialWebSocket(url string) (*WebSocketTransport, error) {
+	ws := js.Global().Get("WebSocket").New(url)
+	ws.Set("binaryType", "arraybuffer")
+	t := &WebSocketTransport{
+		ws:     ws,
+		msgs:   make(chan []byte, 1),
+		closed: make(chan struct{}),
+	}
+
+	opened := make(chan struct{})
+	t.on("open", func(js.Value) { close(opened) })
+	t.on("close", func(js.Value) { t.closeOnce.Do(func() { close(t.closed) }) })
+	t.on("message", func(ev js.Value) {
+		data := js.Global().Get("Uint8Array").New(ev.Get("data"))
+		b := make([]byte, data.Get("length").Int())
+		js.CopyBytesToGo(b, data)
+		// Callbacks must not block: frames nobody waits for are dropped
+		select {
+		case t.msgs <- b:
+		default:
+		}
+	})
+
+	select {
+	case <-opened:
+		return t, nil
+	case <-t.closed:
+		t.release()
+		return nil, ErrTransportClosed
+	}
+}
+
+// wsListener is a Go function registered as a WebSocket event listener.
+type wsListener struct {
+	typ string
+	fn  js.Func
+}
+
+// on registers f as the listener of the WebSocket event typ.
+func (t *WebSocketTransport) on(typ string, f func(ev js.Value)) {
+	fn := js.FuncOf(func(this js.Value, args []js.Value) any {
+		f(args[0])
+		return nil
+	})
+	t.listeners = append(t.listeners, wsListener{typ: typ, fn: fn})
+	t.ws.Call("addEventListener", typ, fn)
+}
+
+// RoundTrip implements Transport. Frames not carrying the response to req,
+// such as late responses to timed out requests, are discarded.
+//
+// Returns ErrTransactionTimeout if no response arrives in time and
+// ErrTransportClosed if the WebSocket is closed.
+func (t *WebSocketTransport) RoundTrip(req []byte) ([]byte, error) {
+	return t.RoundTripContext(context.Background(), req)
+}
+
+// RoundTripContext implements ContextTransport: RoundTrip returning
+// ctx.Err() if ctx is done before the response arrives.
+func (t *WebSocketTransport) RoundTripContext(ctx context.Context, req []byte) ([]byte, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	select {
+	case <-t.closed:
+		return nil, ErrTransportClosed
+	default:
+	}
+
+	buf := js.Global().Get("Uint8Array").New(len(req))
+	js.CopyBytesToJS(buf, req)
+	t.ws.Call("send", buf)
+
+	timeout := t.Timeout
+	if timeout == 0 {
+		timeout = DefaultWebSocketTimeout
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		select {
+		case resp := <-t.msgs:
+			if len(resp) >= headrLength && len(req) >= headrLength && string(resp[8:20]) == string(req[8:20]) {
+				return resp, nil
+			}
+		case <-timer.C:
+			return nil, ErrTransactionTimeout
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-t.closed:
+			return nil, ErrTransportClosed
+		}
+	}
+}
+
+// Close closes the WebSocket and releases its listeners.
+func (t *WebSocketTransport) Close() error {
+	t.ws.Call("close")
+	t.closeOnce.Do(func() { close(t.closed) })
+	t.release()
+	return nil
+}
+
+// release frees the Go functions registered as listeners.
+func (t *WebSocketTransport) release() {
+	for _, l := range t.listeners {
+		t.ws.Call("removeEventListener", l.typ, l.fn)
+		l.fn.Release()
+	}
+	t.listeners = nil
+}
@@ -0,0 +1,115 @@
+package stun
+
+// TransportRule states over which networks a request for Method may be
+// received, optionally only when it asks for a given REQUESTED-TRANSPORT.
+// Requests breaking the rule get an error response with Code.
+type TransportRule struct {
+	// Method is the method the rule applies to.
+	Method Method
+	// RequestedTransport restricts the rule to requests carrying a
+	// REQUESTED-TRANSPORT attribute with this protocol (TransportUDP or
+	// TransportTCP). Zero applies the rule whatever the attribute.
+	RequestedTransport uint8
+	// Networks lists the networks of the local address the request may be
+	// received on ("udp", "tcp"), as reported by net.Addr.Network. Empty
+	// allows any network.
+	Networks []string
+	// Code is the error code sent when the network is not allowed. Zero
+	// selects 442 (Unsupported Transport Protocol).
+	Code int
+	// Reason is the reason phrase of the error response. Empty selects the
+	// phrase matching Code.
+	Reason string
+}
+
+// DefaultTransportRules is the transport policy of a server whose
+// configuration leaves TransportRules unset: UDP allocations may be
+// requested over any transport (RFC 5766 §6.2), TCP allocations only over
+// TCP or TLS, other transports being rejected with 400 as RFC 6062 §5.1
+// requires. Allocations of any other transport get a 442.
+var DefaultTransportRules = []TransportRule{
+	{Method: MethodAllocate, RequestedTransport: TransportUDP},
+	{Method: MethodAllocate, RequestedTransport: TransportTCP, Networks: []string{"tcp"}, Code: CodeBadRequest},
+}
+
+// allows reports whether the rule allows a request received on network.
+func (r TransportRule) allows(network string) bool {
+	if len(r.Networks) == 0 {
+		return true
+	}
+	for _, n := range r.Networks {
+		if n == network {
+			return true
+		}
+	}
+	return false
+}
+
+// reject returns the error response of the rule to req.
+func (r TransportRule) reject(req *Message) (*Message, error) {
+	code, reason := r.Code, r.Reason
+	if code == 0 {
+		code = CodeUnsupportedTransport
+	}
+	if reason == "" {
+		reason = ErrorReason(code)
+	}
+	return newErrorResponse(req, code, reason)
+}
+
+// TransportPolicy returns middleware that enforces the transport rules on
+// inbound requests, so that requests for features bound to another
+// transport, such as TCP allocations over UDP, are answered with consistent
+// error codes in one place instead of in each handler.
+//
+// For a request, the first rule matching its method and REQUESTED-TRANSPORT
+// decides: the request is passed on if the rule allows the network it was
+// received on and rejected with the error of the rule otherwise. A request
+// whose REQUESTED-TRANSPORT matches none of the rules of its method is
+// rejected with 442. Requests for methods without rules are passed on.
+//
+// Example:
+//
+//	rules := append([]stun.TransportRule{
+//		{Method: stun.MethodBinding, Networks: []string{"udp"}},
+//	}, stun.DefaultTransportRules...)
+//	server := stun.NewServer(stun.ServerConfig{
+//		Addr:           "0.0.0.0",
+//		Port:           "3478",
+//		TransportRules: rules,
+//	})
+func TransportPolicy(rules ...TransportRule) Middleware {
+	return func(next Handler) Handler {
+		return func(req *Request) (*Message, error) {
+			if req.Message.Header.Type.Class() != ClassRequest {
+				return next(req)
+			}
+			method := req.Message.Header.Type.Method()
+			network := "udp"
+			if req.LocalAddr != nil {
+				network = req.LocalAddr.Network()
+			}
+			var rt RequestedTransportAttribute
+			hasTransport := rt.GetFrom(req.Message) == nil
+
+			governed := false
+			for _, rule := range rules {
+				if rule.Method != method {
+					continue
+				}
+				governed = true
+				if rule.RequestedTransport != 0 && (!hasTransport || rt.Protocol != rule.RequestedTransport) {
+					continue
+				}
+				if !rule.allows(network) {
+					return rule.reject(req.Message)
+				}
+				return next(req)
+			}
+			if governed && hasTransport {
+				return TransportRule{}.reject(req.Message)
+			}
+			return next(req)
+		}
+	}
+}
@@ -0,0 +1,265 @@
+package stun
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+// Valid channel numbers for ChannelBind (RFC 5766 §11)
+const (
+	MinChannelNumber = 0x4000
+	MaxChannelNumber = 0x7FFF
+)
+
+// ChannelNumberAttribute is the value of a CHANNEL-NUMBER attribute
+// (RFC 5766 §14.1): the channel a peer is bound to, in 0x4000-0x7FFF.
+type ChannelNumberAttribute uint16
+
+// AddTo appends the channel number to m as a CHANNEL-NUMBER attribute.
+func (c ChannelNumberAttribute) AddTo(m *Message) error {
+	if c < MinChannelNumber || c > MaxChannelNumber {
+		return fmt.Errorf("invalid channel number: 0x%04x", uint16(c))
+	}
+	value := make([]byte, 4) // Channel number followed by 16 reserved bits
+	binary.BigEndian.PutUint16(value, uint16(c))
+	return m.Add(ChannelNumber, value)
+}
+
+// GetFrom decodes the CHANNEL-NUMBER attribute of m into c.
+//
+// Returns ErrAttrNotFound if m has no CHANNEL-NUMBER attribute.
+func (c *ChannelNumberAttribute) GetFrom(m *Message) error {
+	value, err := fixedValue(m, ChannelNumber, 4)
+	if err != nil {
+		return err
+	}
+	*c = ChannelNumberAttribute(binary.BigEndian.Uint16(value))
+	return nil
+}
+
+// LifetimeAttribute is the value of a LIFETIME attribute (RFC 5766 §14.2):
+// the remaining lifetime of an allocation, with a one second resolution.
+type LifetimeAttribute time.Duration
+
+// AddTo appends the lifetime to m as a LIFETIME attribute.
+func (l LifetimeAttribute) AddTo(m *Message) error {
+	value := make([]byte, 4)
+	binary.BigEndian.PutUint32(value, uint32(time.Duration(l)/time.Second))
+	return m.Add(Lifetime, value)
+}
+
+// GetFrom decodes the LIFETIME attribute of m into l.
+//
+// Returns ErrAttrNotFound if m has no LIFETIME attribute.
+func (l *LifetimeAttribute) GetFrom(m *Message) error {
+	value, err := fixedValue(m, Lifetime, 4)
+	if err != nil {
+		return err
+	}
+	*l = LifetimeAttribute(time.Duration(binary.BigEndian.Uint32(value)) * time.Second)
+	return nil
+}
+
+// XorPeerAddr is the value of an XOR-PEER-ADDRESS attribute (RFC 5766 §14.3):
+// the address of a peer as seen from the TURN server.
+type XorPeerAddr XorMappedAddr
+
+// AddTo appends the address to m as an XOR-PEER-ADDRESS attribute. The
+// transaction ID of m must be set beforehand since it is part of the XOR key
+// for IPv6 addresses.
+func (a XorPeerAddr) AddTo(m *Message) error {
+	return AddressAttribute{Type: XORPeerAddress, XOR: true}.Add(m, MappedAddr(a))
+}
+
+// GetFrom decodes the XOR-PEER-ADDRESS attribute of m into a.
+//
+// Returns ErrAttrNotFound if m has no XOR-PEER-ADDRESS attribute.
+func (a *XorPeerAddr) GetFrom(m *Message) error {
+	return getAddr(m, AddressAttribute{Type: XORPeerAddress, XOR: true}, (*MappedAddr)(a))
+}
+
+// XorRelayedAddr is the value of an XOR-RELAYED-ADDRESS attribute
+// (RFC 5766 §14.5): the relayed transport address allocated by the TURN server.
+type XorRelayedAddr XorMappedAddr
+
+// AddTo appends the address to m as an XOR-RELAYED-ADDRESS attribute. The
+// transaction ID of m must be set beforehand.
+func (a XorRelayedAddr) AddTo(m *Message) error {
+	return AddressAttribute{Type: XORRelayedAddress, XOR: true}.Add(m, MappedAddr(a))
+}
+
+// GetFrom decodes the XOR-RELAYED-ADDRESS attribute of m into a.
+//
+// Returns ErrAttrNotFound if m has no XOR-RELAYED-ADDRESS attribute.
+func (a *XorRelayedAddr) GetFrom(m *Message) error {
+	return getAddr(m, AddressAttribute{Type: XORRelayedAddress, XOR: true}, (*MappedAddr)(a))
+}
+
+// DataAttribute is the value of a DATA attribute (RFC 5766 §14.4): the
+// application payload of Send and Data indications.
+type DataAttribute []byte
+
+// AddTo appends the payload to m as a DATA attribute.
+func (d DataAttribute) AddTo(m *Message) error {
+	if paddedLength(len(d)) > maxAttrValueLength(m) {
+		return ErrAttrTooLong
+	}
+	return m.Add(Data, d)
+}
+
+// GetFrom decodes the DATA attribute of m into d. The payload is copied, so
+// it remains valid after the buffer m was decoded from is reused.
+//
+// Returns ErrAttrNotFound if m has no DATA attribute.
+func (d *DataAttribute) GetFrom(m *Message) error {
+	attr, ok := m.GetAttr(Data)
+	if !ok {
+		return ErrAttrNotFound
+	}
+	*d = append(DataAttribute(nil), attr.rawValue()...)
+	return nil
+}
+
+// Transport protocol numbers for REQUESTED-TRANSPORT (IANA protocol numbers)
+const (
+	TransportTCP uint8 = 6
+	TransportUDP uint8 = 17
+)
+
+// RequestedTransportAttribute is the value of a REQUESTED-TRANSPORT attribute
+// (RFC 5766 §14.7): the IP protocol the client wants the relayed address for.
+type RequestedTransportAttribute struct {
+	Protocol uint8
+}
+
+// AddTo appends the protocol to m as a REQUESTED-TRANSPORT attribute.
+func (r RequestedTransportAttribute) AddTo(m *Message) error {
+	value := make([]byte, 4) // Protocol followed by 24 reserved bits
+	value[0] = r.Protocol
+	return m.Add(RequestedTransport, value)
+}
+
+// GetFrom decodes the REQUESTED-TRANSPORT attribute of m into r.
+//
+// Returns ErrAttrNotFound if m has no REQUESTED-TRANSPORT attribute.
+func (r *RequestedTransportAttribute) GetFrom(m *Message) error {
+	value, err := fixedValue(m, RequestedTransport, 4)
+	if err != nil {
+		return err
+	}
+	r.Protocol = value[0]
+	return nil
+}
+
+// EvenPortAttribute is the value of an EVEN-PORT attribute (RFC 5766 §14.6):
+// it asks for a relayed port that is even and, if ReservePort is set, for the
+// next higher port to be reserved for a subsequent allocation.
+type EvenPortAttribute struct {
+	ReservePort bool
+}
+
+// AddTo appends the flag to m as an EVEN-PORT attribute.
+func (e EvenPortAttribute) AddTo(m *Message) error {
+	value := make([]byte, 1)
+	if e.ReservePort {
+		value[0] = 0x80
+	}
+	return m.Add(EvenPort, value)
+}
+
+// GetFrom decodes the EVEN-PORT attribute of m into e.
+//
+// Returns ErrAttrNotFound if m has no EVEN-PORT attribute.
+func (e *EvenPortAttribute) GetFrom(m *Message) error {
+	value, err := fixedValue(m, EvenPort, 1)
+	if err != nil {
+		return err
+	}
+	e.ReservePort = value[0]&0x80 != 0
+	return nil
+}
+
+// DontFragmentAttribute represents the DONT-FRAGMENT attribute (RFC 5766 §14.8),
+// a flag without value asking the server to set the DF bit on relayed packets.
+type DontFragmentAttribute struct{}
+
+// AddTo appends an empty DONT-FRAGMENT attribute to m.
+func (DontFragmentAttribute) AddTo(m *Message) error {
+	return m.Add(DontFragment, nil)
+}
+
+// IsSet reports whether m carries the DONT-FRAGMENT attribute.
+func (DontFragmentAttribute) IsSet(m *Message) bool {
+	_, ok := m.GetAttr(DontFragment)
+	return ok
+}
+
+// ReservationTokenLength is the size of a RESERVATION-TOKEN value
+const ReservationTokenLength = 8
+
+// ReservationTokenAttribute is the value of a RESERVATION-TOKEN attribute
+// (RFC 5766 §14.9): an opaque token identifying a relayed port reserved by a
+// previous allocation with EVEN-PORT.
+type ReservationTokenAttribute []byte
+
+// AddTo appends the token to m as a RESERVATION-TOKEN attribute.
+func (r ReservationTokenAttribute) AddTo(m *Message) error {
+	if len(r) != ReservationTokenLength {
+		return fmt.Errorf("invalid reservation token length: %d, want %d", len(r), ReservationTokenLength)
+	}
+	return m.Add(ReservationToken, append([]byte(nil), r...))
+}
+
+// GetFrom decodes the RESERVATION-TOKEN attribute of m into r.
+//
+// Returns ErrAttrNotFound if m has no RESERVATION-TOKEN attribute.
+func (r *ReservationTokenAttribute) GetFrom(m *Message) error {
+	value, err := fixedValue(m, ReservationToken, ReservationTokenLength)
+	if err != nil {
+		return err
+	}
+	*r = append(ReservationTokenAttribute(nil), value...)
+	return nil
+}
+
+// MobilityTicketAttribute is the value of a MOBILITY-TICKET attribute
+// (RFC 8016 §3.1): an opaque ticket issued by a TURN server so that a client
+// can refresh its allocation from a new transport address after a network
+// change. An empty ticket in an Allocate request asks for mobility support.
+type MobilityTicketAttribute []byte
+
+// AddTo appends the ticket to m as a MOBILITY-TICKET attribute.
+func (t MobilityTicketAttribute) AddTo(m *Message) error {
+	if paddedLength(len(t)) > maxAttrValueLength(m) {
+		return ErrAttrTooLong
+	}
+	return m.Add(MobilityTicket, append([]byte(nil), t...))
+}
+
+// GetFrom decodes the MOBILITY-TICKET attribute of m into t. The ticket is
+// copied, so it remains valid after the buffer m was decoded from is reused.
+//
+// Returns ErrAttrNotFound if m has no MOBILITY-TICKET attribute.
+func (t *MobilityTicketAttribute) GetFrom(m *Message) error {
+	attr, ok := m.GetAttr(MobilityTicket)
+	if !ok {
+		return ErrAttrNotFound
+	}
+	*t = append(MobilityTicketAttribute{}, attr.rawValue()...)
+	return nil
+}
+
+// fixedValue returns the value of the first attribute of type t in m,
+// checking that it is exactly size bytes long.
+func fixedValue(m *Message, t StunAttribute, size int) ([]byte, error) {
+	attr, ok := m.GetAttr(t)
+	if !ok {
+		return nil, ErrAttrNotFound
+	}
+	value := attr.rawValue()
+	if len(value) != size {
+		return nil, fmt.Errorf("invalid length %d for attribute 0x%04x, want %d", len(value), uint16(t), size)
+	}
+	return value, nil
+}
@@ -0,0 +1,222 @@
+package stun
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// URI is a parsed STUN URI (RFC 7064): "stun:host[:port]" for STUN over UDP
+// or TCP, "stuns:host[:port]" for STUN over TLS or DTLS.
+type URI struct {
+	// Scheme is "stun" or "stuns", in lower case.
+	Scheme string
+	// Host is a domain name or an IP address, IPv6 literals without their
+	// brackets.
+	Host string
+	// Port is the port of the URI, or the default port of its scheme when it
+	// has none (see SchemeDefaultPort).
+	Port int
+	// Secure reports a "stuns" URI, whose server is reached over TLS or DTLS.
+	Secure bool
+}
+
+// ParseURI parses a "stun:" or "stuns:" URI (RFC 7064 §3.1). The scheme is
+// case-insensitive, and the port defaults to that of the scheme.
+//
+// Returns an error wrapping ErrInvalidURI if s is not a STUN URI, e.g. if it
+// has another scheme, a "//" authority, user information, a path or a query.
+//
+// Example:
+//
+//	u, err := stun.ParseURI("stun:stun.example.org")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	fmt.Println(u.HostPort()) // stun.example.org:3478
+func ParseURI(s string) (*URI, error) {
+	scheme, rest, ok := strings.Cut(s, ":")
+	scheme = strings.ToLower(scheme)
+	if !ok || (scheme != "stun" && scheme != "stuns") {
+		return nil, fmt.Errorf("%w: %q: scheme must be stun or stuns", ErrInvalidURI, s)
+	}
+	host, port, err := parseURIHostPort(rest, scheme)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q: %v", ErrInvalidURI, s, err)
+	}
+	return &URI{Scheme: scheme, Host: host, Port: port, Secure: scheme == "stuns"}, nil
+}
+
+// parseURIHostPort parses the "host [":" port]" part of STUN and TURN URIs,
+// defaulting the port to that of scheme.
+func parseURIHostPort(s, scheme string) (string, int, error) {
+	if strings.HasPrefix(s, "//") {
+		return "", 0, fmt.Errorf("unexpected authority")
+	}
+	if strings.ContainsAny(s, "@/?#") {
+		return "", 0, fmt.Errorf("unexpected user information, path, query or fragment")
+	}
+	port, _ := SchemeDefaultPort(scheme)
+	host := s
+	if h, p, err := net.SplitHostPort(s); err == nil {
+		host = h
+		if p != "" {
+			n, err := strconv.Atoi(p)
+			if err != nil || n <= 0 || n > 65535 {
+				return "", 0, fmt.Errorf("invalid port %q", p)
+			}
+			port = n
+		}
+	} else if strings.HasPrefix(s, "[") && strings.HasSuffix(s, "]") {
+		host = s[1 : len(s)-1]
+	}
+	if strings.HasPrefix(s, "[") {
+		if ip := net.ParseIP(host); ip == nil || ip.To4() != nil {
+			return "", 0, fmt.Errorf("invalid IPv6 literal %q", host)
+		}
+	} else if strings.Contains(host, ":") {
+		return "", 0, fmt.Errorf("IPv6 literal must be bracketed")
+	}
+	host, err := url.PathUnescape(host)
+	if err != nil || host == "" {
+		return "", 0, fmt.Errorf("invalid host")
+	}
+	return host, port, nil
+}
+
+// HostPort returns the "host:port" address of the server, bracketing IPv6
+// literals, as taken by net.Dial and NewClient.
+func (u URI) HostPort() string {
+	return net.JoinHostPort(u.Host, strconv.Itoa(u.Port))
+}
+
+// String returns the URI with its port, e.g. "stun:stun.example.org:3478".
+func (u URI) String() string {
+	return u.Scheme + ":" + u.HostPort()
+}
+
+// isURI reports whether addr is meant as a STUN or TURN URI rather than a
+// "host:port" address: it starts with a scheme not followed by a port only,
+// "stun:3478" being the host named stun.
+func isURI(addr string) bool {
+	scheme, rest, ok := strings.Cut(addr, ":")
+	if !ok {
+		return false
+	}
+	if _, ok := SchemeDefaultPort(scheme); !ok {
+		return false
+	}
+	_, err := strconv.Atoi(rest)
+	return err != nil
+}
+
+// TURNURI is a parsed TURN URI (RFC 7065): "turn:host[:port]" or
+// "turns:host[:port]", optionally followed by "?transport=udp" or
+// "?transport=tcp", as found in WebRTC ICE server configurations.
+type TURNURI struct {
+	// Scheme is "turn" or "turns", in lower case.
+	Scheme string
+	// Host is a domain name or an IP address, IPv6 literals without their
+	// brackets.
+	Host string
+	// Port is the port of the URI, or the default port of its scheme when it
+	// has none (see SchemeDefaultPort).
+	Port int
+	// Secure reports a "turns" URI, whose server is reached over TLS or, with
+	// the udp transport, DTLS.
+	Secure bool
+	// Transport is the transport parameter in lower case, "udp", "tcp" or an
+	// extension, empty when the URI has none (see TURNURI.Network).
+	Transport string
+}
+
+// ParseTURNURI parses a "turn:" or "turns:" URI (RFC 7065 §3.1). The scheme
+// and the transport are case-insensitive, and the port defaults to that of
+// the scheme.
+//
+// Returns an error wrapping ErrInvalidURI if s is not a TURN URI, e.g. if it
+// has another scheme, a "//" authority, user information, a path or a query
+// other than the transport parameter.
+//
+// Example:
+//
+//	u, err := stun.ParseTURNURI("turn:turn.example.org?transport=tcp")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	conn, err := net.Dial(u.Network(), u.HostPort())
+func ParseTURNURI(s string) (*TURNURI, error) {
+	scheme, rest, ok := strings.Cut(s, ":")
+	scheme = strings.ToLower(scheme)
+	if !ok || (scheme != "turn" && scheme != "turns") {
+		return nil, fmt.Errorf("%w: %q: scheme must be turn or turns", ErrInvalidURI, s)
+	}
+	rest, query, hasQuery := strings.Cut(rest, "?")
+	var transport string
+	if hasQuery {
+		key, value, _ := strings.Cut(query, "=")
+		if key != "transport" || !isTransportExt(value) {
+			return nil, fmt.Errorf("%w: %q: query must be a transport parameter", ErrInvalidURI, s)
+		}
+		transport = strings.ToLower(value)
+	}
+	host, port, err := parseURIHostPort(rest, scheme)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q: %v", ErrInvalidURI, s, err)
+	}
+	return &TURNURI{
+		Scheme:    scheme,
+		Host:      host,
+		Port:      port,
+		Secure:    scheme == "turns",
+		Transport: transport,
+	}, nil
+}
+
+// isTransportExt reports whether s is a valid transport parameter value:
+// one or more unreserved characters (RFC 7065 §3.1, RFC 3986 §2.3).
+func isTransportExt(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		switch {
+		case 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z', '0' <= r && r <= '9':
+		case strings.ContainsRune("-._~", r):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// Network returns the network to reach the server on, as taken by net.Dial
+// for udp and tcp: the transport parameter, or the default of the scheme when
+// there is none, "udp" for turn and "tcp" for turns (RFC 7065 §3.2).
+func (u TURNURI) Network() string {
+	switch {
+	case u.Transport != "":
+		return u.Transport
+	case u.Secure:
+		return "tcp"
+	}
+	return "udp"
+}
+
+// HostPort returns the "host:port" address of the server, bracketing IPv6
+// literals.
+func (u TURNURI) HostPort() string {
+	return net.JoinHostPort(u.Host, strconv.Itoa(u.Port))
+}
+
+// String returns the URI with its port and, if set, its transport, e.g.
+// "turn:turn.example.org:3478?transport=tcp".
+func (u TURNURI) String() string {
+	s := u.Scheme + ":" + u.HostPort()
+	if u.Transport != "" {
+		s += "?transport=" + u.Transport
+	}
+	return s
+}
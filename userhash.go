@@ -0,0 +1,76 @@
+package stun
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+)
+
+// NewUserHash returns the USERHASH value for username in realm (RFC 8489
+// §14.4): SHA-256(username ":" realm). Both values are expected to be
+// already prepared with the OpaqueString profile, as they are hashed as is.
+func NewUserHash(username, realm string) []byte {
+	h := sha256.Sum256([]byte(username + ":" + realm))
+	return h[:]
+}
+
+// SetUserHash sets the USERHASH attribute of the message for username in
+// realm, replacing any existing one, so that the username is not sent in
+// cleartext. The message should then carry no USERNAME attribute.
+//
+// Example:
+//
+//	msg := &stun.Message{Header: stun.Header{Type: stun.BindingRequest}}
+//	msg.SetUserHash("alice", "example.org")
+func (m *Message) SetUserHash(username, realm string) {
+	// The hash is 32 bytes long, so Set cannot fail
+	_ = m.Set(UserHash, NewUserHash(username, realm))
+}
+
+// GetUserHash returns the value of the USERHASH attribute.
+//
+// Returns ErrAttrNotFound if the message has no USERHASH attribute and
+// ErrUserHashLength if the value is not UserHashLength bytes long.
+func (m Message) GetUserHash() ([]byte, error) {
+	attr, ok := m.GetAttr(UserHash)
+	if !ok {
+		return nil, ErrAttrNotFound
+	}
+	if attr.Length != UserHashLength {
+		return nil, ErrUserHashLength
+	}
+	return attr.rawValue(), nil
+}
+
+// CheckUserHash verifies that the USERHASH attribute of m is the hash of
+// username in realm. The comparison takes constant time, so that the
+// response time of a server does not tell how much of a guessed hash
+// matches.
+//
+// Returns ErrAttrNotFound if m has no USERHASH attribute, ErrUserHashLength
+// if its length is wrong and ErrUserHashMismatch if it does not match.
+func (m Message) CheckUserHash(username, realm string) error {
+	got, err := m.GetUserHash()
+	if err != nil {
+		return err
+	}
+	if subtle.ConstantTimeCompare(got, NewUserHash(username, realm)) != 1 {
+		return ErrUserHashMismatch
+	}
+	return nil
+}
+
+// useUserHash replaces the USERNAME attribute of m with the USERHASH of the
+// username in the realm of m. Messages without both attributes are left
+// untouched.
+func (m *Message) useUserHash() {
+	username, err := m.GetUsername()
+	if err != nil {
+		return
+	}
+	realm, err := m.GetRealm()
+	if err != nil {
+		return
+	}
+	m.Remove(Username)
+	m.SetUserHash(username, realm)
+}
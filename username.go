@@ -0,0 +1,59 @@
+package stun
+
+// MaxUsernameLength is the maximum size in bytes of a USERNAME value (RFC 5389 §15.3).
+const MaxUsernameLength = 513
+
+// SetUsername sets the USERNAME attribute of the message, replacing any
+// existing one. The value is padded to a 4-byte boundary on the wire and
+// Header.Length is updated accordingly. RFC 8489 §14.3 expects usernames
+// prepared with OpaqueString, which is left to the caller.
+//
+// Returns a *TextLengthError, matching ErrAttrTooLong, if the username is
+// longer than MaxUsernameLength bytes, and an error wrapping ErrInvalidUTF8
+// if it is not valid UTF-8.
+//
+// Example:
+//
+//	msg := &stun.Message{Header: stun.Header{Type: stun.BindingRequest}}
+//	if err := msg.SetUsername("alice:bob"); err != nil {
+//		log.Fatal(err)
+//	}
+func (m *Message) SetUsername(username string) error {
+	return usernameText.Set(m, username)
+}
+
+// GetUsername returns the value of the USERNAME attribute, without padding.
+//
+// Returns ErrAttrNotFound if the message has no USERNAME attribute, and the
+// errors of SetUsername if the received value is invalid.
+func (m Message) GetUsername() (string, error) {
+	return usernameText.Get(&m)
+}
+
+// UsernameAttribute is the value of a USERNAME attribute, for message
+// composition with Build.
+type UsernameAttribute string
+
+// NewUsername returns the USERNAME attribute with the given value.
+//
+// Example:
+//
+//	err := stun.Build(msg, stun.BindingRequest, stun.NewUsername("alice:bob"))
+func NewUsername(username string) UsernameAttribute {
+	return UsernameAttribute(username)
+}
+
+// AddTo sets the USERNAME attribute of m, as SetUsername does.
+func (u UsernameAttribute) AddTo(m *Message) error {
+	return m.SetUsername(string(u))
+}
+
+// GetFrom decodes the USERNAME attribute of m into u, as GetUsername does.
+func (u *UsernameAttribute) GetFrom(m *Message) error {
+	v, err := m.GetUsername()
+	if err != nil {
+		return err
+	}
+	*u = UsernameAttribute(v)
+	return nil
+}
@@ -1,21 +1,10 @@
 package stun
 
 import (
-	"crypto/rand"
 	"fmt"
 	"net"
 )
 
-func randomTransactionID() []byte {
-	transactionID := make([]byte, 12)
-	_, err := rand.Read(transactionID)
-	if err != nil {
-		// In case of error, return a zero-filled slice (fallback)
-		return transactionID
-	}
-	return transactionID
-}
-
 func GetPortFromAddr(addr net.Addr) (int, error) {
 	switch a := addr.(type) {
 	case *net.TCPAddr:
@@ -45,3 +34,12 @@ func GetPortAndIPFromAddr(addr net.Addr) (int, net.IP, error) {
 		return 0, nil, fmt.Errorf("unsupported address type: %T", addr)
 	}
 }
+
+// udpNetwork returns the network of a socket reaching ip: "udp4" for IPv4
+// addresses, including IPv4-mapped IPv6 ones, and "udp6" otherwise.
+func udpNetwork(ip net.IP) string {
+	if ip.To4() != nil {
+		return "udp4"
+	}
+	return "udp6"
+}
@@ -0,0 +1,173 @@
+package stun
+
+import (
+	"errors"
+	"fmt"
+)
+
+// addrLengths are the valid value lengths of the address attributes: IPv4
+// and IPv6 (RFC 5389 §15.1).
+var addrLengths = []int{8, 20}
+
+// attrLengths are the valid value lengths of the fixed-size attributes of
+// the package.
+var attrLengths = map[StunAttribute][]int{
+	MappedAddress:      addrLengths,
+	SourceAddress:      addrLengths,
+	ChangedAddress:     addrLengths,
+	XORPeerAddress:     addrLengths,
+	XORRelayedAddress:  addrLengths,
+	XORMappedAddress:   addrLengths,
+	AlternateServer:    addrLengths,
+	ResponseOrigin:     addrLengths,
+	OtherAddress:       addrLengths,
+	ChangeRequest:      {4},
+	MessageIntegrity:   {MessageIntegrityLength},
+	ChannelNumber:      {4},
+	Lifetime:           {4},
+	EvenPort:           {1},
+	RequestedTransport: {4},
+	DontFragment:       {0},
+	UserHash:           {UserHashLength},
+	ReservationToken:   {8},
+	Priority:           {4},
+	UseCandidate:       {0},
+	FingerprintAttr:    {4},
+	ICEControlled:      {8},
+	ICEControlling:     {8},
+}
+
+// attrMaxLengths are the maximum value lengths of the text attributes.
+var attrMaxLengths = map[StunAttribute]int{
+	Username: MaxUsernameLength,
+	Realm:    MaxRealmLength,
+	Nonce:    MaxNonceLength,
+	Software: MaxSoftwareLength,
+}
+
+// Validate checks m against the rules of RFC 5389 and RFC 8489: the magic
+// cookie, the zero top bits of the message type, a header length matching
+// the attributes, the padded length of every attribute, the length of the
+// attributes of the package and of those with a registered codec, and the
+// placement of MESSAGE-INTEGRITY, MESSAGE-INTEGRITY-SHA256 and FINGERPRINT
+// (see CheckAttrOrder).
+//
+// Returns nil for a valid message, and otherwise an error joining one error
+// per violation, each wrapping ErrInvalidMessage and, where one applies, the
+// more specific error such as ErrInvalidCookie or ErrAttrOrder.
+//
+// Example:
+//
+//	if err := msg.Validate(); err != nil {
+//		// One violation per line
+//		log.Printf("invalid message:\n%v", err)
+//	}
+func (m *Message) Validate() error {
+	return errors.Join(m.violations()...)
+}
+
+// violations returns the errors described by Validate.
+func (m *Message) violations() []error {
+	var errs []error
+	violation := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%w: "+format, append([]any{ErrInvalidMessage}, args...)...))
+	}
+
+	if m.Header.IsClassic() {
+		violation("%w: 0x%08x", ErrInvalidCookie, m.Header.MagicCookie)
+	}
+	if uint16(m.Header.Type)&0xC000 != 0 {
+		violation("message type 0x%04x has its top bits set", uint16(m.Header.Type))
+	}
+	if size := m.attrsSize(); int(m.Header.Length) != size {
+		violation("header length %d, attributes take %d bytes", m.Header.Length, size)
+	}
+
+	registered := registeredAttrs()
+	for i, attr := range m.Attributes {
+		n := int(attr.Length)
+		if attr.PaddedLength != paddedLength(n) {
+			violation("attribute %d (0x%04x): padded length %d for length %d", i, uint16(attr.Type), attr.PaddedLength, n)
+		}
+		if len(attr.Value) != n {
+			violation("attribute %d (0x%04x): %d value bytes for length %d", i, uint16(attr.Type), len(attr.Value), n)
+		}
+		if err := checkAttrLength(attr, registered); err != nil {
+			violation("attribute %d (0x%04x): %w", i, uint16(attr.Type), err)
+		}
+	}
+
+	if err := m.CheckAttrOrder(); err != nil {
+		errs = append(errs, fmt.Errorf("%w: %w", ErrInvalidMessage, err))
+	}
+	return errs
+}
+
+// checkAttrLength checks the length of the value of attr against the format
+// of its type, when known.
+func checkAttrLength(attr Attribute, registered map[StunAttribute]AttrCodec) error {
+	n := int(attr.Length)
+	if lengths, ok := attrLengths[attr.Type]; ok {
+		for _, l := range lengths {
+			if n == l {
+				return nil
+			}
+		}
+		return fmt.Errorf("invalid length %d for %s", n, attr.Type.Name())
+	}
+	if max, ok := attrMaxLengths[attr.Type]; ok && n > max {
+		return fmt.Errorf("%w: %d bytes for %s, max %d", ErrAttrTooLong, n, attr.Type.Name(), max)
+	}
+	switch attr.Type {
+	case MessageIntegritySHA256:
+		if !validIntegritySHA256Length(n) {
+			return ErrIntegrityLength
+		}
+	case ErrorCode:
+		if n < ErrorCodeLength {
+			return fmt.Errorf("invalid length %d for ERROR-CODE", n)
+		}
+	case UnknownStunAttributes:
+		if n%2 != 0 {
+			return fmt.Errorf("invalid length %d for UNKNOWN-ATTRIBUTES", n)
+		}
+	}
+	if c, ok := registered[attr.Type]; ok && c.Unmarshal != nil {
+		if _, err := c.Unmarshal(attr.rawValue()); err != nil {
+			return fmt.Errorf("%s: %w", c.Name, err)
+		}
+	}
+	return nil
+}
+
+// ValidationPolicy returns middleware running Message.Validate on requests
+// and indications: invalid requests are answered with a 400 (Bad Request)
+// error and invalid indications are dropped. The missing magic cookie of
+// classic requests is not a violation, as the server only lets them through
+// when configured for RFC 3489 clients.
+//
+// The server applies the policy first, before the configured middleware,
+// when ServerConfig.StrictValidation is set.
+func ValidationPolicy() Middleware {
+	return func(next Handler) Handler {
+		return func(req *Request) (*Message, error) {
+			class := req.Message.Header.Type.Class()
+			if class != ClassRequest && class != ClassIndication {
+				return next(req)
+			}
+			var errs []error
+			for _, err := range req.Message.violations() {
+				if !errors.Is(err, ErrInvalidCookie) {
+					errs = append(errs, err)
+				}
+			}
+			if errs == nil {
+				return next(req)
+			}
+			if class == ClassIndication {
+				return nil, nil
+			}
+			return NewErrorResponse(req.Message, CodeBadRequest)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package stun_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/lai0xn/stun"
+	"github.com/lai0xn/stun/stuntest"
+)
+
+func TestVectors(t *testing.T) {
+	for _, v := range stuntest.Vectors {
+		t.Run(v.Name, func(t *testing.T) { stuntest.AssertVector(t, v) })
+	}
+}
+
+// TestEncodeGolden builds the long-term request of RFC 5769 §2.4, whose
+// padding is all zero, from its attributes: Encode must produce the bytes of
+// the RFC, now and in later releases.
+func TestEncodeGolden(t *testing.T) {
+	v := stuntest.SampleLongTermRequest
+	var id stun.TransactionID
+	copy(id[:], v.Raw[8:20])
+
+	m := new(stun.Message)
+	err := stun.Build(m, stun.BindingRequest, id,
+		stun.UsernameAttribute(stuntest.VectorLongTermUsername),
+		stun.NonceAttribute("f//499k954d6OL34oL9FSTvy64sA"),
+		stun.RealmAttribute(stuntest.VectorRealm),
+		v.Key,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc := m.Encode()
+	if !bytes.Equal(enc, v.Raw) {
+		t.Fatalf("encoded to\n%x\nwant\n%x", enc, v.Raw)
+	}
+	if again := m.Encode(); !bytes.Equal(again, enc) {
+		t.Fatalf("second encoding differs:\n%x\nwant\n%x", again, enc)
+	}
+}
+
+func TestVectorPreparedKey(t *testing.T) {
+	v := stuntest.SampleLongTermRequest
+	key, err := stun.NewLongTermIntegrityPrepared(stuntest.VectorLongTermUsername, stuntest.VectorRealm, stuntest.VectorLongTermPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := stun.NewMessage(v.Raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := key.Check(m); err != nil {
+		t.Fatalf("key prepared from the unprepared password: %v", err)
+	}
+}
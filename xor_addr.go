@@ -1,9 +1,9 @@
 package stun
 
 import (
-	"encoding/binary"
-	"fmt"
 	"net"
+	"net/netip"
+	"strconv"
 )
 
 type IPFamily uint16
@@ -29,52 +29,65 @@ type XorMappedAddr struct {
 	Port   uint16
 }
 
-// SerializeAddr takes an ip and Port and encodes into a byte slice
-func serializeAddr(addr XorMappedAddr, transactionID [12]byte) ([]byte, error) {
-	ipv4 := addr.IP.To4()
-	if ipv4 == nil {
-		return nil, fmt.Errorf("invalid IPv4 address")
-	}
-
-	buf := make([]byte, 8)
-	buf[0] = 0x00 // Reserved
-	buf[1] = byte(IPV4)
-
-	// XOR Port
-	xorPort := addr.Port ^ uint16(magicCookie>>16)
-	buf[2] = byte(xorPort >> 8)
-	buf[3] = byte(xorPort & 0xFF)
+// AddTo appends the address to m as an XOR-MAPPED-ADDRESS attribute. The
+// transaction ID of m must be set beforehand since it is part of the XOR key
+// for IPv6 addresses.
+func (a XorMappedAddr) AddTo(m *Message) error {
+	return AddressAttribute{Type: XORMappedAddress, XOR: true}.Add(m, MappedAddr(a))
+}
 
-	// XOR IP
-	magicCookieBytes := make([]byte, 4)
-	binary.BigEndian.PutUint32(magicCookieBytes, magicCookie)
+// GetFrom decodes the first XOR-MAPPED-ADDRESS attribute of m into a.
+//
+// Returns ErrAttrNotFound if m has no XOR-MAPPED-ADDRESS attribute.
+func (a *XorMappedAddr) GetFrom(m *Message) error {
+	return getAddr(m, AddressAttribute{Type: XORMappedAddress, XOR: true}, (*MappedAddr)(a))
+}
 
-	for i := 0; i < 4; i++ {
-		buf[4+i] = ipv4[i] ^ magicCookieBytes[i]
+// FromUDPAddr returns the XorMappedAddr of addr, its family derived from the
+// IP, e.g. to answer a request with the address it came from.
+func FromUDPAddr(addr *net.UDPAddr) XorMappedAddr {
+	family, ip := IPV4, addr.IP.To4()
+	if ip == nil {
+		family, ip = IPV6, addr.IP
 	}
-
-	return buf, nil
+	return XorMappedAddr{Family: family, IP: ip, Port: uint16(addr.Port)}
 }
 
-// DecodeAddr takes an ip and Port as bytes and decodes them into XorMappedAddr
-func decodeAddr(addr []byte) *XorMappedAddr {
-
-	// Decode IP Family
-	// Skip the first reserved byte
-	familly := addr[1]
-
-	x := uint16(magicCookie >> 16)
-
-	port := uint16(uint16(addr[2])<<8 | uint16(addr[3]) ^ x)
-
-	ip := make([]byte, 4)
+// FromNetipAddrPort returns the XorMappedAddr of ap. IPv4-mapped IPv6
+// addresses are unmapped to IPv4.
+func FromNetipAddrPort(ap netip.AddrPort) XorMappedAddr {
+	ip := ap.Addr().Unmap()
+	family := IPV4
+	if ip.Is6() {
+		family = IPV6
+	}
+	return XorMappedAddr{Family: family, IP: net.IP(ip.AsSlice()), Port: ap.Port()}
+}
 
-	binary.BigEndian.PutUint32(ip, binary.BigEndian.Uint32(addr[4:8])^magicCookie)
+// UDPAddr returns the address as a *net.UDPAddr.
+//
+// Example:
+//
+//	xorAddr, err := resp.GetXorAddr()
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	conn.WriteTo(payload, xorAddr.UDPAddr())
+func (a XorMappedAddr) UDPAddr() *net.UDPAddr {
+	return &net.UDPAddr{IP: a.IP, Port: int(a.Port)}
+}
 
-	return &XorMappedAddr{
-		Family: IPFamily(familly),
-		Port:   port,
-		IP:     net.IP(ip),
+// AddrPort returns the address as a netip.AddrPort, IPv4 addresses being
+// 4 bytes long. It returns the zero AddrPort if IP is invalid.
+func (a XorMappedAddr) AddrPort() netip.AddrPort {
+	ip, ok := netip.AddrFromSlice(a.IP)
+	if !ok {
+		return netip.AddrPort{}
 	}
+	return netip.AddrPortFrom(ip.Unmap(), a.Port)
+}
 
+// String returns the address in host:port form, IPv6 addresses bracketed.
+func (a XorMappedAddr) String() string {
+	return net.JoinHostPort(a.IP.String(), strconv.Itoa(int(a.Port)))
 }